@@ -1,27 +1,39 @@
 package _rt_package_name_
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 type GoRequest struct {
-	r *http.Request
+	r    *http.Request
+	data []byte
+}
+
+func (p *GoRequest) Method() string {
+	return p.r.Method
 }
 
 func (p *GoRequest) Action() string {
 	return p.r.URL.Query().Get("a")
 }
 
+// Data is the query d of a GET request, otherwise the body that is read
+// by the handler
 func (p *GoRequest) Data() []byte {
 	if p.r.Method == http.MethodGet {
 		return []byte(p.r.URL.Query().Get("d"))
 	} else {
-		body, err := io.ReadAll(p.r.Body)
-		if err != nil {
-			return nil
-		}
-		return body
+		return p.data
 	}
 }
 
@@ -49,35 +61,230 @@ func (p *GoResponse) WriteHeader(code int) {
 	p.w.WriteHeader(code)
 }
 
+// NewHttpServer is the server of addr, cors allows any origin
 func NewHttpServer(addr string, certFile string, keyFile string, cors bool) *Server {
+	config := &ServerConfig{Listen: addr}
+	if certFile != "" || keyFile != "" {
+		config.TLS = &ServerTLSConfig{Cert: certFile, Key: keyFile}
+	}
+	if cors {
+		config.CORS = &ServerCORSConfig{
+			Origins: []string{"*"},
+			Methods: []string{http.MethodGet, http.MethodPost},
+			Headers: []string{"Content-Type", "Authorization"},
+		}
+	}
+	return NewServer(config)
+}
+
+// NewServer is the server of a checked config, see LoadServerConfig
+func NewServer(config *ServerConfig) *Server {
+	maxBodySize, _ := ParseConfigSize(config.MaxBodySize)
 	mux := http.NewServeMux()
+	ret := &Server{config: config}
 
 	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
-		apiHandler(cors, &GoResponse{w: w}, &GoRequest{r: r})
+		// the responses of the gates have the CORS headers too
+		response, request := &GoResponse{w: w}, &GoRequest{r: r}
+		if corsHandler(config.CORS, response, request) {
+			return
+		}
+
+		if dbManager := GetDBManager(); dbManager != nil && !dbManager.IsMigrated() {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			if maxBodySize > 0 {
+				if r.ContentLength > maxBodySize {
+					w.WriteHeader(http.StatusRequestEntityTooLarge)
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+			}
+
+			// a body without Content-Length exceeds the limit while it is read
+			body, err := io.ReadAll(r.Body)
+			if maxBytesErr := (*http.MaxBytesError)(nil); errors.As(err, &maxBytesErr) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			} else if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			request.data = body
+		}
+		apiHandler(response, request)
+	})
+
+	// healthz is the liveness of the process
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	// readyz is ready after the tables are migrated until the server is shut down
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := ret.ready(r.Context()); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(err.Error()))
+		} else {
+			_, _ = w.Write([]byte("ok"))
+		}
 	})
 
-	return &Server{
-		addr:     addr,
-		certFile: certFile,
-		keyFile:  keyFile,
-		server: &http.Server{
-			Addr:    addr,
-			Handler: mux,
-		},
+	ret.server = &http.Server{
+		Addr:              config.Listen,
+		Handler:           mux,
+		ReadTimeout:       configDuration(config.ReadTimeout),
+		ReadHeaderTimeout: configDuration(config.ReadHeaderTimeout),
+		WriteTimeout:      configDuration(config.WriteTimeout),
+		IdleTimeout:       configDuration(config.IdleTimeout),
 	}
+
+	if config.AdminAddress != "" {
+		ret.admin = &http.Server{
+			Addr:              config.AdminAddress,
+			Handler:           newAdminHandler(config.AdminToken),
+			ReadHeaderTimeout: configDuration(config.ReadHeaderTimeout),
+		}
+	}
+
+	return ret
 }
 
 type Server struct {
-	addr     string
-	certFile string
-	keyFile  string
+	config   *ServerConfig
 	server   *http.Server
+	admin    *http.Server
+	shutdown atomic.Bool
 }
 
-func (p *Server) Run() error {
-	if p.certFile != "" || p.keyFile != "" {
-		return p.server.ListenAndServeTLS(p.certFile, p.keyFile)
+// ready returns the reason why the server can not serve the actions
+func (p *Server) ready(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	if p.shutdown.Load() {
+		return fmt.Errorf("shutting down")
+	} else if dbManager := GetDBManager(); dbManager == nil {
+		return nil
+	} else if !dbManager.IsMigrated() {
+		return fmt.Errorf("migrating")
+	} else if err := dbManager.Ping(ctx); err != nil {
+		return fmt.Errorf("db: %s", err.Error())
 	} else {
-		return p.server.ListenAndServe()
+		return nil
+	}
+}
+
+// Run listens, migrates the tables and serves until the server is shut
+// down or SIGINT or SIGTERM is received, then it shuts down the server
+func (p *Server) Run() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	listener, err := net.Listen("tcp", p.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	if p.config.Log != nil {
+		SetLogger(newLogger(p.config.Log, os.Stderr))
+	}
+	SetExposeErrors(p.config.ExposeErrors)
+
+	if tracing := p.config.Tracing; tracing != nil {
+		serviceName := tracing.ServiceName
+		if serviceName == "" {
+			serviceName = "capi"
+		}
+		_ = SetSpanExporter(NewOTLPExporter(tracing.Endpoint, serviceName, tracing.Headers))
+	}
+
+	adminListener := net.Listener(nil)
+	if p.admin != nil {
+		if adminListener, err = net.Listen("tcp", p.admin.Addr); err != nil {
+			_ = listener.Close()
+			return err
+		}
+	}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		if p.config.TLS != nil {
+			serveErr <- p.server.ServeTLS(listener, p.config.TLS.Cert, p.config.TLS.Key)
+		} else {
+			serveErr <- p.server.Serve(listener)
+		}
+	}()
+
+	if adminListener != nil {
+		go func() {
+			if p.config.TLS != nil {
+				serveErr <- p.admin.ServeTLS(adminListener, p.config.TLS.Cert, p.config.TLS.Key)
+			} else {
+				serveErr <- p.admin.Serve(adminListener)
+			}
+		}()
+	}
+
+	Logger().Info("server listening", "address", listener.Addr().String())
+
+	if dbManager := GetDBManager(); dbManager != nil && !dbManager.IsMigrated() {
+		if err := dbManager.Open(); err != nil {
+			Logger().Error("db migration failed", "trace", DebugError(err))
+			_ = p.Shutdown(context.Background())
+			return err
+		}
+		Logger().Info("db migrated")
+	}
+
+	select {
+	case err := <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		_ = p.Shutdown(context.Background())
+		return err
+	case received := <-signals:
+		Logger().Info("server shutting down", "signal", received.String())
+		ctx := context.Background()
+		if timeout := configDuration(p.config.ShutdownTimeout); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return p.Shutdown(ctx)
+	}
+}
+
+// Shutdown stops accepting requests, waits for the in-flight actions until
+// ctx is done and closes the db manager
+func (p *Server) Shutdown(ctx context.Context) error {
+	p.shutdown.Store(true)
+	err := p.server.Shutdown(ctx)
+
+	if p.admin != nil {
+		if e := p.admin.Shutdown(ctx); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	if dbManager := GetDBManager(); dbManager != nil {
+		if e := dbManager.Close(); e != nil && err == nil {
+			err = e
+		}
 	}
+
+	// the spans of the drained actions are exported before the exit
+	if p.config.Tracing != nil {
+		if e := SetSpanExporter(nil); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
 }
@@ -0,0 +1,113 @@
+package _rt_package_name_
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ootiny/capi/utils"
+)
+
+func TestServer_health(t *testing.T) {
+	conn := &testSqlConn{}
+	dbManager := &SQLManager{
+		agent:    NewPGAgent(),
+		db:       sql.OpenDB(conn),
+		tableMap: map[string]*DBTable{},
+		mutex:    &sync.Mutex{},
+	}
+
+	gDBManager = dbManager
+	defer func() { gDBManager = nil }()
+
+	server := NewServer(&ServerConfig{
+		Listen: "127.0.0.1:0",
+		CORS:   &ServerCORSConfig{Origins: []string{"https://app.com"}, Methods: []string{"POST"}},
+	})
+	fnGet := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		server.server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code, w.Body.String()
+	}
+
+	t.Run("migrating", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(fnGet("/healthz")).Equals(http.StatusOK, "ok")
+		assert(fnGet("/readyz")).Equals(http.StatusServiceUnavailable, "migrating")
+		code, _ := fnGet("/api?a=test")
+		assert(code).Equals(http.StatusServiceUnavailable)
+
+		// the CORS policy is applied before the gates
+		r := httptest.NewRequest(http.MethodPost, "/api?a=test", nil)
+		r.Header.Set("Origin", "https://app.com")
+		w := httptest.NewRecorder()
+		server.server.Handler.ServeHTTP(w, r)
+		assert(w.Code, w.Header().Get("Access-Control-Allow-Origin")).Equals(http.StatusServiceUnavailable, "https://app.com")
+		assert(w.Header().Get("Vary")).Equals("Origin")
+
+		r = httptest.NewRequest(http.MethodOptions, "/api?a=test", nil)
+		r.Header.Set("Origin", "https://app.com")
+		r.Header.Set("Access-Control-Request-Method", "POST")
+		w = httptest.NewRecorder()
+		server.server.Handler.ServeHTTP(w, r)
+		assert(w.Code, w.Header().Get("Access-Control-Allow-Methods")).Equals(http.StatusNoContent, "POST")
+	})
+
+	t.Run("ready", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		dbManager.migrated.Store(true)
+		assert(fnGet("/readyz")).Equals(http.StatusOK, "ok")
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(server.Shutdown(context.Background())).IsNil()
+		assert(fnGet("/healthz")).Equals(http.StatusOK, "ok")
+		assert(fnGet("/readyz")).Equals(http.StatusServiceUnavailable, "shutting down")
+		assert(dbManager.db == nil).IsTrue()
+	})
+}
+
+func TestServer_maxBodySize(t *testing.T) {
+	SetLogger(newLogger(&ServerLogConfig{}, &bytes.Buffer{}))
+	RegisterHandler("test:Body", func(ctx *Context, data []byte) *Return {
+		return &Return{Data: string(data)}
+	})
+	defer func() {
+		SetLogger(nil)
+		delete(gAPIMap, "test:Body")
+	}()
+
+	server := NewServer(&ServerConfig{Listen: "127.0.0.1:0", MaxBodySize: "8"})
+	fnPost := func(body string, chunked bool) (int, string) {
+		// a reader of an unknown type has no Content-Length
+		r := httptest.NewRequest(http.MethodPost, "/api?a=test:Body", io.MultiReader(strings.NewReader(body)))
+		if chunked {
+			r.TransferEncoding = []string{"chunked"}
+		} else {
+			r.ContentLength = int64(len(body))
+		}
+		w := httptest.NewRecorder()
+		server.server.Handler.ServeHTTP(w, r)
+		return w.Code, w.Body.String()
+	}
+
+	t.Run("declared", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		code, _ := fnPost(`"0123456789"`, false)
+		assert(code).Equals(http.StatusRequestEntityTooLarge)
+	})
+
+	t.Run("chunked", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		code, _ := fnPost(`"0123456789"`, true)
+		assert(code).Equals(http.StatusRequestEntityTooLarge)
+		assert(fnPost(`"0123"`, true)).Equals(http.StatusOK, `{"code":200,"message":"","data":"\"0123\""}`)
+	})
+}
@@ -0,0 +1,453 @@
+package _rt_package_name_
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ootiny/capi/utils"
+)
+
+func TestPGSqlAgent_Update(t *testing.T) {
+	t.Run("without conditions", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().Update("db_city", []string{"name", "level"}, nil)).Equals(
+			"UPDATE \"db_city\" SET \"name\" = $1,\"level\" = $2 WHERE id = $3;",
+		)
+	})
+
+	t.Run("with conditions", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().Update("db_city", []string{"name"}, []string{"deleted_at"})).Equals(
+			"UPDATE \"db_city\" SET \"name\" = $1 WHERE id = $2 AND \"deleted_at\" = $3;",
+		)
+	})
+
+	t.Run("with version", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().Update("db_city", []string{"name"}, []string{"version"})).Equals(
+			"UPDATE \"db_city\" SET \"name\" = $1,\"version\" = \"version\" + 1 WHERE id = $2 AND \"version\" = $3;",
+		)
+	})
+}
+
+func TestPGSqlAgent_QuoteIdentifier(t *testing.T) {
+	agent := NewPGAgent()
+
+	t.Run("dml", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(agent.Insert(`a"b`, []string{`c"d`, "e"})).Equals(
+			`INSERT INTO "a""b" ("c""d","e") VALUES($1,$2);`,
+		)
+		assert(agent.Update(`a"b`, []string{`c"d`}, []string{`e"f`})).Equals(
+			`UPDATE "a""b" SET "c""d" = $1 WHERE id = $2 AND "e""f" = $3;`,
+		)
+		assert(agent.Delete(`a"b`)).Equals(`DELETE FROM "a""b" WHERE id = $1;`)
+	})
+
+	t.Run("query", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(agent.QuerySelect(`a"b`, []string{`c"d`, "e"})).Equals(`"c""d","e"`)
+		where, _, e := agent.QueryWhere(`a"b`, 0, NewQuery(`a"b`).And(`c"d`, SqlEqual, 1))
+		assert(where, e).Equals(`("c""d" = $1)`, nil)
+	})
+}
+
+func TestPGSqlAgent_CreateEnumCheck(t *testing.T) {
+	assert := utils.NewAssert(t)
+	assert(NewPGAgent().CreateEnumCheck("db_city", "status", []string{"open", "o'clock"})).Equals(
+		"ALTER TABLE \"db_city\" ADD CONSTRAINT \"db_city__check__status\" " +
+			"CHECK (\"status\" = '' OR \"status\" IN ('open','o''clock'));",
+	)
+	assert(NewPGAgent().DropEnumCheck("db_city", "status")).Equals(
+		"ALTER TABLE \"db_city\" DROP CONSTRAINT IF EXISTS \"db_city__check__status\";",
+	)
+}
+
+func TestPGSqlAgent_Exists(t *testing.T) {
+	t.Run("without conditions", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().Exists("db_city", nil)).Equals(
+			"SELECT 1 FROM \"db_city\" WHERE id = $1;",
+		)
+	})
+
+	t.Run("with conditions", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().Exists("db_city", []string{"deleted_at"})).Equals(
+			"SELECT 1 FROM \"db_city\" WHERE id = $1 AND \"deleted_at\" = $2;",
+		)
+	})
+}
+
+func TestPGSqlAgent_References(t *testing.T) {
+	t.Run("LK", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().References("db_city", "geo", "LK", nil)).Equals(
+			"SELECT id, \"geo\" FROM \"db_city\" WHERE \"geo\" = $1;",
+		)
+	})
+
+	t.Run("LKList", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().References("db_city", "geo_list", "LKList", []string{"deleted_at"})).Equals(
+			"SELECT id, \"geo_list\" FROM \"db_city\" WHERE \"geo_list\"::jsonb @> jsonb_build_array($1::text) AND \"deleted_at\" = $2;",
+		)
+	})
+
+	t.Run("LKMap", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().References("db_city", "geo_map", "LKMap", nil)).Equals(
+			"SELECT id, \"geo_map\" FROM \"db_city\" WHERE EXISTS (SELECT 1 FROM jsonb_each_text(\"geo_map\"::jsonb) WHERE value = $1);",
+		)
+	})
+}
+
+func TestPGSqlAgent_CreateCompositeIndex(t *testing.T) {
+	t.Run("index", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().CreateCompositeIndex("db_city", "status_name", &DBTableIndex{
+			Columns: []string{"status", "name:descend"},
+		})).Equals(
+			"CREATE INDEX \"db_city__cindex__status_name\" ON \"db_city\" (\"status\" ASC, \"name\" DESC);",
+		)
+	})
+
+	t.Run("partial unique index", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().CreateCompositeIndex("db_city", "code", &DBTableIndex{
+			Columns: []string{"code"},
+			Unique:  true,
+			Where:   "code <> ''",
+		})).Equals(
+			"CREATE UNIQUE INDEX \"db_city__cindex__code\" ON \"db_city\" (\"code\" ASC) WHERE code <> '';",
+		)
+	})
+}
+
+func TestPGSqlAgent_QueryAfter(t *testing.T) {
+	assert := utils.NewAssert(t)
+	assert(NewPGAgent().QueryAfter("db_city", 2, []*SqlOrderBy{{"level", false, ""}, {"id", true, ""}})).Equals(
+		"((\"level\" < $3) OR (\"level\" = $3 AND \"id\" > $4))",
+	)
+}
+
+func TestPGSqlAgent_QueryAggregate(t *testing.T) {
+	t.Run("count", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		query := NewQuery("db_city").Count()
+		assert(NewPGAgent().QueryAggregate("db_city", query)).Equals("count(*) AS \"count\"")
+		assert(NewPGAgent().QueryGroupBy("db_city", query)).Equals("")
+	})
+
+	t.Run("group by", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		query := NewQuery("db_city").Sum("age").Max("age").GroupBy("status")
+		assert(NewPGAgent().QueryAggregate("db_city", query)).Equals(
+			"\"status\",sum(\"age\") AS \"sum(age)\",max(\"age\") AS \"max(age)\"",
+		)
+		assert(NewPGAgent().QueryGroupBy("db_city", query)).Equals("\"status\"")
+	})
+}
+
+func TestPGSqlAgent_Search(t *testing.T) {
+	t.Run("where", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		where, args, e := NewPGAgent().QueryWhere("db_city", 1, NewQuery("db_city").And("name", SqlSearch, "new york"))
+		assert(e).IsNil()
+		assert(where).Equals("(\"name__search\" @@ websearch_to_tsquery('simple', $2))")
+		assert(args).Equals([]any{"new york"})
+	})
+
+	t.Run("order by", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		orderBy, args := NewPGAgent().QueryOrderBy("db_city", 2, NewQuery("db_city").OrderBySearch("name", "york").OrderByAsc("age"))
+		assert(orderBy).Equals("ts_rank(\"name__search\", websearch_to_tsquery('simple', $3)) DESC, \"age\" ASC")
+		assert(args).Equals([]any{"york"})
+	})
+}
+
+func TestPGSqlAgent_Geo(t *testing.T) {
+	t.Run("add column", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().AddColumn("db_geo", "location", "GeoPoint")).Equals(
+			"ALTER TABLE \"db_geo\" ADD COLUMN \"location\" point NOT NULL DEFAULT '(0,0)'; " +
+				"CREATE INDEX \"db_geo__spatial__location\" ON \"db_geo\" USING GIST (\"location\");",
+		)
+	})
+
+	t.Run("within box", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		where, args, e := NewPGAgent().QueryWhere("db_geo", 0, NewQuery("db_geo").And(
+			"location", SqlWithinBox, map[string]any{
+				"min": map[string]any{"latitude": 1.0, "longitude": 2.0},
+				"max": map[string]any{"latitude": 3.0, "longitude": 4.0},
+			},
+		))
+		assert(e).IsNil()
+		assert(where).Equals("(\"location\" <@ box(point($1::float8, $2::float8), point($3::float8, $4::float8)))")
+		assert(args).Equals([]any{2.0, 1.0, 4.0, 3.0})
+	})
+
+	t.Run("within radius", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		where, args, e := NewPGAgent().QueryWhere("db_geo", 0, NewQuery("db_geo").And(
+			"location", SqlWithinRadius, GeoRadius{Center: NewGeoPoint(0, 0), Radius: 10000},
+		))
+		assert(e).IsNil()
+		assert(strings.HasPrefix(where, "((\"location\" <@ box(point($4::float8, $5::float8), point($6::float8, $7::float8)) AND 6371008.8 * 2 * asin(")).IsTrue()
+		assert(strings.HasSuffix(where, "<= $3::float8))")).IsTrue()
+		assert(len(args)).Equals(7)
+		assert(args[:3]).Equals([]any{0.0, 0.0, 10000.0})
+	})
+
+	t.Run("within radius near the pole", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		where, args, e := NewPGAgent().QueryWhere("db_geo", 0, NewQuery("db_geo").And(
+			"location", SqlWithinRadius, GeoRadius{Center: NewGeoPoint(89.99, 0), Radius: 10000},
+		))
+		assert(e).IsNil()
+		assert(strings.Contains(where, "box(")).IsFalse()
+		assert(len(args)).Equals(3)
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		_, _, e := NewPGAgent().QueryWhere("db_geo", 0, NewQuery("db_geo").And("location", SqlWithinRadius, "10km"))
+		assert(e).IsNotNil()
+		_, _, e = NewPGAgent().QueryWhere("db_geo", 0, NewQuery("db_geo").And(
+			"location", SqlWithinRadius, GeoRadius{Center: NewGeoPoint(0, 0), Radius: -1},
+		))
+		assert(e).IsNotNil()
+	})
+}
+
+func TestPGSqlAgent_InsertMany(t *testing.T) {
+	t.Run("rows", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().InsertMany("db_city", []string{"id", "name"}, 2)).Equals(
+			"INSERT INTO \"db_city\" (\"id\",\"name\") VALUES ($1,$2),($3,$4);",
+		)
+	})
+
+	t.Run("max args", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().MaxArgs()).Equals(65535)
+	})
+}
+
+func TestPGSqlAgent_Upsert(t *testing.T) {
+	t.Run("by id", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().Upsert("db_city", []string{"created_at", "id", "name"}, 2, "id", nil, false)).Equals(
+			"INSERT INTO \"db_city\" (\"created_at\",\"id\",\"name\") VALUES ($1,$2,$3),($4,$5,$6) " +
+				"ON CONFLICT (\"id\") DO UPDATE SET \"name\" = EXCLUDED.\"name\" RETURNING id, \"id\"::text;",
+		)
+	})
+
+	t.Run("with conditions and version", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().Upsert("db_city", []string{"code", "id"}, 1, "code", []string{"deleted_at"}, true)).Equals(
+			"INSERT INTO \"db_city\" (\"code\",\"id\") VALUES ($1,$2) " +
+				"ON CONFLICT (\"code\") DO UPDATE SET \"version\" = \"db_city\".\"version\" + 1 " +
+				"WHERE \"db_city\".\"deleted_at\" = $3 RETURNING id, \"code\"::text;",
+		)
+	})
+
+	t.Run("nothing to update", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().Upsert("db_city", []string{"id"}, 1, "id", nil, false)).Equals(
+			"INSERT INTO \"db_city\" (\"id\") VALUES ($1) " +
+				"ON CONFLICT (\"id\") DO UPDATE SET \"id\" = EXCLUDED.\"id\" RETURNING id, \"id\"::text;",
+		)
+	})
+}
+
+func TestPGSqlAgent_UpdateMany(t *testing.T) {
+	t.Run("with where", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		where, args, e := NewPGAgent().QueryWhere("db_city", 1, NewQuery("db_city").And("age", SqlGreaterThan, 3))
+		assert(e).IsNil()
+		assert(args).Equals([]any{3})
+		assert(NewPGAgent().UpdateMany("db_city", []string{"name"}, where, true)).Equals(
+			"UPDATE \"db_city\" SET \"name\" = $1,\"version\" = \"version\" + 1 WHERE (\"age\" > $2);",
+		)
+	})
+
+	t.Run("without where", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewPGAgent().UpdateMany("db_city", []string{"name", "age"}, "", false)).Equals(
+			"UPDATE \"db_city\" SET \"name\" = $1,\"age\" = $2;",
+		)
+	})
+}
+
+func TestPGSqlAgent_QueryWhere(t *testing.T) {
+	fnQuery := func() *SqlQuery {
+		return NewQuery("db_city")
+	}
+
+	testCases := []struct {
+		name        string
+		argStartPos int
+		query       *SqlQuery
+		where       string
+		args        []any
+		hasError    bool
+	}{
+		{
+			name:  "empty",
+			query: fnQuery(),
+			where: "",
+			args:  []any{},
+		},
+		{
+			name:  "compare",
+			query: fnQuery().And("age", SqlGreaterEqual, 3).And("age", SqlLessThan, 9).Or("name", SqlNotEqual, "a"),
+			where: "(\"age\" >= $1 and \"age\" < $2 or \"name\" != $3)",
+			args:  []any{3, 9, "a"},
+		},
+		{
+			name:        "arg start pos",
+			argStartPos: 4,
+			query:       fnQuery().And("age", SqlEqual, 3),
+			where:       "(\"age\" = $5)",
+			args:        []any{3},
+		},
+		{
+			name:  "like",
+			query: fnQuery().And("name", SqlLike, "york"),
+			where: "(\"name\" like '%' || $1 || '%')",
+			args:  []any{"york"},
+		},
+		{
+			name:  "in",
+			query: fnQuery().And("id", SqlIn, []string{"a", "b"}).And("age", SqlNotIn, []int{1}),
+			where: "(\"id\" in ($1,$2) and \"age\" not in ($3))",
+			args:  []any{"a", "b", 1},
+		},
+		{
+			name:  "in empty",
+			query: fnQuery().And("id", SqlIn, []any{}).Or("age", SqlNotIn, []int64{}),
+			where: "(false or true)",
+			args:  []any{},
+		},
+		{
+			name:     "in invalid",
+			query:    fnQuery().And("id", SqlIn, "a"),
+			hasError: true,
+		},
+		{
+			name:  "is null",
+			query: fnQuery().And("geo", SqlIsNull, nil).Or("geo", SqlIsNotNull, true),
+			where: "(\"geo\" is null or \"geo\" is not null)",
+			args:  []any{},
+		},
+		{
+			name:  "between",
+			query: fnQuery().And("age", SqlBetween, []any{1, 5}).And("area", SqlBetween, []float64{1.5, 2}),
+			where: "(\"age\" between $1 and $2 and \"area\" between $3 and $4)",
+			args:  []any{1, 5, 1.5, 2.0},
+		},
+		{
+			name:     "between invalid",
+			query:    fnQuery().And("age", SqlBetween, []any{1}),
+			hasError: true,
+		},
+		{
+			name:  "search",
+			query: fnQuery().And("name", SqlSearch, "york"),
+			where: "(\"name__search\" @@ websearch_to_tsquery('simple', $1))",
+			args:  []any{"york"},
+		},
+		{
+			name:        "child",
+			argStartPos: 1,
+			query: fnQuery().And("age", SqlEqual, 1).OrChild(
+				fnQuery().And("name", SqlEqual, "a").And("status", SqlIn, []string{"b", "c"}),
+			),
+			where: "(\"age\" = $2 or (\"name\" = $3 and \"status\" in ($4,$5)))",
+			args:  []any{1, "a", "b", "c"},
+		},
+		{
+			name:        "nested child",
+			argStartPos: 2,
+			query: fnQuery().AndChild(
+				fnQuery().And("age", SqlEqual, 1).OrChild(fnQuery().And("age", SqlEqual, 2).And("name", SqlEqual, "a")),
+			).And("status", SqlEqual, "b"),
+			where: "((\"age\" = $3 or (\"age\" = $4 and \"name\" = $5)) and \"status\" = $6)",
+			args:  []any{1, 2, "a", "b"},
+		},
+		{
+			name:  "empty child",
+			query: fnQuery().AndChild(fnQuery()).And("age", SqlEqual, 1).OrNot(fnQuery()),
+			where: "(\"age\" = $1)",
+			args:  []any{1},
+		},
+		{
+			name:  "not",
+			query: fnQuery().And("age", SqlEqual, 1).AndNot(fnQuery().And("name", SqlEqual, "a").Or("name", SqlEqual, "b")),
+			where: "(\"age\" = $1 and not (\"name\" = $2 or \"name\" = $3))",
+			args:  []any{1, "a", "b"},
+		},
+		{
+			name:  "first not",
+			query: fnQuery().OrNot(fnQuery().And("geo", SqlIsNull, nil)),
+			where: "(not (\"geo\" is null))",
+			args:  []any{},
+		},
+		{
+			name:     "child invalid",
+			query:    fnQuery().And("", SqlChild, "age = 1"),
+			hasError: true,
+		},
+		{
+			name:     "unsupported operator",
+			query:    fnQuery().And("age", "~", 1),
+			hasError: true,
+		},
+		{
+			name:        "too many args",
+			argStartPos: 1,
+			query:       fnQuery().And("id", SqlIn, make([]any, 65535)),
+			hasError:    true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert := utils.NewAssert(t)
+			where, args, e := NewPGAgent().QueryWhere("db_city", testCase.argStartPos, testCase.query)
+			if testCase.hasError {
+				assert(e).IsNotNil()
+			} else {
+				assert(e).IsNil()
+				assert(where).Equals(testCase.where)
+				assert(args).Equals(testCase.args)
+			}
+		})
+	}
+}
+
+func TestPGSqlAgent_DataSource(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		connect := &DBConnectConfig{User: "postgres", Password: "it's"}
+		assert(NewPGAgent().DataSource(connect, "db", 5432, "")).Equals(
+			`host='db' port=5432 user='postgres' password='it\'s' sslmode=disable`,
+		)
+	})
+
+	t.Run("tls and timeout", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		connect := &DBConnectConfig{
+			User:             "postgres",
+			Password:         "p",
+			StatementTimeout: "1.5s",
+			TLS:              &DBTLSConfig{Mode: "verify-full", RootCert: "/ca.pem"},
+		}
+		assert(NewPGAgent().DataSource(connect, "replica", 5433, "test")).Equals(
+			"host='replica' port=5433 user='postgres' password='p' dbname='test' " +
+				"sslmode='verify-full' sslrootcert='/ca.pem' statement_timeout=1500",
+		)
+	})
+}
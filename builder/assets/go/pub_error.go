@@ -43,6 +43,7 @@ func IsTraceError() bool {
 type Error struct {
 	message string
 	code    int
+	status  int
 	traces  []string
 }
 
@@ -70,6 +71,24 @@ func (p *Error) SetCode(code int) *Error {
 	return p
 }
 
+// Status returns the http status of the response of a public error,
+// 0 is 200
+func (p *Error) Status() int {
+	if p != nil {
+		return p.status
+	}
+
+	return 0
+}
+
+func (p *Error) SetStatus(status int) *Error {
+	if p != nil {
+		p.status = status
+	}
+
+	return p
+}
+
 func (p *Error) AddHeader(header string) error {
 	if p != nil {
 		p.message = header + ": " + p.message
@@ -0,0 +1,136 @@
+package _rt_package_name_
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+)
+
+// AdminStatus is the runtime status reported by the admin server
+type AdminStatus struct {
+	Migrated   bool           `json:"migrated"`
+	TraceError bool           `json:"traceError"`
+	DB         *sql.DBStats   `json:"db,omitempty"`
+	Replicas   []sql.DBStats  `json:"replicas,omitempty"`
+	Cache      *DBCacheConfig `json:"cache,omitempty"`
+	// CacheSeconds are the cache durations of the views by table and view
+	CacheSeconds map[string]map[string]int64 `json:"cacheSeconds,omitempty"`
+}
+
+// AdminToggles are the runtime switches that the admin server can change,
+// the omitted ones are not changed
+type AdminToggles struct {
+	TraceError   *bool `json:"traceError,omitempty"`
+	ExposeErrors *bool `json:"exposeErrors,omitempty"`
+}
+
+func getAdminToggles() *AdminToggles {
+	traceError, exposeErrors := IsTraceError(), IsExposeErrors()
+	return &AdminToggles{TraceError: &traceError, ExposeErrors: &exposeErrors}
+}
+
+func getAdminStatus() *AdminStatus {
+	ret := &AdminStatus{TraceError: IsTraceError()}
+
+	if dbManager := GetDBManager(); dbManager != nil {
+		primary, replicas := dbManager.Stats()
+		ret.Migrated = dbManager.IsMigrated()
+		ret.DB = &primary
+		ret.Replicas = replicas
+		if dbManager.config != nil {
+			ret.Cache = dbManager.config.Cache
+		}
+
+		for tableName, table := range dbManager.Tables() {
+			for viewName, view := range table.Views {
+				if view.CacheSecond <= 0 {
+					continue
+				} else if ret.CacheSeconds == nil {
+					ret.CacheSeconds = map[string]map[string]int64{}
+				}
+				if ret.CacheSeconds[tableName] == nil {
+					ret.CacheSeconds[tableName] = map[string]int64{}
+				}
+				ret.CacheSeconds[tableName][viewName] = view.CacheSecond
+			}
+		}
+	}
+
+	return ret
+}
+
+func writeAdminJson(w http.ResponseWriter, v any) {
+	if content, err := json.Marshal(v); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(content)
+	}
+}
+
+// newAdminHandler is the handler of the admin server, the requests must have
+// the header Authorization: Bearer <token>
+func newAdminHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+
+	// actions are the names of the registered actions
+	mux.HandleFunc("GET /actions", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJson(w, slices.Sorted(maps.Keys(gAPIMap)))
+	})
+
+	// tables are the metas of the migrated tables
+	mux.HandleFunc("GET /tables", func(w http.ResponseWriter, r *http.Request) {
+		if dbManager := GetDBManager(); dbManager != nil {
+			writeAdminJson(w, dbManager.Tables())
+		} else {
+			writeAdminJson(w, map[string]*DBTable{})
+		}
+	})
+
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJson(w, getAdminStatus())
+	})
+
+	// metrics are in the Prometheus text format
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = gMetrics.WriteTo(w)
+	})
+
+	mux.HandleFunc("GET /toggles", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJson(w, getAdminToggles())
+	})
+
+	mux.HandleFunc("POST /toggles", func(w http.ResponseWriter, r *http.Request) {
+		var toggles AdminToggles
+		if err := json.NewDecoder(r.Body).Decode(&toggles); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if toggles.TraceError != nil {
+			SetTraceError(*toggles.TraceError)
+		}
+		if toggles.ExposeErrors != nil {
+			SetExposeErrors(*toggles.ExposeErrors)
+		}
+
+		writeAdminJson(w, getAdminToggles())
+	})
+
+	authorization := []byte("Bearer " + token)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" ||
+			subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), authorization) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
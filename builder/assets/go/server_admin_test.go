@@ -0,0 +1,97 @@
+package _rt_package_name_
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ootiny/capi/utils"
+)
+
+func TestAdminHandler(t *testing.T) {
+	dbManager := &SQLManager{
+		agent:  NewPGAgent(),
+		config: &DBConfig{Cache: &DBCacheConfig{Type: "local", Size: "1g"}},
+		db:     sql.OpenDB(&testSqlConn{}),
+		tableMap: map[string]*DBTable{"city": {
+			Table: "city",
+			Views: map[string]*DBTableView{"Full": {CacheSecond: 60}},
+		}},
+		mutex: &sync.Mutex{},
+	}
+	dbManager.migrated.Store(true)
+
+	gDBManager = dbManager
+	RegisterHandler("test:Action", func(ctx *Context, data []byte) *Return { return &Return{} })
+	defer func() {
+		gDBManager = nil
+		delete(gAPIMap, "test:Action")
+		SetTraceError(true)
+		SetExposeErrors(false)
+	}()
+
+	handler := newAdminHandler("secret")
+	fnServe := func(method string, path string, token string, body string) (int, string) {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code, w.Body.String()
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		code, _ := fnServe(http.MethodGet, "/actions", "", "")
+		assert(code).Equals(http.StatusUnauthorized)
+		code, _ = fnServe(http.MethodGet, "/actions", "wrong", "")
+		assert(code).Equals(http.StatusUnauthorized)
+
+		// an empty token refuses every request
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/actions", nil)
+		r.Header.Set("Authorization", "Bearer ")
+		newAdminHandler("").ServeHTTP(w, r)
+		assert(w.Code).Equals(http.StatusUnauthorized)
+	})
+
+	t.Run("actions", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		code, body := fnServe(http.MethodGet, "/actions", "secret", "")
+		assert(code).Equals(http.StatusOK)
+		assert(strings.Contains(body, `"test:Action"`)).IsTrue()
+	})
+
+	t.Run("tables and status", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		code, body := fnServe(http.MethodGet, "/tables", "secret", "")
+		assert(code).Equals(http.StatusOK)
+		assert(strings.Contains(body, `"city"`)).IsTrue()
+
+		var status AdminStatus
+		code, body = fnServe(http.MethodGet, "/status", "secret", "")
+		assert(code).Equals(http.StatusOK)
+		assert(json.Unmarshal([]byte(body), &status)).IsNil()
+		assert(status.Migrated, status.Cache.Type).Equals(true, "local")
+		assert(status.CacheSeconds["city"]["Full"]).Equals(int64(60))
+	})
+
+	t.Run("toggles", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(fnServe(http.MethodPost, "/toggles", "secret", `{"traceError": false}`)).
+			Equals(http.StatusOK, `{"traceError":false,"exposeErrors":false}`)
+		assert(IsTraceError()).IsFalse()
+		assert(fnServe(http.MethodPost, "/toggles", "secret", `{"exposeErrors": true}`)).
+			Equals(http.StatusOK, `{"traceError":false,"exposeErrors":true}`)
+		assert(IsExposeErrors()).IsTrue()
+		assert(fnServe(http.MethodGet, "/toggles", "secret", "")).
+			Equals(http.StatusOK, `{"traceError":false,"exposeErrors":true}`)
+		code, _ := fnServe(http.MethodPost, "/toggles", "secret", `{`)
+		assert(code).Equals(http.StatusBadRequest)
+	})
+}
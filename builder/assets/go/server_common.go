@@ -2,8 +2,14 @@ package _rt_package_name_
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -12,12 +18,69 @@ const (
 	ErrActionNotImplemented = 2001
 	ErrActionExec           = 2002
 	ErrActionCustom         = 2003
+	ErrActionDecode         = 2004
 	ErrDBCustom             = 3000
+	ErrDBRecordNotFound     = 3001
+	ErrDBConflict           = 3002
+	ErrDBReferenced         = 3003
 )
 
 var gAPIMap = map[string]func(ctx *Context, data []byte) *Return{}
 
+// gPublicErrorCodes are the codes whose messages are returned to the clients,
+// the messages of the other codes are internal, they are only logged
+var gPublicErrorCodes = map[int]bool{
+	ErrActionNotFound:       true,
+	ErrActionNotImplemented: true,
+	ErrActionCustom:         true,
+	ErrActionDecode:         true,
+	ErrDBCustom:             true,
+	ErrDBRecordNotFound:     true,
+	ErrDBConflict:           true,
+	ErrDBReferenced:         true,
+}
+var gPublicErrorCodesMutex = sync.RWMutex{}
+
+// gExposeErrors returns the internal messages to the clients
+var gExposeErrors = atomic.Bool{}
+
+// gInternalErrorMessage is the message of the internal errors that are hidden
+const gInternalErrorMessage = "internal error"
+
+// gDBErrorMessages are the messages of the public db errors returned to the
+// clients, the detailed messages name the tables and the records, they are
+// only logged
+var gDBErrorMessages = map[int]string{
+	ErrDBRecordNotFound: "record not found",
+	ErrDBConflict:       "record has been changed",
+	ErrDBReferenced:     "record is referenced",
+}
+
+// RegisterPublicErrorCode declares a code whose messages are public
+func RegisterPublicErrorCode(code int) {
+	gPublicErrorCodesMutex.Lock()
+	defer gPublicErrorCodesMutex.Unlock()
+	gPublicErrorCodes[code] = true
+}
+
+func IsPublicErrorCode(code int) bool {
+	gPublicErrorCodesMutex.RLock()
+	defer gPublicErrorCodesMutex.RUnlock()
+	return gPublicErrorCodes[code]
+}
+
+// SetExposeErrors sets whether the messages of the internal errors are
+// returned to the clients, they should only be exposed in development
+func SetExposeErrors(expose bool) {
+	gExposeErrors.Store(expose)
+}
+
+func IsExposeErrors() bool {
+	return gExposeErrors.Load()
+}
+
 type Request interface {
+	Method() string
 	Action() string
 	Data() []byte
 	Cookie(name string) (*http.Cookie, error)
@@ -30,16 +93,26 @@ type Response interface {
 	WriteJson(data []byte) (int, error)
 }
 
+// NewContext is the context of a request, the request id is the header
+// X-Request-Id of the request if it is valid, otherwise a random one
 func NewContext(request Request, response Response) *Context {
+	requestID := newRequestID(request.Header("X-Request-Id"))
 	return &Context{
-		request:  request,
-		response: response,
+		request:   request,
+		response:  response,
+		requestID: requestID,
+		logger:    Logger().With("request_id", requestID),
 	}
 }
 
 type Context struct {
-	request  Request
-	response Response
+	request   Request
+	response  Response
+	principal string
+	tx        *SQLTransaction
+	span      *Span
+	requestID string
+	logger    *slog.Logger
 }
 
 func (p *Context) Request() Request {
@@ -50,7 +123,66 @@ func (p *Context) Response() Response {
 	return p.response
 }
 
+// SetPrincipal sets the authenticated user of the request,
+// it is recorded by tables with the audit option.
+func (p *Context) SetPrincipal(principal string) {
+	p.principal = principal
+	if p.tx != nil {
+		p.tx.SetPrincipal(principal)
+	}
+}
+
+func (p *Context) Principal() string {
+	return p.principal
+}
+
+// Tx returns the db transaction of the request, it is committed
+// when the action returns without error, otherwise rolled back.
+func (p *Context) Tx() *SQLTransaction {
+	if p.tx == nil {
+		p.tx = GetDBManager().NewTransaction(SqlLevelReadCommitted, false)
+		p.tx.SetPrincipal(p.principal)
+		p.tx.SetSpan(p.span)
+	}
+
+	return p.tx
+}
+
+// RequestID returns the id of the request, it is sent in the header
+// X-Request-Id of the response
+func (p *Context) RequestID() string {
+	return p.requestID
+}
+
+// Logger returns the logger of the request with the request id and action
+func (p *Context) Logger() *slog.Logger {
+	return p.logger
+}
+
+// Span returns the span of the action, it is nil if the tracing is disabled
+func (p *Context) Span() *Span {
+	return p.span
+}
+
+// Unmarshal decodes the data of the action in a span
+func (p *Context) Unmarshal(data []byte, v any) *Error {
+	span := p.span.StartChild("decode", SpanKindInternal)
+	defer span.Finish()
+
+	err := JsonUnmarshal(data, v)
+	if err != nil {
+		span.SetError(err)
+	}
+	return err
+}
+
 func (p *Context) Close(dbCommit bool) *Error {
+	if p.tx != nil {
+		tx := p.tx
+		p.tx = nil
+		return WrapError(tx.Close(dbCommit))
+	}
+
 	return nil
 }
 
@@ -58,6 +190,10 @@ type Return struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	Data    any    `json:"data"`
+	// RequestID is the request id of an internal error to find its log
+	RequestID string `json:"requestId,omitempty"`
+	// Err is the error of the code, its traces are logged
+	Err *Error `json:"-"`
 }
 
 func RegisterHandler(action string, handler func(ctx *Context, data []byte) *Return) {
@@ -69,8 +205,36 @@ func JsonUnmarshal(data []byte, v any) *Error {
 }
 
 func evalAction(w Response, r Request) (ret *Return) {
+	start := time.Now()
 	ctx := NewContext(r, w)
 	action := ctx.Request().Action()
+	ctx.logger = ctx.logger.With("action", action)
+	w.SetHeader("X-Request-Id", ctx.requestID)
+
+	// the span of the action continues the trace of the request
+	ctx.span = StartSpan("action "+action, SpanKindServer, r.Header("traceparent"))
+	ctx.span.SetAttribute("capi.action", action)
+	ctx.span.SetAttribute("capi.request_id", ctx.requestID)
+	defer func() {
+		ctx.span.SetAttribute("capi.code", ret.Code)
+		if ret.Code != 0 {
+			ctx.span.SetError(errors.New(ret.Message))
+		}
+		ctx.span.Finish()
+		logAction(ctx, ret, time.Since(start))
+
+		// the internal errors are found by the request id in the log
+		if ret.Code != 0 && !IsPublicErrorCode(ret.Code) {
+			if IsExposeErrors() {
+				ret = &Return{Code: ret.Code, Message: ret.Message, RequestID: ctx.requestID}
+			} else {
+				ret = &Return{Code: ret.Code, Message: gInternalErrorMessage, RequestID: ctx.requestID}
+			}
+		} else if message, ok := gDBErrorMessages[ret.Code]; ok && !IsExposeErrors() {
+			ret = &Return{Code: ret.Code, Message: message, Err: ret.Err}
+		}
+	}()
+
 	data := ctx.Request().Data()
 	fn, ok := gAPIMap[action]
 
@@ -88,11 +252,17 @@ func evalAction(w Response, r Request) (ret *Return) {
 				Code:    ErrActionExec,
 				Message: fmt.Sprintf("action exec error: %s", reason),
 			}
+			ctx.logger.Error(
+				"action panic",
+				"reason", fmt.Sprint(reason),
+				"stack", string(debug.Stack()),
+			)
 		} else if ret != nil {
 			if closeErr := ctx.Close(ret.Code == 0); closeErr != nil {
 				ret = &Return{
 					Code:    ErrInternal,
 					Message: fmt.Sprintf("close error: %s", closeErr.Error()),
+					Err:     closeErr,
 				}
 			}
 		} else {
@@ -107,21 +277,35 @@ func evalAction(w Response, r Request) (ret *Return) {
 	return fn(ctx, data)
 }
 
-func apiHandler(cors bool, w Response, r Request) {
-	// Set CORS headers
-	if cors {
-		w.SetHeader("Access-Control-Allow-Origin", "*")
+// logAction writes the access log of the action, the errors that are not
+// public are logged with their traces
+func logAction(ctx *Context, ret *Return, duration time.Duration) {
+	attrs := []any{"code", ret.Code, "duration", duration}
 
-		// Handle preflight OPTIONS request only when CORS is enabled
-		if r.Header("Access-Control-Request-Method") == "OPTIONS" {
-			w.SetHeader("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.SetHeader("Access-Control-Allow-Headers", "Content-Type, Authorization")
-			w.WriteHeader(http.StatusNoContent)
-			return
+	if ret.Code == 0 {
+		ctx.logger.Info("action", attrs...)
+	} else if IsPublicErrorCode(ret.Code) {
+		ctx.logger.Warn("action", append(attrs, "message", ret.Message)...)
+	} else {
+		attrs = append(attrs, "message", ret.Message)
+		if ret.Err != nil {
+			attrs = append(attrs, "trace", DebugError(ret.Err))
 		}
+		ctx.logger.Error("action", attrs...)
 	}
+}
 
+// apiHandler evaluates the action of a request that has passed the CORS
+// policy of the engine
+func apiHandler(w Response, r Request) {
+	start := time.Now()
 	ret := evalAction(w, r)
+	if _, ok := gAPIMap[r.Action()]; ok {
+		gMetrics.observeAction(r.Action(), ret.Code, time.Since(start))
+	} else {
+		gMetrics.observeAction(gMetricsUnknownAction, ret.Code, time.Since(start))
+	}
+
 	if ret.Code == 0 {
 		ret.Code = http.StatusOK
 	}
@@ -131,6 +315,10 @@ func apiHandler(cors bool, w Response, r Request) {
 	if retBytes, err := json.Marshal(ret); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		_, _ = w.WriteJson([]byte(`{"code":500,"message":"Internal Server Error"}`))
+	} else if status := ret.Err.Status(); status != 0 && IsPublicErrorCode(ret.Code) {
+		// the declared errors have their http status
+		w.WriteHeader(status)
+		_, _ = w.WriteJson(retBytes)
 	} else {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.WriteJson(retBytes)
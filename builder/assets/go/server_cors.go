@@ -0,0 +1,80 @@
+package _rt_package_name_
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// corsAllowOrigin returns the Access-Control-Allow-Origin of origin, it is
+// empty if the origin is not allowed
+func corsAllowOrigin(cors *ServerCORSConfig, origin string) string {
+	for _, pattern := range cors.Origins {
+		if pattern == "*" {
+			return "*"
+		} else if matched, _ := path.Match(pattern, origin); matched {
+			return origin
+		}
+	}
+
+	return ""
+}
+
+// corsHandler sets the CORS headers of the response by the policy, it
+// returns true if the request is a preflight and it has been responded
+func corsHandler(cors *ServerCORSConfig, w Response, r Request) bool {
+	if cors == nil {
+		return false
+	}
+
+	origin := r.Header("Origin")
+	allowOrigin := ""
+	if origin != "" {
+		allowOrigin = corsAllowOrigin(cors, origin)
+	}
+
+	// the response depends on the origin unless any origin is allowed
+	if allowOrigin != "*" {
+		w.SetHeader("Vary", "Origin")
+	}
+
+	isPreflight := r.Method() == http.MethodOptions &&
+		r.Header("Access-Control-Request-Method") != ""
+
+	if allowOrigin == "" {
+		if isPreflight {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		return isPreflight
+	}
+
+	w.SetHeader("Access-Control-Allow-Origin", allowOrigin)
+	if cors.AllowCredentials {
+		w.SetHeader("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !isPreflight {
+		if len(cors.ExposeHeaders) > 0 {
+			w.SetHeader("Access-Control-Expose-Headers", strings.Join(cors.ExposeHeaders, ", "))
+		}
+		return false
+	}
+
+	requestMethod := strings.ToUpper(r.Header("Access-Control-Request-Method"))
+	if !slices.Contains(cors.Methods, requestMethod) {
+		w.WriteHeader(http.StatusForbidden)
+		return true
+	}
+
+	w.SetHeader("Access-Control-Allow-Methods", strings.Join(cors.Methods, ", "))
+	if len(cors.Headers) > 0 {
+		w.SetHeader("Access-Control-Allow-Headers", strings.Join(cors.Headers, ", "))
+	}
+	if maxAge := configDuration(cors.MaxAge); maxAge > 0 {
+		w.SetHeader("Access-Control-Max-Age", strconv.FormatInt(int64(maxAge.Seconds()), 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
@@ -0,0 +1,103 @@
+package _rt_package_name_
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ootiny/capi/utils"
+)
+
+type testRequest struct {
+	method  string
+	headers map[string]string
+	action  string
+	data    []byte
+}
+
+func (p *testRequest) Method() string                           { return p.method }
+func (p *testRequest) Action() string                           { return p.action }
+func (p *testRequest) Data() []byte                             { return p.data }
+func (p *testRequest) Cookie(name string) (*http.Cookie, error) { return nil, http.ErrNoCookie }
+func (p *testRequest) Header(name string) string                { return p.headers[name] }
+
+type testResponse struct {
+	code    int
+	headers map[string]string
+	body    []byte
+}
+
+func (p *testResponse) SetHeader(name string, value string) { p.headers[name] = value }
+func (p *testResponse) WriteHeader(code int)                { p.code = code }
+func (p *testResponse) WriteJson(data []byte) (int, error) {
+	p.body = append(p.body, data...)
+	return len(data), nil
+}
+
+func TestCorsHandler(t *testing.T) {
+	cors := &ServerCORSConfig{
+		Origins:          []string{"https://app.com", "https://*.app.com"},
+		AllowCredentials: true,
+		Methods:          []string{"GET", "POST"},
+		Headers:          []string{"Content-Type", "Authorization"},
+		ExposeHeaders:    []string{"X-Request-Id"},
+		MaxAge:           "10m",
+	}
+
+	fnHandle := func(cors *ServerCORSConfig, method string, headers map[string]string) (bool, *testResponse) {
+		w := &testResponse{headers: map[string]string{}}
+		return corsHandler(cors, w, &testRequest{method: method, headers: headers}), w
+	}
+
+	t.Run("preflight", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		handled, w := fnHandle(cors, "OPTIONS", map[string]string{
+			"Origin":                        "https://www.app.com",
+			"Access-Control-Request-Method": "POST",
+		})
+		assert(handled, w.code).Equals(true, http.StatusNoContent)
+		assert(w.headers["Access-Control-Allow-Origin"]).Equals("https://www.app.com")
+		assert(w.headers["Access-Control-Allow-Credentials"]).Equals("true")
+		assert(w.headers["Access-Control-Allow-Methods"]).Equals("GET, POST")
+		assert(w.headers["Access-Control-Allow-Headers"]).Equals("Content-Type, Authorization")
+		assert(w.headers["Access-Control-Max-Age"], w.headers["Vary"]).Equals("600", "Origin")
+	})
+
+	t.Run("preflight refused", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		handled, w := fnHandle(cors, "OPTIONS", map[string]string{
+			"Origin":                        "https://evil.com",
+			"Access-Control-Request-Method": "POST",
+		})
+		assert(handled, w.code).Equals(true, http.StatusForbidden)
+		assert(w.headers["Access-Control-Allow-Origin"]).Equals("")
+
+		handled, w = fnHandle(cors, "OPTIONS", map[string]string{
+			"Origin":                        "https://app.com",
+			"Access-Control-Request-Method": "DELETE",
+		})
+		assert(handled, w.code).Equals(true, http.StatusForbidden)
+	})
+
+	t.Run("request", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		handled, w := fnHandle(cors, "POST", map[string]string{"Origin": "https://app.com"})
+		assert(handled, w.code).Equals(false, 0)
+		assert(w.headers["Access-Control-Allow-Origin"]).Equals("https://app.com")
+		assert(w.headers["Access-Control-Expose-Headers"]).Equals("X-Request-Id")
+
+		// OPTIONS without Access-Control-Request-Method is not a preflight
+		handled, w = fnHandle(cors, "OPTIONS", map[string]string{"Origin": "https://evil.com"})
+		assert(handled, w.code).Equals(false, 0)
+		assert(w.headers["Access-Control-Allow-Origin"]).Equals("")
+	})
+
+	t.Run("any origin", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		handled, w := fnHandle(&ServerCORSConfig{Origins: []string{"*"}}, "GET", map[string]string{"Origin": "https://a.com"})
+		assert(handled).IsFalse()
+		assert(w.headers["Access-Control-Allow-Origin"], w.headers["Vary"]).Equals("*", "")
+
+		handled, w = fnHandle(nil, "OPTIONS", map[string]string{"Origin": "https://a.com"})
+		assert(handled, len(w.headers)).Equals(false, 0)
+	})
+}
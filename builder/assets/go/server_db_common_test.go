@@ -0,0 +1,165 @@
+package _rt_package_name_
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ootiny/capi/utils"
+)
+
+func TestSqlEncodeToDB(t *testing.T) {
+	t.Run("time", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		v, e := SqlEncodeToDB("Time", "2024-05-06T07:08:09+08:00")
+		assert(e).IsNil()
+		assert(v).Equals(time.Date(2024, 5, 5, 23, 8, 9, 0, time.UTC))
+		_, e = SqlEncodeToDB("Time", "2024-05-06")
+		assert(e).IsNotNil()
+	})
+
+	t.Run("date", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		v, e := SqlEncodeToDB("Date", "2024-05-06")
+		assert(v, e).Equals("2024-05-06", nil)
+		v, e = SqlEncodeToDB("Date", time.Date(2024, 5, 6, 23, 0, 0, 0, time.UTC))
+		assert(v, e).Equals("2024-05-06", nil)
+		_, e = SqlEncodeToDB("Date", "2024-05-06T00:00:00Z")
+		assert(e).IsNotNil()
+	})
+
+	t.Run("decimal", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		v, e := SqlEncodeToDB("Decimal", "-12.50")
+		assert(v, e).Equals("-12.50", nil)
+		v, e = SqlEncodeToDB("Decimal", int64(3))
+		assert(v, e).Equals("3", nil)
+		_, e = SqlEncodeToDB("Decimal", "12a")
+		assert(e).IsNotNil()
+	})
+
+	t.Run("uuid", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		v, e := SqlEncodeToDB("UUID", "6BA7B810-9DAD-11D1-80B4-00C04FD430C8")
+		assert(v, e).Equals("6ba7b810-9dad-11d1-80b4-00c04fd430c8", nil)
+		_, e = SqlEncodeToDB("UUID", "not-a-uuid")
+		assert(e).IsNotNil()
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		v, e := SqlEncodeToDB("Bytes", "aGVsbG8=")
+		assert(v, e).Equals([]byte("hello"), nil)
+		_, e = SqlEncodeToDB("Bytes", "!!")
+		assert(e).IsNotNil()
+	})
+
+	t.Run("geo point", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(SqlEncodeToDB("GeoPoint", NewGeoPoint(40.7128, -74.006))).Equals("(-74.006,40.7128)", nil)
+		assert(SqlEncodeToDB("GeoPoint", map[string]any{"latitude": 1.5, "longitude": 2})).Equals("(2,1.5)", nil)
+		assert(SqlEncodeToDB("GeoPoint", nil)).Equals("(0,0)", nil)
+		_, e := SqlEncodeToDB("GeoPoint", NewGeoPoint(91, 0))
+		assert(e).IsNotNil()
+		_, e = SqlEncodeToDB("GeoPoint", "(1,2)")
+		assert(e).IsNotNil()
+	})
+
+	t.Run("list and map", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(SqlEncodeToDB("List<String>", nil)).Equals("[]", nil)
+		assert(SqlEncodeToDB("LKMap", nil)).Equals("{}", nil)
+		assert(SqlEncodeToDB("List<String>", []string{"a"})).Equals(`["a"]`, nil)
+	})
+}
+
+func TestSqlDecodeFromDB(t *testing.T) {
+	t.Run("date", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		v, e := SqlDecodeFromDB("Date", time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC))
+		assert(e).IsNil()
+		assert(v.(Date).String()).Equals("2024-05-06")
+	})
+
+	t.Run("decimal and uuid", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(SqlDecodeFromDB("Decimal", []byte("12.50"))).Equals("12.50", nil)
+		assert(SqlDecodeFromDB("UUID", []byte("6ba7b810-9dad-11d1-80b4-00c04fd430c8"))).
+			Equals("6ba7b810-9dad-11d1-80b4-00c04fd430c8", nil)
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(SqlDecodeFromDB("Bytes", []byte("hi"))).Equals([]byte("hi"), nil)
+		assert(SqlDecodeFromDB("Bytes", nil)).Equals([]byte{}, nil)
+	})
+
+	t.Run("geo point", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(SqlDecodeFromDB("GeoPoint", []byte("(-74.006,40.7128)"))).Equals(NewGeoPoint(40.7128, -74.006), nil)
+		_, e := SqlDecodeFromDB("GeoPoint", []byte("(1)"))
+		assert(e).IsNotNil()
+	})
+}
+
+func TestSqlCursor(t *testing.T) {
+	table := &DBTable{
+		Table: "db_city",
+		Columns: map[string]*DBTableColumn{
+			"id":         {Type: "PK"},
+			"level":      {Type: "Int64"},
+			"created_at": {Type: "Time"},
+		},
+	}
+	orders := []*SqlOrderBy{{"level", false, ""}, {"created_at", true, ""}, {"id", true, ""}}
+	createdAt := time.Date(2024, 5, 6, 7, 8, 9, 123, time.UTC)
+
+	t.Run("round trip", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		cursor, e := sqlEncodeCursor(orders, Record{
+			"id": "abc", "level": int64(1 << 60), "created_at": createdAt,
+		})
+		assert(e).IsNil()
+		values, e := sqlDecodeCursor(table, orders, cursor)
+		assert(e).IsNil()
+		assert(values).Equals([]any{int64(1 << 60), createdAt, "abc"})
+	})
+
+	t.Run("orders changed", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		cursor, e := sqlEncodeCursor(orders, Record{
+			"id": "abc", "level": int64(1), "created_at": createdAt,
+		})
+		assert(e).IsNil()
+		_, e = sqlDecodeCursor(table, orders[1:], cursor)
+		assert(e).IsNotNil()
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		_, e := sqlDecodeCursor(table, orders, "not a cursor")
+		assert(e).IsNotNil()
+	})
+}
+
+func TestSqlQuery_Check(t *testing.T) {
+	table := &DBTable{
+		Table: "db_city",
+		Columns: map[string]*DBTableColumn{
+			"age":    {Type: "Int64", AggregateMap: map[string]bool{"sum": true}},
+			"status": {Type: "Enum", AggregateMap: map[string]bool{"groupBy": true}},
+			"name":   {Type: "String"},
+		},
+	}
+
+	t.Run("allowed aggregates", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewQuery("db_city").Count().Sum("age").GroupBy("status").Check(table, true)).IsNil()
+	})
+
+	t.Run("not allowed aggregates", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(NewQuery("db_city").Avg("age").Check(table, true)).IsNotNil()
+		assert(NewQuery("db_city").Count().GroupBy("name").Check(table, true)).IsNotNil()
+		assert(NewQuery("db_city").Max("unknown").Check(table, true)).IsNotNil()
+	})
+}
@@ -1,27 +1,38 @@
 package _rt_package_name_
 
 import (
+	"context"
 	"database/sql"
-	"embed"
 	"fmt"
 	"io/fs"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+var gDBManager *SQLManager
+
+func GetDBManager() *SQLManager {
+	return gDBManager
+}
+
 type SQLManager struct {
 	agent    ISqlAgent
 	config   *DBConfig
-	dbAssets *embed.FS
+	dbAssets fs.FS
 	db       *sql.DB
+	replicas []*sql.DB
+	replicaN atomic.Uint64
 	tableMap map[string]*DBTable
 	mutex    *sync.Mutex
+	migrated atomic.Bool
 }
 
-func NewSQLManager(dbAssets *embed.FS) (*SQLManager, error) {
+func NewSQLManager(dbAssets fs.FS) (*SQLManager, error) {
 	if dbAssets == nil {
 		return nil, fmt.Errorf("dbAssets is nil")
-	} else if configContent, err := dbAssets.ReadFile("db/config.json"); err != nil {
+	} else if configContent, err := fs.ReadFile(dbAssets, "db/config.json"); err != nil {
 		return nil, err
 	} else if config, err := LoadDBConfig(string(configContent)); err != nil {
 		return nil, err
@@ -35,33 +46,70 @@ func NewSQLManager(dbAssets *embed.FS) (*SQLManager, error) {
 			return nil, fmt.Errorf("invalid driver name %s", config.Connect.Driver)
 		}
 
-		if db, err := sql.Open(
-			config.Connect.Driver,
-			agent.DataSource(
-				config.Connect.Host,
-				config.Connect.Port,
-				config.Connect.User,
-				config.Connect.Password,
-				config.Connect.DBName,
-			),
-		); err != nil {
+		ret := &SQLManager{
+			agent:    agent,
+			config:   config,
+			dbAssets: dbAssets,
+			tableMap: make(map[string]*DBTable),
+			mutex:    &sync.Mutex{},
+		}
+
+		if db, err := ret.openDB(config.Connect.Host, config.Connect.Port); err != nil {
 			return nil, err
 		} else {
-			return &SQLManager{
-				agent:    agent,
-				config:   config,
-				dbAssets: dbAssets,
-				db:       db,
-				tableMap: make(map[string]*DBTable),
-				mutex:    &sync.Mutex{},
-			}, nil
+			ret.db = db
+		}
+
+		for _, replica := range config.Replicas {
+			if db, err := ret.openDB(replica.Host, replica.Port); err != nil {
+				_ = ret.Close()
+				return nil, err
+			} else {
+				ret.replicas = append(ret.replicas, db)
+			}
+		}
+
+		return ret, nil
+	}
+}
+
+// openDB opens the database of the server at host and port with the pool config
+func (p *SQLManager) openDB(host string, port uint16) (*sql.DB, error) {
+	connect := p.config.Connect
+	db, err := sql.Open(connect.Driver, p.agent.DataSource(connect, host, port, connect.DBName))
+	if err != nil {
+		return nil, err
+	}
+
+	// the durations are checked by LoadDBConfig
+	if pool := p.config.Pool; pool != nil {
+		db.SetMaxOpenConns(pool.MaxOpenConns)
+		if pool.MaxIdleConns > 0 {
+			db.SetMaxIdleConns(pool.MaxIdleConns)
+		}
+		if d, _ := time.ParseDuration(pool.ConnMaxLifetime); d > 0 {
+			db.SetConnMaxLifetime(d)
+		}
+		if d, _ := time.ParseDuration(pool.ConnMaxIdleTime); d > 0 {
+			db.SetConnMaxIdleTime(d)
 		}
 	}
+
+	return db, nil
+}
+
+// readDB returns the database of the read-only transactions, the replicas
+// are used in turn, it is the primary if there is no replica
+func (p *SQLManager) readDB() *sql.DB {
+	if len(p.replicas) == 0 {
+		return p.db
+	}
+	return p.replicas[(p.replicaN.Add(1)-1)%uint64(len(p.replicas))]
 }
 
 func (p *SQLManager) CreateDatabaseIfNotExist() (err error) {
 	connect := p.config.Connect
-	dataSource := p.agent.DataSource(connect.Host, connect.Port, connect.User, connect.Password, "")
+	dataSource := p.agent.DataSource(connect, connect.Host, connect.Port, "")
 
 	if db, err := sql.Open(connect.Driver, dataSource); err != nil {
 		return err
@@ -72,7 +120,7 @@ func (p *SQLManager) CreateDatabaseIfNotExist() (err error) {
 
 		var exists bool
 
-		if err = db.QueryRow(p.agent.HasDatabase(connect.DBName)).Scan(&exists); err != nil {
+		if err = db.QueryRow(p.agent.HasDatabase(), connect.DBName).Scan(&exists); err != nil {
 			return WrapError(err)
 		}
 
@@ -149,7 +197,7 @@ func (p *SQLManager) Open() error {
 			continue
 		}
 
-		if fContent, e := p.dbAssets.ReadFile(file); e != nil {
+		if fContent, e := fs.ReadFile(p.dbAssets, file); e != nil {
 			_ = tx.Close(false)
 			return WrapError(e)
 		} else if table, e := LoadDBTable(string(fContent)); e != nil {
@@ -169,8 +217,29 @@ func (p *SQLManager) Open() error {
 		}
 	}
 
+	if e := tx.ExecPending(); e != nil {
+		_ = tx.Close(false)
+		return WrapError(e)
+	}
+
 	// check link
 	for tableName, tableConfig := range p.tableMap {
+		for columnName, columnConfig := range tableConfig.Columns {
+			if columnConfig.LinkTable == "" {
+				continue
+			}
+
+			if _, ok := p.tableMap[columnConfig.LinkTable]; !ok {
+				_ = tx.Close(false)
+				return Errorf(
+					"%s: Columns.%s invalid link table: %s",
+					tableName,
+					columnName,
+					columnConfig.LinkTable,
+				)
+			}
+		}
+
 		for viewName, viewConfig := range tableConfig.Views {
 			for _, columnConfig := range viewConfig.Columns {
 				if columnConfig.LinkTable == "" {
@@ -205,16 +274,72 @@ func (p *SQLManager) Open() error {
 	if e := tx.Close(true); e != nil {
 		return WrapError(e)
 	} else {
+		p.migrated.Store(true)
 		return nil
 	}
 }
 
+// IsMigrated returns whether the tables have been migrated by Open
+func (p *SQLManager) IsMigrated() bool {
+	return p.migrated.Load()
+}
+
+// Tables returns the migrated tables, it is empty before the migration
+func (p *SQLManager) Tables() map[string]*DBTable {
+	if !p.IsMigrated() {
+		return map[string]*DBTable{}
+	}
+	return p.tableMap
+}
+
+// Stats returns the connection pool statistics of the primary and the replicas
+func (p *SQLManager) Stats() (sql.DBStats, []sql.DBStats) {
+	primary := sql.DBStats{}
+	if p.db != nil {
+		primary = p.db.Stats()
+	}
+
+	replicas := make([]sql.DBStats, len(p.replicas))
+	for i, replica := range p.replicas {
+		replicas[i] = replica.Stats()
+	}
+
+	return primary, replicas
+}
+
+// Ping checks the connections of the primary and the replicas
+func (p *SQLManager) Ping(ctx context.Context) error {
+	if p.db == nil {
+		return Errorf("db-manager: closed")
+	} else if e := p.db.PingContext(ctx); e != nil {
+		return WrapError(e)
+	}
+
+	for i, replica := range p.replicas {
+		if e := replica.PingContext(ctx); e != nil {
+			return WrapError(e).AddHeaderf("replicas[%d]", i)
+		}
+	}
+
+	return nil
+}
+
 func (p *SQLManager) Close() error {
+	ret := error(nil)
+
+	for _, replica := range p.replicas {
+		if e := replica.Close(); e != nil && ret == nil {
+			ret = WrapError(e)
+		}
+	}
+	p.replicas = nil
+
 	if p.db != nil {
-		ret := WrapError(p.db.Close())
+		if e := p.db.Close(); e != nil && ret == nil {
+			ret = WrapError(e)
+		}
 		p.db = nil
-		return ret
-	} else {
-		return nil
 	}
+
+	return ret
 }
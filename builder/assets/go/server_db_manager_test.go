@@ -0,0 +1,338 @@
+package _rt_package_name_
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ootiny/capi/utils"
+)
+
+// testSqlExec is a statement executed by the test driver
+type testSqlExec struct {
+	query string
+	args  []driver.Value
+}
+
+// testSqlConn is a driver connection that records the executed statements,
+// the queries return no rows
+type testSqlConn struct {
+	execs []testSqlExec
+	// rows are the rows of the queries
+	rows [][]driver.Value
+}
+
+func (p *testSqlConn) Connect(context.Context) (driver.Conn, error) { return p, nil }
+func (p *testSqlConn) Driver() driver.Driver                        { return nil }
+func (p *testSqlConn) Prepare(query string) (driver.Stmt, error)    { return &testSqlStmt{p, query}, nil }
+func (p *testSqlConn) Close() error                                 { return nil }
+func (p *testSqlConn) Begin() (driver.Tx, error)                    { return p, nil }
+func (p *testSqlConn) Commit() error                                { return nil }
+func (p *testSqlConn) Rollback() error                              { return nil }
+
+func (p *testSqlConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return p, nil
+}
+
+type testSqlStmt struct {
+	conn  *testSqlConn
+	query string
+}
+
+func (p *testSqlStmt) Close() error  { return nil }
+func (p *testSqlStmt) NumInput() int { return -1 }
+
+func (p *testSqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	p.conn.execs = append(p.conn.execs, testSqlExec{p.query, args})
+	return driver.RowsAffected(0), nil
+}
+
+func (p *testSqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	p.conn.execs = append(p.conn.execs, testSqlExec{p.query, args})
+	return &testSqlRows{rows: p.conn.rows}, nil
+}
+
+type testSqlRows struct {
+	rows [][]driver.Value
+}
+
+func (p *testSqlRows) Columns() []string {
+	if len(p.rows) > 0 {
+		return make([]string, len(p.rows[0]))
+	}
+	return []string{"meta"}
+}
+
+func (p *testSqlRows) Close() error { return nil }
+
+func (p *testSqlRows) Next(dest []driver.Value) error {
+	if len(p.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, p.rows[0])
+	p.rows = p.rows[1:]
+	return nil
+}
+
+// testSqlSkeleton replaces the quoted identifiers and literals of a statement
+// by I and L, the statements that only differ in the quoted values have the
+// same skeleton. It fails if a quote is not closed.
+func testSqlSkeleton(query string) (string, bool) {
+	sb := strings.Builder{}
+	for i := 0; i < len(query); i++ {
+		quote := query[i]
+		if quote != '\'' && quote != '"' {
+			sb.WriteByte(quote)
+			continue
+		}
+
+		closed := false
+		for i++; i < len(query); i++ {
+			if query[i] != quote {
+				continue
+			} else if i+1 < len(query) && query[i+1] == quote {
+				i++
+			} else {
+				closed = true
+				break
+			}
+		}
+
+		if !closed {
+			return "", false
+		} else if quote == '"' {
+			sb.WriteString("I")
+		} else {
+			sb.WriteString("L")
+		}
+	}
+	return sb.String(), true
+}
+
+// testOpenSQLManager opens a manager of a table with an enum column by the
+// test driver and returns the meta and the executed statements
+func testOpenSQLManager(
+	tableName string,
+	columnName string,
+	description string,
+	enumValue string,
+) (string, []testSqlExec, error) {
+	content, _ := json.Marshal(map[string]any{
+		"version":     "config.db.v1",
+		"table":       tableName,
+		"description": description,
+		"columns": map[string]any{
+			"id":       map[string]any{"type": "PK"},
+			columnName: map[string]any{"type": "Enum", "enum": []string{enumValue}},
+		},
+		"views": map[string]any{},
+	})
+
+	conn := &testSqlConn{}
+	manager := &SQLManager{
+		agent:    NewPGAgent(),
+		dbAssets: fstest.MapFS{"db/tables/table.json": {Data: content}},
+		db:       sql.OpenDB(conn),
+		tableMap: map[string]*DBTable{},
+		mutex:    &sync.Mutex{},
+	}
+
+	e := manager.Open()
+	return string(content), conn.execs, e
+}
+
+func FuzzSQLManager_Open(f *testing.F) {
+	f.Add("city", "status", "a city", "open")
+	f.Add("city", "status", "it's a city", "o'clock")
+	f.Add("city", "status", "'); DROP TABLE city; --", "x'); DROP TABLE city; --")
+	f.Add("ci\"ty", "st'atus", "\\'", "\"")
+	f.Add("city; DROP TABLE city", "status", "", "")
+
+	_, expectExecs, e := testOpenSQLManager("city", "status", "a city", "open")
+	if e != nil {
+		f.Fatal(e)
+	}
+
+	f.Fuzz(func(t *testing.T, tableName string, columnName string, description string, enumValue string) {
+		assert := utils.NewAssert(t)
+		meta, execs, e := testOpenSQLManager(tableName, columnName, description, enumValue)
+
+		if columnName == "id" {
+			t.Skip("the id column is the primary key")
+		} else if !gSqlServiceNameRegex.MatchString(tableName) || !gSqlColumnNameRegex.MatchString(columnName) {
+			// the invalid names are refused before any statement
+			assert(e).IsNotNil()
+			assert(len(execs)).Equals(0)
+			return
+		}
+
+		assert(e).IsNil()
+		assert(len(execs)).Equals(len(expectExecs))
+
+		// the values only change the quoted parts of the statements
+		for i, exec := range execs {
+			skeleton, ok := testSqlSkeleton(exec.query)
+			assert(ok).IsTrue()
+			expectSkeleton, _ := testSqlSkeleton(expectExecs[i].query)
+			assert(skeleton).Equals(expectSkeleton)
+		}
+
+		// the meta is an arg
+		last := execs[len(execs)-1]
+		assert(len(last.args)).Equals(2)
+		assert(last.args[1]).Equals(meta)
+	})
+}
+
+func TestLoadDBConfig(t *testing.T) {
+	fnConfig := func(connect string, extra string) string {
+		return `{"connect": {"driver": "postgres", "host": "db", "port": 5432` + connect + `}, "cache": {}` + extra + `}`
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		config, e := LoadDBConfig(fnConfig(
+			`, "statementTimeout": "30s", "tls": {"mode": "require"}`,
+			`, "pool": {"maxOpenConns": 10, "connMaxLifetime": "30m"}, "replicas": [{"host": "r1", "port": 5432}]`,
+		))
+		assert(e).IsNil()
+		assert(config.Pool.MaxOpenConns, config.Replicas[0].Host).Equals(10, "r1")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		_, e := LoadDBConfig(fnConfig(`, "tls": {"mode": "on"}`, ""))
+		assert(e).IsNotNil()
+		_, e = LoadDBConfig(fnConfig(`, "statementTimeout": "30"`, ""))
+		assert(e).IsNotNil()
+		_, e = LoadDBConfig(fnConfig("", `, "pool": {"connMaxIdleTime": "-1s"}`))
+		assert(e).IsNotNil()
+		_, e = LoadDBConfig(fnConfig("", `, "replicas": [{"host": "r1"}]`))
+		assert(e).IsNotNil()
+	})
+
+	t.Run("references", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		passwordFile := filepath.Join(t.TempDir(), "password")
+		assert(os.WriteFile(passwordFile, []byte("secret\n"), 0o600)).IsNil()
+		t.Setenv("TEST_DB_USER", "admin")
+		t.Setenv("TEST_DB_TIMEOUT", "10s")
+
+		config, e := LoadDBConfig(fnConfig(
+			`, "user": "${env:TEST_DB_USER}", "password": "${file:`+passwordFile+`}", "statementTimeout": "${env:TEST_DB_TIMEOUT}"`,
+			"",
+		))
+		assert(e).IsNil()
+		assert(config.Connect.User, config.Connect.Password).Equals("admin", "secret")
+		assert(config.Connect.StatementTimeout).Equals("10s")
+
+		_, e = LoadDBConfig(fnConfig(`, "password": "${env:TEST_DB_NOT_SET}"`, ""))
+		assert(e).IsNotNil()
+		_, e = LoadDBConfig(fnConfig(`, "password": "${file:`+passwordFile+`.none}"`, ""))
+		assert(e).IsNotNil()
+	})
+
+	t.Run("env override", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		t.Setenv("TEST_DB_PASSWORD", "secret")
+		t.Setenv("CAPI_DB_CONNECT_HOST", "db2")
+		t.Setenv("CAPI_DB_CONNECT_PASSWORD", "${env:TEST_DB_PASSWORD}")
+		t.Setenv("CAPI_DB_CONNECT_TLS_MODE", "require")
+		t.Setenv("CAPI_DB_POOL_MAXOPENCONNS", "8")
+		t.Setenv("CAPI_DB_REPLICAS", `[{"host": "r1", "port": 5432}]`)
+
+		config, e := LoadDBConfig(fnConfig("", ""))
+		assert(e).IsNil()
+		assert(config.Connect.Host, config.Connect.Password).Equals("db2", "secret")
+		assert(config.Connect.TLS.Mode, config.Pool.MaxOpenConns).Equals("require", 8)
+		assert(config.Replicas[0].Host).Equals("r1")
+
+		t.Setenv("CAPI_DB_POOL_MAXOPENCONNS", "eight")
+		_, e = LoadDBConfig(fnConfig("", ""))
+		assert(e).IsNotNil()
+	})
+}
+
+func TestLoadServerConfig(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		config, e := LoadServerConfig(`{"listen": ":8080", "maxBodySize": "4m", "readTimeout": "30s"}`)
+		assert(e).IsNil()
+		assert(config.Listen, config.ReadTimeout).Equals(":8080", "30s")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		_, e := LoadServerConfig(`{}`)
+		assert(e).IsNotNil()
+		_, e = LoadServerConfig(`{"listen": ":8080", "tls": {"cert": "cert.pem"}}`)
+		assert(e).IsNotNil()
+		_, e = LoadServerConfig(`{"listen": ":8080", "idleTimeout": "2"}`)
+		assert(e).IsNotNil()
+		_, e = LoadServerConfig(`{"listen": ":8080", "maxBodySize": "4x"}`)
+		assert(e).IsNotNil()
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		configFile := filepath.Join(t.TempDir(), "server.json")
+		assert(os.WriteFile(configFile, []byte(`{"listen": ":9090", "writeTimeout": "5s"}`), 0o600)).IsNil()
+		t.Setenv("CAPI_SERVER_CONFIG", configFile)
+		t.Setenv("CAPI_SERVER_WRITETIMEOUT", "10s")
+		t.Setenv("CAPI_SERVER_CORS_ORIGINS", `["https://a.com"]`)
+
+		config, e := LoadServerConfig(`{"listen": ":8080", "readTimeout": "30s"}`)
+		assert(e).IsNil()
+		assert(config.Listen, config.ReadTimeout, config.WriteTimeout).Equals(":9090", "30s", "10s")
+		assert(config.CORS.Origins).Equals([]string{"https://a.com"})
+	})
+}
+
+func TestParseConfigSize(t *testing.T) {
+	assert := utils.NewAssert(t)
+	for value, expect := range map[string]int64{"512": 512, "64k": 64 << 10, "4M": 4 << 20, "1g": 1 << 30} {
+		size, e := ParseConfigSize(value)
+		assert(size, e).Equals(expect, nil)
+	}
+	for _, value := range []string{"", "k", "-1", "4x", "1.5m"} {
+		_, e := ParseConfigSize(value)
+		assert(e).IsNotNil()
+	}
+}
+
+func TestSQLManager_replicas(t *testing.T) {
+	primary, replica := &testSqlConn{}, &testSqlConn{}
+	manager := &SQLManager{
+		agent:    NewPGAgent(),
+		db:       sql.OpenDB(primary),
+		replicas: []*sql.DB{sql.OpenDB(replica)},
+		tableMap: map[string]*DBTable{},
+		mutex:    &sync.Mutex{},
+	}
+
+	t.Run("read only", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		tx, e := manager.NewTransaction(SqlLevelReadCommitted, true).GetTx()
+		assert(e).IsNil()
+		_, e = tx.Exec("SELECT 1;")
+		assert(e).IsNil()
+		assert(len(primary.execs), len(replica.execs)).Equals(0, 1)
+	})
+
+	t.Run("read write", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		tx, e := manager.NewTransaction(SqlLevelReadCommitted, false).GetTx()
+		assert(e).IsNil()
+		_, e = tx.Exec("SELECT 1;")
+		assert(e).IsNil()
+		assert(len(primary.execs), len(replica.execs)).Equals(1, 1)
+	})
+}
@@ -4,10 +4,16 @@ import (
 	"bufio"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 func (p *DBTable) GetColumnsTypeMap() map[string]string {
@@ -50,352 +56,1298 @@ func (p *DBTable) GetUniques() []string {
 	return ret
 }
 
+func (p *DBTable) GetSearches() []string {
+	if p == nil {
+		return nil
+	}
+
+	ret := make([]string, 0)
+	for columnName, column := range p.Columns {
+		if column.Search {
+			ret = append(ret, columnName)
+		}
+	}
+	return ret
+}
+
+// GetCompositeIndexes returns the composite indexes, the values are encoded
+// so that any change of an index can be detected by a string compare.
+func (p *DBTable) GetCompositeIndexes() map[string]string {
+	if p == nil {
+		return nil
+	}
+
+	ret := make(map[string]string)
+	for indexName, index := range p.Indexes {
+		if value, e := json.Marshal(index); e == nil {
+			ret[indexName] = string(value)
+		}
+	}
+	return ret
+}
+
+// GetEnums returns the enum columns, the values are encoded so that
+// any change of the allowed values can be detected by a string compare.
+func (p *DBTable) GetEnums() map[string]string {
+	if p == nil {
+		return nil
+	}
+
+	ret := make(map[string]string)
+	for columnName, column := range p.Columns {
+		if column.Type == "Enum" {
+			if values, e := json.Marshal(column.Enum); e == nil {
+				ret[columnName] = string(values)
+			}
+		}
+	}
+	return ret
+}
+
+// GetForeignKeys returns the link columns with a foreign key, the values
+// contain the linked table and the rule so that any change can be detected.
+func (p *DBTable) GetForeignKeys() map[string]string {
+	if p == nil {
+		return nil
+	}
+
+	ret := make(map[string]string)
+	for columnName, column := range p.Columns {
+		if column.ForeignKey {
+			ret[columnName] = column.LinkTable + ":" + column.OnDelete
+		}
+	}
+	return ret
+}
+
+// checkNames checks the names that are used as identifiers of the statements
+func (p *DBTable) checkNames() error {
+	if !gSqlServiceNameRegex.MatchString(p.Table) {
+		return Errorf("invalid table name %q", p.Table)
+	}
+
+	for columnName, column := range p.Columns {
+		if !gSqlColumnNameRegex.MatchString(columnName) {
+			return Errorf("%s: invalid column name %q", p.Table, columnName)
+		} else if column.LinkTable != "" && !gSqlServiceNameRegex.MatchString(column.LinkTable) {
+			return Errorf("%s.%s: invalid link table %q", p.Table, columnName, column.LinkTable)
+		}
+	}
+
+	for indexName, index := range p.Indexes {
+		if !gSqlColumnNameRegex.MatchString(indexName) {
+			return Errorf("%s: invalid index name %q", p.Table, indexName)
+		}
+		for _, column := range index.Columns {
+			if columnName, _, _ := strings.Cut(column, ":"); p.Columns[columnName] == nil {
+				return Errorf("%s.indexes.%s: column %q not found", p.Table, indexName, columnName)
+			}
+		}
+	}
+
+	return nil
+}
+
+// IsManagedColumn reports whether the column is maintained by the runtime
+func (p *DBTable) IsManagedColumn(columnName string) bool {
+	switch columnName {
+	case SqlColumnCreatedAt, SqlColumnUpdatedAt:
+		return p.Timestamps
+	case SqlColumnCreatedBy, SqlColumnUpdatedBy:
+		return p.Audit
+	case SqlColumnDeletedAt:
+		return p.SoftDelete
+	case SqlColumnVersion:
+		return p.Versioned
+	default:
+		return false
+	}
+}
+
 type SQLTransaction struct {
 	readOnly       bool
 	tx             *sql.Tx
 	dbMgr          *SQLManager
 	isolationLevel string
+	principal      string
+	pendingExecs   []string
 	mutex          *sync.Mutex
+	span           *Span
+}
+
+func (p *SQLTransaction) GetTx() (*sql.Tx, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// the read-only transactions are routed to the replicas
+	if p.tx == nil {
+		db := p.dbMgr.db
+		if p.readOnly {
+			db = p.dbMgr.readDB()
+		}
+
+		if tx, e := db.BeginTx(context.Background(), &sql.TxOptions{
+			Isolation: stringToIsolationLevel(p.isolationLevel),
+			ReadOnly:  p.readOnly,
+		}); e != nil {
+			return nil, e
+		} else {
+			p.tx = tx
+		}
+	}
+
+	return p.tx, nil
+}
+
+func (p *SQLTransaction) Close(commit bool) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	ret := error(nil)
+
+	if p.tx != nil {
+		if commit {
+			ret = p.tx.Commit()
+		} else {
+			ret = p.tx.Rollback()
+		}
+		gMetrics.observeTx(commit, ret)
+		p.tx = nil
+	}
+
+	return ret
+}
+
+// SetSpan sets the span of the action, the statements are traced as its children
+func (p *SQLTransaction) SetSpan(span *Span) {
+	p.span = span
+}
+
+// execStmt executes a statement of the transaction in a span
+func (p *SQLTransaction) execStmt(tx *sql.Tx, query string, args ...any) (sql.Result, error) {
+	span := p.span.StartChild("sql exec", SpanKindClient)
+	span.SetAttribute("db.statement", query)
+	ret, e := tx.Exec(query, args...)
+	span.SetError(e)
+	span.Finish()
+	return ret, e
+}
+
+// queryStmt queries a statement of the transaction in a span, the span
+// ends when the rows are returned
+func (p *SQLTransaction) queryStmt(tx *sql.Tx, query string, args ...any) (*sql.Rows, error) {
+	span := p.span.StartChild("sql query", SpanKindClient)
+	span.SetAttribute("db.statement", query)
+	ret, e := tx.Query(query, args...)
+	span.SetError(e)
+	span.Finish()
+	return ret, e
+}
+
+// Record is a row of a table, keyed by column name
+type Record map[string]any
+
+func (p Record) String(name string) (string, bool) {
+	v, ok := p[name].(string)
+	return v, ok
+}
+
+func (p *SQLTransaction) SetPrincipal(principal string) {
+	p.principal = principal
+}
+
+func (p *SQLTransaction) GetPrincipal() string {
+	return p.principal
+}
+
+// setManagedColumns sets the columns maintained by the runtime
+func (p *SQLTransaction) setManagedColumns(table *DBTable, insert bool, keys []string, args []any) ([]string, []any) {
+	now := time.Now().UTC()
+
+	if table.Timestamps {
+		if insert {
+			keys, args = append(keys, SqlColumnCreatedAt), append(args, now)
+		}
+		keys, args = append(keys, SqlColumnUpdatedAt), append(args, now)
+	}
+
+	if table.Audit {
+		if insert {
+			keys, args = append(keys, SqlColumnCreatedBy), append(args, p.principal)
+		}
+		keys, args = append(keys, SqlColumnUpdatedBy), append(args, p.principal)
+	}
+
+	return keys, args
+}
+
+// encodeRecord encodes the columns of record, the columns that are not in
+// record are skipped, so that they keep the database default or old value.
+func (p *SQLTransaction) encodeRecord(table *DBTable, record Record) ([]string, []any, error) {
+	keys := make([]string, 0, len(record))
+	args := make([]any, 0, len(record))
+
+	for columnName, columnValue := range record {
+		if columnName == "id" {
+			continue
+		}
+
+		column, ok := table.Columns[columnName]
+		if !ok {
+			return nil, nil, Errorf("%s: unknown column \"%s\"", table.Table, columnName)
+		} else if table.IsManagedColumn(columnName) {
+			return nil, nil, Errorf("%s: column \"%s\" is managed by the runtime", table.Table, columnName)
+		}
+
+		if v, e := SqlEncodeToDB(column.Type, columnValue); e != nil {
+			return nil, nil, WrapError(e).AddHeaderf("%s.%s", table.Table, columnName)
+		} else if column.ForeignKey && v == "" {
+			keys = append(keys, columnName)
+			args = append(args, nil)
+		} else {
+			keys = append(keys, columnName)
+			args = append(args, v)
+		}
+	}
+
+	return keys, args, nil
+}
+
+// Insert inserts record into the table and returns its id,
+// a new id is generated if record has no id.
+func (p *SQLTransaction) Insert(serviceName string, record Record) (string, error) {
+	agent := p.dbMgr.agent
+	table := p.dbMgr.GetService(serviceName)
+
+	if table == nil {
+		return "", Errorf("Insert: table %s not found", serviceName)
+	}
+
+	id, _ := record.String("id")
+	if id == "" {
+		id = SqlUUID()
+	}
+
+	keys, args, e := p.encodeRecord(table, record)
+	if e != nil {
+		return "", WrapError(e).AddHeader("Insert")
+	}
+
+	keys, args = append(keys, "id"), append(args, id)
+	keys, args = p.setManagedColumns(table, true, keys, args)
+
+	if tx, e := p.GetTx(); e != nil {
+		return "", WrapError(e).AddHeaderf("Insert %s", table.Table)
+	} else if _, e := p.execStmt(tx, agent.Insert(table.Table, keys), args...); e != nil {
+		return "", WrapError(e).AddHeaderf("Insert %s", table.Table)
+	} else {
+		return id, nil
+	}
+}
+
+// sqlRowGroup is a group of encoded records that have the same columns,
+// indexes are the positions of the records in the inserted records
+type sqlRowGroup struct {
+	keys    []string
+	rows    [][]any
+	indexes []int
+}
+
+// args returns the values of the rows in [start, end) row by row
+func (p *sqlRowGroup) args(start int, end int) []any {
+	ret := make([]any, 0, (end-start)*len(p.keys))
+	for _, row := range p.rows[start:end] {
+		ret = append(ret, row...)
+	}
+	return ret
+}
+
+// encodeRecords encodes the records to insert and returns their ids, the
+// records are grouped by their columns, so that a group can be inserted by
+// multi-row statements
+func (p *SQLTransaction) encodeRecords(table *DBTable, records []Record) ([]string, []*sqlRowGroup, error) {
+	ids := make([]string, len(records))
+	groups := []*sqlRowGroup{}
+	groupMap := map[string]*sqlRowGroup{}
+
+	for i, record := range records {
+		id, _ := record.String("id")
+		if id == "" {
+			id = SqlUUID()
+		}
+		ids[i] = id
+
+		keys, args, e := p.encodeRecord(table, record)
+		if e != nil {
+			return nil, nil, WrapError(e).AddHeaderf("records[%d]", i)
+		}
+
+		keys, args = append(keys, "id"), append(args, id)
+		keys, args = p.setManagedColumns(table, true, keys, args)
+
+		// the columns are sorted, the column order of a record is random
+		sortedKeys := slices.Sorted(slices.Values(keys))
+		row := make([]any, len(keys))
+		for j, key := range keys {
+			row[slices.Index(sortedKeys, key)] = args[j]
+		}
+
+		groupKey := strings.Join(sortedKeys, ",")
+		group, ok := groupMap[groupKey]
+		if !ok {
+			group = &sqlRowGroup{keys: sortedKeys}
+			groupMap[groupKey] = group
+			groups = append(groups, group)
+		}
+		group.rows = append(group.rows, row)
+		group.indexes = append(group.indexes, i)
+	}
+
+	return ids, groups, nil
+}
+
+// InsertMany inserts records and returns their ids in the order of records.
+// The records are inserted by multi-row statements, the rows of a statement
+// are limited by the max args of the agent.
+func (p *SQLTransaction) InsertMany(serviceName string, records []Record) ([]string, error) {
+	agent := p.dbMgr.agent
+	table := p.dbMgr.GetService(serviceName)
+
+	if table == nil {
+		return nil, Errorf("InsertMany: table %s not found", serviceName)
+	}
+
+	ids, groups, e := p.encodeRecords(table, records)
+	if e != nil {
+		return nil, WrapError(e).AddHeader("InsertMany")
+	} else if len(ids) == 0 {
+		return ids, nil
+	}
+
+	tx, e := p.GetTx()
+	if e != nil {
+		return nil, WrapError(e).AddHeaderf("InsertMany %s", table.Table)
+	}
+
+	for _, group := range groups {
+		chunk := agent.MaxArgs() / len(group.keys)
+		for start := 0; start < len(group.rows); start += chunk {
+			end := min(start+chunk, len(group.rows))
+			if _, e := p.execStmt(tx, agent.InsertMany(table.Table, group.keys, end-start), group.args(start, end)...); e != nil {
+				return nil, WrapError(e).AddHeaderf("InsertMany %s", table.Table)
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+// Upsert inserts records, a record that has the same value of columnName as
+// an existing record updates the existing record instead. columnName must be
+// the id or a unique column, and the records must not share a value of it.
+// It returns the ids of the inserted or updated records in the order of records.
+// A soft deleted record is not updated and fails the upsert.
+func (p *SQLTransaction) Upsert(serviceName string, columnName string, records []Record) ([]string, error) {
+	agent := p.dbMgr.agent
+	table := p.dbMgr.GetService(serviceName)
+
+	if table == nil {
+		return nil, Errorf("Upsert: table %s not found", serviceName)
+	}
+
+	if column, ok := table.Columns[columnName]; !ok || (columnName != "id" && !column.Unique) {
+		return nil, Errorf("Upsert %s: column %s is not unique", table.Table, columnName)
+	} else if !gSqlConflictColumnTypes[column.Type] {
+		return nil, Errorf("Upsert %s: column %s of type %s can not match the records", table.Table, columnName, column.Type)
+	}
+
+	ids, groups, e := p.encodeRecords(table, records)
+	if e != nil {
+		return nil, WrapError(e).AddHeader("Upsert")
+	} else if len(ids) == 0 {
+		return ids, nil
+	}
+
+	conditions, conditionArgs := []string(nil), []any(nil)
+	if table.SoftDelete {
+		conditions, conditionArgs = append(conditions, SqlColumnDeletedAt), append(conditionArgs, gSqlNotDeleted)
+	}
+
+	tx, e := p.GetTx()
+	if e != nil {
+		return nil, WrapError(e).AddHeaderf("Upsert %s", table.Table)
+	}
+
+	for _, group := range groups {
+		chunk := (agent.MaxArgs() - len(conditionArgs)) / len(group.keys)
+		for start := 0; start < len(group.rows); start += chunk {
+			end := min(start+chunk, len(group.rows))
+			execSQL := agent.Upsert(table.Table, group.keys, end-start, columnName, conditions, table.Versioned)
+			upsertRows, e := p.queryUpsertRows(tx, execSQL, append(group.args(start, end), conditionArgs...))
+			if e != nil {
+				return nil, WrapError(e).AddHeaderf("Upsert %s", table.Table)
+			} else if len(upsertRows) != end-start {
+				return nil, Errorf(
+					"Upsert %s: %d records conflict with deleted records", table.Table, end-start-len(upsertRows),
+				).SetCode(ErrDBConflict)
+			} else if e := group.matchUpsertRows(start, end, columnName, ids, upsertRows); e != nil {
+				return nil, WrapError(e).AddHeaderf("Upsert %s", table.Table)
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+// sqlUpsertRow is a record returned by an upsert, key is the text of its
+// conflict column
+type sqlUpsertRow struct {
+	id  string
+	key sql.NullString
+}
+
+// queryUpsertRows returns the records returned by the upsert execSQL
+func (p *SQLTransaction) queryUpsertRows(tx *sql.Tx, execSQL string, args []any) ([]sqlUpsertRow, error) {
+	rows, e := p.queryStmt(tx, execSQL, args...)
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+
+	ret := []sqlUpsertRow{}
+	for rows.Next() {
+		row := sqlUpsertRow{}
+		if e := rows.Scan(&row.id, &row.key); e != nil {
+			return nil, e
+		}
+		ret = append(ret, row)
+	}
+
+	return ret, rows.Err()
+}
+
+// gSqlConflictColumnTypes are the column types that an upsert can match by,
+// the text of their values is the text of the db, e.g. a Float64 or a Time is
+// formatted differently by the db
+var gSqlConflictColumnTypes = map[string]bool{
+	"PK": true, "LK": true, "Bool": true, "Int64": true, "Enum": true,
+	"String": true, "String16": true, "String32": true, "String64": true, "String256": true,
+}
+
+// sqlConflictKey is the text of an encoded value of a conflict column, the
+// named types such as the enums are keyed by their kinds. It is false if the
+// text of the db can differ from the text of the value.
+func sqlConflictKey(v any) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.String:
+		return rv.String(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), true
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), true
+	default:
+		return "", false
+	}
+}
+
+// matchUpsertRows sets the ids of the rows in [start, end) by the records
+// returned by their upsert, the order of the records is not the order of the
+// rows. An inserted record has the id of its row, an updated record has the
+// id of the existing record and it is matched by the conflict column.
+func (p *sqlRowGroup) matchUpsertRows(start int, end int, columnName string, ids []string, upsertRows []sqlUpsertRow) error {
+	insertedIds := map[string]bool{}
+	keyIndexes := map[string]int{}
+	keyPos := slices.Index(p.keys, columnName)
+
+	for i := start; i < end; i++ {
+		index := p.indexes[i]
+		insertedIds[ids[index]] = true
+		if keyPos < 0 {
+			continue
+		} else if key, ok := sqlConflictKey(p.rows[i][keyPos]); ok {
+			keyIndexes[key] = index
+		}
+	}
+
+	for _, row := range upsertRows {
+		if insertedIds[row.id] {
+			continue
+		} else if index, ok := keyIndexes[row.key.String]; ok && row.key.Valid {
+			ids[index] = row.id
+		} else {
+			return fmt.Errorf("record %s does not match a row by %s", row.id, columnName)
+		}
+	}
+
+	return nil
+}
+
+// UpdateMany updates the columns in record of the records matched by the
+// where of query and returns the count of the updated records, the orders,
+// limit and offset of query are ignored. The versions of the records of a
+// versioned table are increased without checking.
+func (p *SQLTransaction) UpdateMany(query *SqlQuery, record Record) (int64, error) {
+	if query == nil {
+		return 0, Errorf("UpdateMany: query is nil")
+	}
+
+	agent := p.dbMgr.agent
+	table := p.dbMgr.GetService(query.GetService())
+	if table == nil {
+		return 0, Errorf("UpdateMany: table %s not found", query.GetService())
+	}
+
+	if e := query.Check(table, true); e != nil {
+		return 0, WrapError(e)
+	}
+
+	keys, args, e := p.encodeRecord(table, record)
+	if e != nil {
+		return 0, WrapError(e).AddHeader("UpdateMany")
+	} else if len(keys) == 0 {
+		return 0, nil
+	}
+
+	keys, args = p.setManagedColumns(table, false, keys, args)
+
+	execWhere, whereArgs, e := p.queryWhere(table, len(args), query)
+	if e != nil {
+		return 0, WrapError(e).AddHeaderf("UpdateMany %s", table.Table)
+	}
+
+	tx, e := p.GetTx()
+	if e != nil {
+		return 0, WrapError(e).AddHeaderf("UpdateMany %s", table.Table)
+	}
+
+	execSQL := agent.UpdateMany(table.Table, keys, execWhere, table.Versioned)
+	if result, e := p.execStmt(tx, execSQL, append(args, whereArgs...)...); e != nil {
+		return 0, WrapError(e).AddHeaderf("UpdateMany %s", table.Table)
+	} else if n, e := result.RowsAffected(); e != nil {
+		return 0, WrapError(e).AddHeaderf("UpdateMany %s", table.Table)
+	} else {
+		return n, nil
+	}
+}
+
+// Update updates the columns in record of the record with id, the record
+// of a versioned table must contain the version that the update is based on.
+func (p *SQLTransaction) Update(serviceName string, id string, record Record) error {
+	agent := p.dbMgr.agent
+	table := p.dbMgr.GetService(serviceName)
+
+	if table == nil {
+		return Errorf("Update: table %s not found", serviceName)
+	}
+
+	version := int64(0)
+	if table.Versioned {
+		if v, ok := sqlToInt64(record[SqlColumnVersion]); !ok {
+			return Errorf("Update %s: version is required", table.Table)
+		} else {
+			version = v
+			record = maps.Clone(record)
+			delete(record, SqlColumnVersion)
+		}
+	}
+
+	keys, args, e := p.encodeRecord(table, record)
+	if e != nil {
+		return WrapError(e).AddHeader("Update")
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	keys, args = p.setManagedColumns(table, false, keys, args)
+	args = append(args, id)
+
+	// the deleted records can only be restored
+	conditions := []string(nil)
+	if table.SoftDelete {
+		conditions, args = append(conditions, SqlColumnDeletedAt), append(args, gSqlNotDeleted)
+	}
+	existsArgs := append([]any{id}, args[len(keys)+1:]...)
+	if table.Versioned {
+		conditions, args = append(conditions, SqlColumnVersion), append(args, version)
+	}
+
+	tx, e := p.GetTx()
+	if e != nil {
+		return WrapError(e).AddHeaderf("Update %s", table.Table)
+	}
+
+	if result, e := p.execStmt(tx, agent.Update(table.Table, keys, conditions), args...); e != nil {
+		return WrapError(e).AddHeaderf("Update %s", table.Table)
+	} else if n, e := result.RowsAffected(); e != nil {
+		return WrapError(e).AddHeaderf("Update %s", table.Table)
+	} else if n > 0 {
+		return nil
+	} else if !table.Versioned {
+		return Errorf("Update %s: record %s not found", table.Table, id).SetCode(ErrDBRecordNotFound)
+	}
+
+	// the version does not match if the record still exists
+	rows, e := p.queryStmt(tx, agent.Exists(table.Table, conditions[:len(conditions)-1]), existsArgs...)
+	if e != nil {
+		return WrapError(e).AddHeaderf("Update %s", table.Table)
+	}
+	exists := rows.Next()
+	if e := rows.Close(); e != nil {
+		return WrapError(e).AddHeaderf("Update %s", table.Table)
+	}
+
+	if exists {
+		return Errorf(
+			"Update %s: record %s has been changed, version %d is out of date",
+			table.Table, id, version,
+		).SetCode(ErrDBConflict)
+	} else {
+		return Errorf("Update %s: record %s not found", table.Table, id).SetCode(ErrDBRecordNotFound)
+	}
+}
+
+// Delete deletes the record with id, if the table is soft deleted
+// the record is only marked by deleted_at and can be restored.
+// The onDelete rules of the columns that link to the table are applied first.
+func (p *SQLTransaction) Delete(serviceName string, id string) error {
+	table := p.dbMgr.GetService(serviceName)
+
+	if table == nil {
+		return Errorf("Delete: table %s not found", serviceName)
+	}
+
+	return p.delete(table, id, map[string]bool{})
+}
+
+// delete deletes the record, deleted contains the records that are being
+// deleted by the cascade, so that a cycle of links is deleted only once
+func (p *SQLTransaction) delete(table *DBTable, id string, deleted map[string]bool) error {
+	agent := p.dbMgr.agent
+
+	deleted[table.Table+":"+id] = true
+
+	for _, linkTable := range p.dbMgr.tableMap {
+		for _, columnName := range slices.Sorted(maps.Keys(linkTable.Columns)) {
+			column := linkTable.Columns[columnName]
+			if column.LinkTable == table.Table && column.OnDelete != "" {
+				if e := p.applyOnDelete(table, id, linkTable, columnName, deleted); e != nil {
+					return e
+				}
+			}
+		}
+	}
+
+	if table.SoftDelete {
+		return p.setDeletedAt(table, "Delete", id, time.Now().UTC(), []string{SqlColumnDeletedAt}, gSqlNotDeleted)
+	}
+
+	if tx, e := p.GetTx(); e != nil {
+		return WrapError(e).AddHeaderf("Delete %s", table.Table)
+	} else if result, e := p.execStmt(tx, agent.Delete(table.Table), id); e != nil {
+		return WrapError(e).AddHeaderf("Delete %s", table.Table)
+	} else if n, e := result.RowsAffected(); e != nil {
+		return WrapError(e).AddHeaderf("Delete %s", table.Table)
+	} else if n == 0 {
+		return Errorf("Delete %s: record %s not found", table.Table, id).SetCode(ErrDBRecordNotFound)
+	} else {
+		return nil
+	}
+}
+
+// applyOnDelete applies the onDelete rule of the link column to the
+// records that link to the deleted record with id
+func (p *SQLTransaction) applyOnDelete(
+	table *DBTable,
+	id string,
+	linkTable *DBTable,
+	columnName string,
+	deleted map[string]bool,
+) error {
+	agent := p.dbMgr.agent
+	column := linkTable.Columns[columnName]
+
+	tx, e := p.GetTx()
+	if e != nil {
+		return WrapError(e).AddHeaderf("Delete %s", table.Table)
+	}
+
+	conditions, args := []string(nil), []any{id}
+	if linkTable.SoftDelete {
+		conditions, args = append(conditions, SqlColumnDeletedAt), append(args, gSqlNotDeleted)
+	}
+
+	// read all the references before changing them
+	references := []Record{}
+	rows, e := p.queryStmt(tx, agent.References(linkTable.Table, columnName, column.Type, conditions), args...)
+	if e != nil {
+		return WrapError(e).AddHeaderf("Delete %s", table.Table)
+	}
+	for rows.Next() {
+		linkID, value := "", any(nil)
+		if e := rows.Scan(&linkID, &value); e != nil {
+			_ = rows.Close()
+			return WrapError(e).AddHeaderf("Delete %s", table.Table)
+		} else if v, e := SqlDecodeFromDB(column.Type, value); e != nil {
+			_ = rows.Close()
+			return WrapError(e).AddHeaderf("Delete %s", table.Table)
+		} else if !deleted[linkTable.Table+":"+linkID] {
+			references = append(references, Record{"id": linkID, columnName: v})
+		}
+	}
+	if e := FirstError(rows.Err(), rows.Close()); e != nil {
+		return WrapError(e).AddHeaderf("Delete %s", table.Table)
+	}
+
+	for _, reference := range references {
+		linkID, _ := reference.String("id")
+
+		switch column.OnDelete {
+		case "restrict":
+			return Errorf(
+				"Delete %s: record %s is linked by %s.%s of record %s",
+				table.Table, id, linkTable.Table, columnName, linkID,
+			).SetCode(ErrDBReferenced)
+		case "cascade":
+			if !deleted[linkTable.Table+":"+linkID] {
+				if e := p.delete(linkTable, linkID, deleted); e != nil {
+					return e
+				}
+			}
+		case "setNull":
+			value := any(nil)
+			switch v := reference[columnName].(type) {
+			case []string:
+				value = slices.DeleteFunc(v, func(it string) bool { return it == id })
+			case map[string]any:
+				maps.DeleteFunc(v, func(_ string, it any) bool { return it == id })
+				value = v
+			}
+
+			if v, e := SqlEncodeToDB(column.Type, value); e != nil {
+				return WrapError(e).AddHeaderf("Delete %s", table.Table)
+			} else {
+				if column.Type == "LK" && !column.ForeignKey {
+					v = ""
+				}
+				keys, args := p.setManagedColumns(linkTable, false, []string{columnName}, []any{v})
+				if _, e := p.execStmt(tx, agent.Update(linkTable.Table, keys, nil), append(args, linkID)...); e != nil {
+					return WrapError(e).AddHeaderf("Delete %s", table.Table)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Restore restores the soft deleted record with id, restoring a record
+// that is not deleted does nothing.
+func (p *SQLTransaction) Restore(serviceName string, id string) error {
+	table := p.dbMgr.GetService(serviceName)
+
+	if table == nil {
+		return Errorf("Restore: table %s not found", serviceName)
+	} else if !table.SoftDelete {
+		return Errorf("Restore %s: table is not soft deleted", table.Table)
+	}
+
+	return p.setDeletedAt(table, "Restore", id, gSqlNotDeleted, nil)
+}
+
+func (p *SQLTransaction) setDeletedAt(
+	table *DBTable,
+	action string,
+	id string,
+	deletedAt time.Time,
+	conditions []string,
+	conditionArgs ...any,
+) error {
+	agent := p.dbMgr.agent
+	keys, args := p.setManagedColumns(table, false, []string{SqlColumnDeletedAt}, []any{deletedAt})
+	args = append(append(args, id), conditionArgs...)
+
+	if tx, e := p.GetTx(); e != nil {
+		return WrapError(e).AddHeaderf("%s %s", action, table.Table)
+	} else if result, e := p.execStmt(tx, agent.Update(table.Table, keys, conditions), args...); e != nil {
+		return WrapError(e).AddHeaderf("%s %s", action, table.Table)
+	} else if n, e := result.RowsAffected(); e != nil {
+		return WrapError(e).AddHeaderf("%s %s", action, table.Table)
+	} else if n == 0 {
+		return Errorf("%s %s: record %s not found", action, table.Table, id).SetCode(ErrDBRecordNotFound)
+	} else {
+		return nil
+	}
+}
+
+// Query returns the view of the records matched by query,
+// the linked columns of the view are loaded with their linked views.
+func (p *SQLTransaction) Query(query *SqlQuery) ([]Record, error) {
+	if query == nil {
+		return nil, Errorf("Query: query is nil")
+	}
+
+	table := p.dbMgr.GetService(query.GetService())
+	if table == nil {
+		return nil, Errorf("Query: table %s not found", query.GetService())
+	}
+
+	if e := query.Check(table, true); e != nil {
+		return nil, WrapError(e)
+	}
+
+	return p.query(table, query, 0)
 }
 
-func (p *SQLTransaction) GetTx() (*sql.Tx, error) {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
+func (p *SQLTransaction) query(table *DBTable, query *SqlQuery, depth int) ([]Record, error) {
+	if query.GetCursor() != "" {
+		return nil, Errorf("Query %s: cursor is only allowed by page queries", table.Table)
+	}
 
-	if p.tx == nil {
-		if tx, e := p.dbMgr.db.BeginTx(context.Background(), &sql.TxOptions{
-			Isolation: stringToIsolationLevel(p.isolationLevel),
-			ReadOnly:  p.readOnly,
-		}); e != nil {
-			return nil, e
+	ret, _, e := p.queryPage(table, query, depth, false)
+	return ret, e
+}
+
+// QueryPage returns a page of the view of the records matched by query, the
+// records are ordered by the orders of query and id. nextCursor is empty if
+// it is the last page, otherwise it is the cursor of the next page.
+func (p *SQLTransaction) QueryPage(query *SqlQuery) ([]Record, string, error) {
+	if query == nil {
+		return nil, "", Errorf("QueryPage: query is nil")
+	}
+
+	table := p.dbMgr.GetService(query.GetService())
+	if table == nil {
+		return nil, "", Errorf("QueryPage: table %s not found", query.GetService())
+	}
+
+	if e := query.Check(table, true); e != nil {
+		return nil, "", WrapError(e)
+	} else if query.GetLimit() <= 0 {
+		return nil, "", Errorf("QueryPage %s: limit is required", table.Table)
+	} else if query.GetOffset() > 0 {
+		return nil, "", Errorf("QueryPage %s: offset is not allowed", table.Table)
+	}
+
+	return p.queryPage(table, query, 0, true)
+}
+
+func (p *SQLTransaction) queryPage(
+	table *DBTable,
+	query *SqlQuery,
+	depth int,
+	paginate bool,
+) (ret []Record, nextCursor string, err error) {
+	agent := p.dbMgr.agent
+
+	if depth > gSqlMaxLinkDepth {
+		return nil, "", Errorf("Query %s: links are nested too deep", table.Table)
+	}
+
+	view := table.Views[query.GetView()]
+	if view == nil {
+		return nil, "", Errorf("Query %s: view %s not found", table.Table, query.GetView())
+	}
+
+	columns := make([]string, len(view.Columns))
+	for i, column := range view.Columns {
+		columns[i] = column.Name
+	}
+
+	// a page is ordered by id at last, so that the order is unique,
+	// the relevance of search orders can not be kept by a cursor
+	orders := query.GetOrders()
+	if paginate {
+		orders = slices.DeleteFunc(slices.Clone(orders), func(it *SqlOrderBy) bool { return it.search != "" })
+		if !slices.ContainsFunc(orders, func(it *SqlOrderBy) bool { return it.name == "id" }) {
+			orders = append(orders, &SqlOrderBy{"id", true, ""})
+		}
+	}
+
+	// the order columns are selected to build the cursor, they are
+	// removed from the records if they are not in the view
+	viewColumnsCount := len(columns)
+	if paginate {
+		for _, order := range orders {
+			if !slices.Contains(columns, order.name) {
+				columns = append(columns, order.name)
+			}
+		}
+	}
+
+	execWhere, whereArgs, e := p.queryWhere(table, 0, query)
+	if e != nil {
+		return nil, "", WrapError(e).AddHeaderf("Query %s", table.Table)
+	}
+
+	// the records after the cursor
+	if paginate && query.GetCursor() != "" {
+		if cursorArgs, e := sqlDecodeCursor(table, orders, query.GetCursor()); e != nil {
+			return nil, "", WrapError(e).AddHeaderf("QueryPage %s", table.Table)
 		} else {
-			p.tx = tx
+			execAfter := agent.QueryAfter(table.Table, len(whereArgs), orders)
+			if execWhere == "" {
+				execWhere = execAfter
+			} else {
+				execWhere = execWhere + " AND " + execAfter
+			}
+			whereArgs = append(whereArgs, cursorArgs...)
 		}
 	}
 
-	return p.tx, nil
+	if execWhere != "" {
+		execWhere = "WHERE " + execWhere
+	}
+
+	orderQuery := *query
+	orderQuery.orders = orders
+	execOrderBy, orderArgs := agent.QueryOrderBy(table.Table, len(whereArgs), &orderQuery)
+	if execOrderBy != "" {
+		execOrderBy = "ORDER BY " + execOrderBy
+	}
+	whereArgs = append(whereArgs, orderArgs...)
+
+	// a page reads one more record to know whether there is a next page
+	execLimit := ""
+	if paginate {
+		execLimit = fmt.Sprintf("LIMIT %d", query.GetLimit()+1)
+	} else if query.GetLimit() > 0 {
+		execLimit = fmt.Sprintf("LIMIT %d", query.GetLimit())
+	}
+	if query.GetOffset() > 0 {
+		execLimit = strings.TrimSpace(fmt.Sprintf("%s OFFSET %d", execLimit, query.GetOffset()))
+	}
+
+	tx, e := p.GetTx()
+	if e != nil {
+		return nil, "", WrapError(e).AddHeaderf("Query %s", table.Table)
+	}
+
+	rows, e := p.queryStmt(tx, fmt.Sprintf(
+		"SELECT %s FROM %s %s %s %s;",
+		agent.QuerySelect(table.Table, columns),
+		agent.QuoteIdentifier(table.Table),
+		execWhere,
+		execOrderBy,
+		execLimit,
+	), whereArgs...)
+	if e != nil {
+		return nil, "", WrapError(e).AddHeaderf("Query %s", table.Table)
+	}
+	defer func() {
+		if e := rows.Close(); e != nil && err == nil {
+			err = WrapError(e)
+		}
+	}()
+
+	ret = make([]Record, 0)
+	values := make([]any, len(columns))
+	scanArgs := make([]any, len(columns))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if e := rows.Scan(scanArgs...); e != nil {
+			return nil, "", WrapError(e).AddHeaderf("Query %s", table.Table)
+		}
+
+		record := Record{}
+		for i, columnName := range columns {
+			if v, e := SqlDecodeFromDB(table.Columns[columnName].Type, values[i]); e != nil {
+				return nil, "", WrapError(e).AddHeaderf("Query %s.%s", table.Table, columnName)
+			} else {
+				record[columnName] = v
+			}
+		}
+		ret = append(ret, record)
+	}
+
+	if e := rows.Err(); e != nil {
+		return nil, "", WrapError(e).AddHeaderf("Query %s", table.Table)
+	}
+
+	if paginate {
+		if len(ret) > query.GetLimit() {
+			ret = ret[:query.GetLimit()]
+			if nextCursor, e = sqlEncodeCursor(orders, ret[len(ret)-1]); e != nil {
+				return nil, "", WrapError(e).AddHeaderf("QueryPage %s", table.Table)
+			}
+		}
+
+		for _, record := range ret {
+			for _, columnName := range columns[viewColumnsCount:] {
+				delete(record, columnName)
+			}
+		}
+	}
+
+	if e := p.loadLinks(table, view, ret, depth); e != nil {
+		return nil, "", e
+	}
+
+	return ret, nextCursor, nil
 }
 
-func (p *SQLTransaction) Close(commit bool) error {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
+// queryWhere builds the where of query, the soft deleted records are excluded
+func (p *SQLTransaction) queryWhere(table *DBTable, argStartPos int, query *SqlQuery) (string, []any, error) {
+	whereQuery := query
+	if table.SoftDelete && !query.GetIncludeDeleted() {
+		whereQuery = NewQuery(query.GetService()).And(SqlColumnDeletedAt, SqlEqual, gSqlNotDeleted)
+		if len(query.GetWheres()) > 0 {
+			whereQuery.AndChild(query)
+		}
+	}
 
-	ret := error(nil)
+	return p.dbMgr.agent.QueryWhere(table.Table, argStartPos, whereQuery)
+}
 
-	if p.tx != nil {
-		if commit {
-			ret = p.tx.Commit()
-		} else {
-			ret = p.tx.Rollback()
+// Aggregate returns the aggregates of the records matched by query, a record
+// for each group. The records contain the group columns and the aggregates,
+// see SqlAggregate.GetName. The orders of query must be group columns.
+func (p *SQLTransaction) Aggregate(query *SqlQuery) (ret []Record, err error) {
+	if query == nil {
+		return nil, Errorf("Aggregate: query is nil")
+	}
+
+	agent := p.dbMgr.agent
+	table := p.dbMgr.GetService(query.GetService())
+	if table == nil {
+		return nil, Errorf("Aggregate: table %s not found", query.GetService())
+	}
+
+	if e := query.Check(table, true); e != nil {
+		return nil, WrapError(e)
+	} else if len(query.GetAggregates()) == 0 {
+		return nil, Errorf("Aggregate %s: aggregates are required", table.Table)
+	} else if query.GetCursor() != "" {
+		return nil, Errorf("Aggregate %s: cursor is not allowed", table.Table)
+	}
+
+	for _, order := range query.GetOrders() {
+		if !slices.Contains(query.GetGroupBy(), order.name) {
+			return nil, Errorf("Aggregate %s: order %s is not a group column", table.Table, order.name)
 		}
-		p.tx = nil
 	}
 
-	return ret
+	execWhere, whereArgs, e := p.queryWhere(table, 0, query)
+	if e != nil {
+		return nil, WrapError(e).AddHeaderf("Aggregate %s", table.Table)
+	} else if execWhere != "" {
+		execWhere = "WHERE " + execWhere
+	}
+
+	execGroupBy := agent.QueryGroupBy(table.Table, query)
+	if execGroupBy != "" {
+		execGroupBy = "GROUP BY " + execGroupBy
+	}
+
+	execOrderBy, orderArgs := agent.QueryOrderBy(table.Table, len(whereArgs), query)
+	if execOrderBy != "" {
+		execOrderBy = "ORDER BY " + execOrderBy
+	}
+	whereArgs = append(whereArgs, orderArgs...)
+
+	execLimit := ""
+	if query.GetLimit() > 0 {
+		execLimit = fmt.Sprintf("LIMIT %d", query.GetLimit())
+	}
+	if query.GetOffset() > 0 {
+		execLimit = strings.TrimSpace(fmt.Sprintf("%s OFFSET %d", execLimit, query.GetOffset()))
+	}
+
+	tx, e := p.GetTx()
+	if e != nil {
+		return nil, WrapError(e).AddHeaderf("Aggregate %s", table.Table)
+	}
+
+	rows, e := p.queryStmt(tx, fmt.Sprintf(
+		"SELECT %s FROM %s %s %s %s %s;",
+		agent.QueryAggregate(table.Table, query),
+		agent.QuoteIdentifier(table.Table),
+		execWhere,
+		execGroupBy,
+		execOrderBy,
+		execLimit,
+	), whereArgs...)
+	if e != nil {
+		return nil, WrapError(e).AddHeaderf("Aggregate %s", table.Table)
+	}
+	defer func() {
+		if e := rows.Close(); e != nil && err == nil {
+			err = WrapError(e)
+		}
+	}()
+
+	groupBy, aggregates := query.GetGroupBy(), query.GetAggregates()
+	values := make([]any, len(groupBy)+len(aggregates))
+	scanArgs := make([]any, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	ret = make([]Record, 0)
+	for rows.Next() {
+		if e := rows.Scan(scanArgs...); e != nil {
+			return nil, WrapError(e).AddHeaderf("Aggregate %s", table.Table)
+		}
+
+		record := Record{}
+		for i, columnName := range groupBy {
+			if v, e := SqlDecodeFromDB(table.Columns[columnName].Type, values[i]); e != nil {
+				return nil, WrapError(e).AddHeaderf("Aggregate %s.%s", table.Table, columnName)
+			} else {
+				record[columnName] = v
+			}
+		}
+		for i, aggregate := range aggregates {
+			kind := ""
+			if column := table.Columns[aggregate.GetColumnName()]; column != nil {
+				kind = column.Type
+			}
+			if v, e := sqlDecodeAggregate(aggregate.GetFunc(), kind, values[len(groupBy)+i]); e != nil {
+				return nil, WrapError(e).AddHeaderf("Aggregate %s.%s", table.Table, aggregate.GetName())
+			} else {
+				record[aggregate.GetName()] = v
+			}
+		}
+		ret = append(ret, record)
+	}
+
+	if e := rows.Err(); e != nil {
+		return nil, WrapError(e).AddHeaderf("Aggregate %s", table.Table)
+	}
+
+	return ret, nil
+}
+
+// Count returns the count of the records matched by the where of query,
+// the orders, limit and offset of query are ignored.
+func (p *SQLTransaction) Count(query *SqlQuery) (int64, error) {
+	if query == nil {
+		return 0, Errorf("Count: query is nil")
+	}
+
+	countQuery := NewQuery(query.GetService()).Count()
+	countQuery.wheres = query.GetWheres()
+	countQuery.includeDeleted = query.GetIncludeDeleted()
+
+	if records, e := p.Aggregate(countQuery); e != nil {
+		return 0, e
+	} else if len(records) != 1 {
+		return 0, Errorf("Count %s: db internal error", query.GetService())
+	} else {
+		count, _ := records[0][string(SqlCount)].(int64)
+		return count, nil
+	}
 }
 
-// func (p *SQLTransaction) Add(serviceName string, record Record) error {
-// 	agent := p.dbMgr.agent
-// 	service := p.dbMgr.GetService(serviceName)
-
-// 	if service == nil {
-// 		return Errorf("Add: service %s not found", serviceName)
-// 	}
-
-// 	for columnName, columnKind := range service.GetColumnsMap() {
-// 		if _, ok := record[columnName]; !ok {
-// 			switch columnKind {
-// 			case "PK":
-// 				record[columnName] = SqlUUID()
-// 			case "LK":
-// 				record[columnName] = ""
-// 			case "Bool":
-// 				record[columnName] = false
-// 			case "Int64":
-// 				record[columnName] = int64(0)
-// 			case "Float64":
-// 				record[columnName] = float64(0)
-// 			case "Bytes":
-// 				record[columnName] = []byte{}
-// 			case "String256":
-// 				record[columnName] = ""
-// 			case "String":
-// 				record[columnName] = ""
-// 			case "StringArray":
-// 				record[columnName] = []any{}
-// 			case "StringMap":
-// 				record[columnName] = map[string]any{}
-// 			case "File":
-// 				record[columnName] = ""
-// 			case "FileArray":
-// 				record[columnName] = []string{}
-// 			case "FileMap":
-// 				record[columnName] = map[string]string{}
-// 			case "LKArray":
-// 				record[columnName] = []string{}
-// 			case "LKMap":
-// 				record[columnName] = map[string]string{}
-// 			default:
-// 			}
-// 		}
-// 	}
-
-// 	pos := 0
-// 	keys := make([]string, 0)
-// 	arguments := make([]any, 0)
-
-// 	columnConfig := service.GetColumnsMap()
-
-// 	for columnName, columnValue := range record {
-// 		pos++
-// 		keys = append(keys, columnName)
-
-// 		kind := columnConfig[columnName]
-
-// 		if kind == "" {
-// 			return Errorf("Add: %s: unknown column \"%s\"", service.GetName(), columnName)
-// 		} else if argValue, e := SqlEncodeToDB(kind, columnValue); e != nil {
-// 			return WrapError(e, "Add", service.GetName())
-// 		} else {
-// 			arguments = append(arguments, argValue)
-// 		}
-// 	}
-
-// 	if len(keys) == 0 {
-// 		return nil
-// 	}
-
-// 	if tx, e := p.GetTx(); e != nil {
-// 		return WrapError(e, "Add", service.GetName())
-// 	} else if _, e := tx.Exec(
-// 		agent.Insert(service.GetName(), keys),
-// 		arguments...,
-// 	); e != nil {
-// 		return WrapError(e, "Add", service.GetName())
-// 	} else {
-// 		return nil
-// 	}
-// }
-
-// func (p *SQLTransaction) Del(serviceName string, id string) error {
-// 	agent := p.dbMgr.agent
-// 	service := p.dbMgr.GetService(serviceName)
-
-// 	if service == nil {
-// 		return Errorf("Delete: %s: service is ", serviceName)
-// 	}
-
-// 	if tx, e := p.GetTx(); e != nil {
-// 		return WrapError(e, "Delete", service.GetName())
-// 	} else if _, e := tx.Exec(
-// 		agent.Delete(service.GetName()),
-// 		id,
-// 	); e != nil {
-// 		return WrapError(e, "Delete", service.GetName())
-// 	} else {
-// 		return nil
-// 	}
-// }
-
-// func (p *SQLTransaction) Update(serviceName string, record Record) error {
-// 	agent := p.dbMgr.agent
-// 	service := p.dbMgr.GetService(serviceName)
-
-// 	if service == nil {
-// 		return Errorf("Update: service %s not found", serviceName)
-// 	}
-
-// 	if recordId, ok := record.String("id"); !ok {
-// 		return Errorf("Update: %s: record has no id", serviceName)
-// 	} else {
-// 		columnConfig := service.GetColumnsMap()
-// 		keys := make([]string, 0)
-// 		arguments := make([]any, 0)
-
-// 		for columnName, columnValue := range record {
-// 			if columnName != "id" {
-// 				if len(columnConfig[columnName]) <= 0 {
-// 					return Errorf("Update: %s: invalid column %s", service.GetName(), columnName)
-// 				}
-
-// 				if v, e := SqlEncodeToDB(columnConfig[columnName], columnValue); e != nil {
-// 					return WrapError(e, "Update", service.GetName())
-// 				} else {
-// 					keys = append(keys, columnName)
-// 					arguments = append(arguments, v)
-// 				}
-// 			}
-// 		}
-
-// 		if len(keys) == 0 {
-// 			return nil
-// 		}
-
-// 		arguments = append(arguments, recordId)
-
-// 		if tx, e := p.GetTx(); e != nil {
-// 			return WrapError(e, "Update", service.GetName())
-// 		} else if _, e := tx.Exec(
-// 			agent.Update(service.GetName(), keys),
-// 			arguments...,
-// 		); e != nil {
-// 			return WrapError(e, "Update", service.GetName())
-// 		} else {
-// 			return nil
-// 		}
-// 	}
-// }
-
-// func (p *SQLTransaction) GetColumnType(serviceName string, columnName string) string {
-// 	service := p.dbMgr.GetService(serviceName)
-
-// 	if service == nil {
-// 		return ""
-// 	}
-
-// 	return service.GetColumnType(columnName)
-// }
-
-// func (p *SQLTransaction) GetRecordWithAllColumns(serviceName string, id string) (ret Record, err error) {
-// 	agent := p.dbMgr.agent
-// 	service := p.dbMgr.GetService(serviceName)
-
-// 	if service == nil {
-// 		return Record{}, Errorf("GetColumnValue: service %s not found", serviceName)
-// 	}
-
-// 	viewSelects := []string{}
-// 	for columnName := range service.GetColumnsMap() {
-// 		viewSelects = append(viewSelects, columnName)
-// 	}
-
-// 	query := NewQuery(serviceName).And("id", "=", id)
-
-// 	// check and build where
-// 	execWhere, whereArgs, e := agent.QueryWhere(service.GetName(), 0, query)
-// 	if e != nil {
-// 		return Record{}, WrapError(e, "GetColumnValue", service.GetName())
-// 	}
-
-// 	if execWhere != "" {
-// 		execWhere = "WHERE " + execWhere
-// 	}
-
-// 	if tx, e := p.GetTx(); e != nil {
-// 		return Record{}, WrapError(e, "GetColumnValue", service.GetName())
-// 	} else if rows, e := tx.Query(fmt.Sprintf(
-// 		"SELECT %s FROM \"%s\" %s;",
-// 		agent.QuerySelect(service.GetName(), viewSelects),
-// 		service.GetName(),
-// 		execWhere,
-// 	), whereArgs...); e != nil {
-// 		return Record{}, WrapError(e, "GetColumnValue", service.GetName())
-// 	} else {
-// 		defer func() {
-// 			if rows != nil {
-// 				err = GetFirstError(err, WrapError(rows.Close()))
-// 			}
-// 		}()
-
-// 		if ret, e := SqlRowsToRecords(service, viewSelects, rows); e != nil {
-// 			return Record{}, Errorf("service %s GetColumnValue error: %s", service.GetName(), e.Error())
-// 		} else if len(ret) != 1 {
-// 			return Record{}, Errorf("service %s GetColumnValue error: record not found", service.GetName())
-// 		} else {
-// 			return ret[0], nil
-// 		}
-// 	}
-// }
-
-// func (p *SQLTransaction) Query(query *SqlQuery) (ret []Record, err error) {
-// 	serviceName := query.GetService()
-// 	agent := p.dbMgr.agent
-// 	service := p.dbMgr.GetService(serviceName)
-
-// 	if service == nil {
-// 		return []Record{}, Errorf("Query: service %s not found", serviceName)
-// 	}
-
-// 	if query == nil {
-// 		return []Record{}, Errorf("Query: %s: service is nil", serviceName)
-// 	}
-
-// 	if e := query.Check(service, true); e != nil {
-// 		return []Record{}, WrapError(e, "Query", service.GetName())
-// 	}
-
-// 	viewSelects := service.GetViewSelects(query.GetView())
-// 	if len(viewSelects) == 0 {
-// 		return []Record{}, Errorf("Query: %s: view %s not found", service.GetName(), query.GetView())
-// 	}
-
-// 	// check and build where
-// 	execWhere, whereArgs, e := agent.QueryWhere(service.GetName(), 0, query)
-// 	if e != nil {
-// 		return []Record{}, WrapError(e, "Query", service.GetName())
-// 	}
-
-// 	execOrderBy := agent.QueryOrderBy(service.GetName(), query)
-// 	execLimit := ""
-
-// 	if query.GetLimit() > 0 && query.GetOffset() >= 0 {
-// 		execLimit = fmt.Sprintf("LIMIT %d OFFSET %d", query.GetLimit(), query.GetOffset())
-// 	} else if query.GetLimit() > 0 {
-// 		execLimit = fmt.Sprintf("LIMIT %d", query.GetLimit())
-// 	} else if query.GetOffset() >= 0 {
-// 		execLimit = fmt.Sprintf("OFFSET %d", query.GetOffset())
-// 	} else {
-// 		execLimit = ""
-// 	}
-
-// 	if execWhere != "" {
-// 		execWhere = "WHERE " + execWhere
-// 	}
-
-// 	if execOrderBy != "" {
-// 		execOrderBy = "ORDER BY " + execOrderBy
-// 	}
-
-// 	// fmt.Printf(
-// 	// 	"SELECT %s FROM \"%s\" %s %s %s;\n",
-// 	// 	agent.QuerySelect(service.GetName(), viewSelects),
-// 	// 	service.GetName(),
-// 	// 	execWhere,
-// 	// 	execOrderBy,
-// 	// 	execLimit,
-// 	// )
-
-// 	if tx, e := p.GetTx(); e != nil {
-// 		return []Record{}, WrapError(e, "Query", service.GetName())
-// 	} else if rows, e := tx.Query(fmt.Sprintf(
-// 		"SELECT %s FROM \"%s\" %s %s %s;",
-// 		agent.QuerySelect(service.GetName(), viewSelects),
-// 		service.GetName(),
-// 		execWhere,
-// 		execOrderBy,
-// 		execLimit,
-// 	), whereArgs...); e != nil {
-// 		return []Record{}, WrapError(e, "Query", service.GetName())
-// 	} else {
-// 		defer func() {
-// 			if rows != nil {
-// 				err = GetFirstError(err, WrapError(rows.Close()))
-// 			}
-// 		}()
-
-// 		if ret, e := SqlRowsToRecords(service, viewSelects, rows); e != nil {
-// 			return []Record{}, Errorf("service %s Query error: %s", service.GetName(), e.Error())
-// 		} else {
-// 			return ret, nil
-// 		}
-// 	}
-// }
+// loadLinks replaces the linked ids in records with the linked views
+func (p *SQLTransaction) loadLinks(table *DBTable, view *DBTableView, records []Record, depth int) error {
+	for _, viewColumn := range view.Columns {
+		if viewColumn.LinkTable == "" || viewColumn.LinkView == "" {
+			continue
+		}
+
+		kind := table.Columns[viewColumn.Name].Type
+		ids := []string{}
+
+		for _, record := range records {
+			switch v := record[viewColumn.Name].(type) {
+			case string:
+				ids = append(ids, v)
+			case []string:
+				ids = append(ids, v...)
+			case map[string]any:
+				for _, it := range v {
+					if id, ok := it.(string); ok {
+						ids = append(ids, id)
+					}
+				}
+			}
+		}
+
+		linkMap := map[string]Record{}
+		if ids = UniqueArrayWithoutEmpty(ids); len(ids) > 0 {
+			linkTable := p.dbMgr.GetService(viewColumn.LinkTable)
+			if linkTable == nil {
+				return Errorf("Query %s: link table %s not found", table.Table, viewColumn.LinkTable)
+			}
+
+			// the statements of the linked view are the children of its span
+			span, parentSpan := p.span.StartChild("load link", SpanKindInternal), p.span
+			span.SetAttribute("capi.table", table.Table)
+			span.SetAttribute("capi.column", viewColumn.Name)
+			span.SetAttribute("capi.link_view", viewColumn.LinkTable+"."+viewColumn.LinkView)
+			span.SetAttribute("capi.link_count", len(ids))
+
+			p.span = span
+			linkQuery := NewQuery(viewColumn.LinkTable).View(viewColumn.LinkView).And("id", SqlIn, ids)
+			linked, e := p.query(linkTable, linkQuery, depth+1)
+			p.span = parentSpan
+			span.SetError(e)
+			span.Finish()
+
+			if e != nil {
+				return e
+			} else {
+				for _, it := range linked {
+					if id, ok := it.String("id"); ok {
+						linkMap[id] = it
+					}
+				}
+			}
+		}
+
+		for _, record := range records {
+			switch kind {
+			case "LK":
+				id, _ := record.String(viewColumn.Name)
+				if linked, ok := linkMap[id]; ok {
+					record[viewColumn.Name] = linked
+				} else {
+					record[viewColumn.Name] = nil
+				}
+			case "LKList":
+				list := []Record{}
+				idList, _ := record[viewColumn.Name].([]string)
+				for _, id := range idList {
+					if linked, ok := linkMap[id]; ok {
+						list = append(list, linked)
+					}
+				}
+				record[viewColumn.Name] = list
+			case "LKMap":
+				linkedMap := map[string]Record{}
+				idMap, _ := record[viewColumn.Name].(map[string]any)
+				for key, it := range idMap {
+					id, _ := it.(string)
+					if linked, ok := linkMap[id]; ok {
+						linkedMap[key] = linked
+					}
+				}
+				record[viewColumn.Name] = linkedMap
+			}
+		}
+	}
+
+	return nil
+}
 
 func (p *SQLTransaction) UpdateTable(newConfigText string) error {
 	agent := p.dbMgr.agent
 	newTable, e := LoadDBTable(newConfigText)
 	if e != nil {
-		return WrapError(e).AddHeaderf("UpdateTable %s", newTable.Table)
+		return WrapError(e).AddHeader("UpdateTable")
+	} else if e := newTable.checkNames(); e != nil {
+		return WrapError(e).AddHeader("UpdateTable")
 	}
 
 	oldTableConfig := ""
@@ -405,11 +1357,11 @@ func (p *SQLTransaction) UpdateTable(newConfigText string) error {
 		return WrapError(e)
 	}
 
-	if _, e := tx.Exec(agent.CreateMetaTable()); e != nil {
+	if _, e := p.execStmt(tx, agent.CreateMetaTable()); e != nil {
 		return WrapError(e)
 	}
 
-	if rows, e := tx.Query(agent.QueryMetaTable(), newTable.Table); e != nil {
+	if rows, e := p.queryStmt(tx, agent.QueryMetaTable(), newTable.Table); e != nil {
 		return WrapError(e)
 	} else {
 		for rows.Next() {
@@ -439,6 +1391,32 @@ func (p *SQLTransaction) UpdateTable(newConfigText string) error {
 		)
 	}
 
+	// update foreign keys, they are created by ExecPending after all the
+	// tables are updated, because the linked table may not exist yet
+	addForeignKeys, changeForeignKeys, delForeignKeys := SqlDiffStringMap(
+		oldTable.GetForeignKeys(),
+		newTable.GetForeignKeys(),
+	)
+
+	for columnName := range delForeignKeys {
+		if _, e := p.execStmt(tx, agent.DropForeignKey(newTable.Table, columnName)); e != nil {
+			return WrapError(e)
+		}
+	}
+	for columnName := range changeForeignKeys {
+		if _, e := p.execStmt(tx, agent.DropForeignKey(newTable.Table, columnName)); e != nil {
+			return WrapError(e)
+		}
+		addForeignKeys[columnName] = changeForeignKeys[columnName]
+	}
+	for columnName := range addForeignKeys {
+		column := newTable.Columns[columnName]
+		p.pendingExecs = append(
+			p.pendingExecs,
+			agent.CreateForeignKey(newTable.Table, columnName, column.LinkTable, column.OnDelete),
+		)
+	}
+
 	if len(delColumns) != 0 {
 		for columnName := range delColumns {
 			fmt.Printf(
@@ -462,7 +1440,8 @@ func (p *SQLTransaction) UpdateTable(newConfigText string) error {
 				}
 
 				// delete columns
-				if _, e := tx.Exec(
+				if _, e := p.execStmt(
+					tx,
 					agent.DropColumn(newTable.Table, columnName),
 				); e != nil {
 					return WrapError(e)
@@ -511,19 +1490,197 @@ func (p *SQLTransaction) UpdateTable(newConfigText string) error {
 		execList = append(execList, agent.CreateUnique(newTable.Table, columnName))
 	}
 
-	// update meta
-	if oldTableConfig == "" {
-		execList = append(execList, agent.InsertMetaTable(newTable.Table, newConfigText))
-	} else {
-		execList = append(execList, agent.UpdateMetaTable(newTable.Table, newConfigText))
+	// update composite indexes
+	addCompositeIndexes, changeCompositeIndexes, delCompositeIndexes := SqlDiffStringMap(
+		oldTable.GetCompositeIndexes(),
+		newTable.GetCompositeIndexes(),
+	)
+
+	for indexName := range delCompositeIndexes {
+		execList = append(execList, agent.DropCompositeIndex(newTable.Table, indexName))
+	}
+	for indexName := range changeCompositeIndexes {
+		execList = append(execList, agent.DropCompositeIndex(newTable.Table, indexName))
+		execList = append(execList, agent.CreateCompositeIndex(newTable.Table, indexName, newTable.Indexes[indexName]))
+	}
+	for indexName := range addCompositeIndexes {
+		execList = append(execList, agent.CreateCompositeIndex(newTable.Table, indexName, newTable.Indexes[indexName]))
+	}
+
+	// update search columns
+	addSearches, delSearches := SqlDiffStringArray(oldTable.GetSearches(), newTable.GetSearches())
+
+	for _, columnName := range delSearches {
+		execList = append(execList, agent.DropSearch(newTable.Table, columnName))
+	}
+	for _, columnName := range addSearches {
+		execList = append(execList, agent.CreateSearch(newTable.Table, columnName))
+	}
+
+	// update enum checks
+	addEnums, changeEnums, delEnums := SqlDiffStringMap(oldTable.GetEnums(), newTable.GetEnums())
+
+	for columnName := range delEnums {
+		execList = append(execList, agent.DropEnumCheck(newTable.Table, columnName))
+	}
+	for columnName := range changeEnums {
+		execList = append(execList, agent.DropEnumCheck(newTable.Table, columnName))
+		execList = append(execList, agent.CreateEnumCheck(newTable.Table, columnName, newTable.Columns[columnName].Enum))
+	}
+	for columnName := range addEnums {
+		execList = append(execList, agent.CreateEnumCheck(newTable.Table, columnName, newTable.Columns[columnName].Enum))
 	}
 
 	// exec sql
 	for i := 0; i < len(execList); i++ {
-		if _, e := tx.Exec(execList[i]); e != nil {
+		if _, e := p.execStmt(tx, execList[i]); e != nil {
+			return WrapError(e)
+		}
+	}
+
+	// update meta
+	metaSQL := agent.UpdateMetaTable()
+	if oldTableConfig == "" {
+		metaSQL = agent.InsertMetaTable()
+	}
+	if _, e := p.execStmt(tx, metaSQL, newTable.Table, newConfigText); e != nil {
+		return WrapError(e)
+	}
+
+	return nil
+}
+
+// ExecPending executes the statements that are delayed by UpdateTable
+func (p *SQLTransaction) ExecPending() error {
+	tx, e := p.GetTx()
+	if e != nil {
+		return WrapError(e)
+	}
+
+	for _, execSql := range p.pendingExecs {
+		if _, e := p.execStmt(tx, execSql); e != nil {
 			return WrapError(e)
 		}
 	}
 
+	p.pendingExecs = nil
 	return nil
 }
+
+// DBInsert inserts record with the transaction of ctx and returns its id
+func DBInsert(ctx *Context, table string, record Record) (string, *Error) {
+	if id, e := ctx.Tx().Insert(table, record); e != nil {
+		return "", WrapError(e)
+	} else {
+		return id, nil
+	}
+}
+
+// DBInsertMany inserts records with the transaction of ctx and returns their ids
+func DBInsertMany(ctx *Context, table string, records []Record) ([]string, *Error) {
+	if ids, e := ctx.Tx().InsertMany(table, records); e != nil {
+		return nil, WrapError(e)
+	} else {
+		return ids, nil
+	}
+}
+
+// DBUpsert inserts or updates records by columnName with the transaction of
+// ctx and returns their ids
+func DBUpsert(ctx *Context, table string, columnName string, records []Record) ([]string, *Error) {
+	if ids, e := ctx.Tx().Upsert(table, columnName, records); e != nil {
+		return nil, WrapError(e)
+	} else {
+		return ids, nil
+	}
+}
+
+// DBUpdateMany updates the records matched by query with the transaction of ctx
+func DBUpdateMany(ctx *Context, query *SqlQuery, record Record) (int64, *Error) {
+	if n, e := ctx.Tx().UpdateMany(query, record); e != nil {
+		return 0, WrapError(e)
+	} else {
+		return n, nil
+	}
+}
+
+// DBUpdate updates record with the transaction of ctx
+func DBUpdate(ctx *Context, table string, id string, record Record) *Error {
+	return WrapError(ctx.Tx().Update(table, id, record))
+}
+
+// DBDelete deletes the record with the transaction of ctx
+func DBDelete(ctx *Context, table string, id string) *Error {
+	return WrapError(ctx.Tx().Delete(table, id))
+}
+
+// DBRestore restores the soft deleted record with the transaction of ctx
+func DBRestore(ctx *Context, table string, id string) *Error {
+	return WrapError(ctx.Tx().Restore(table, id))
+}
+
+// DBQuery queries with the transaction of ctx and decodes the records into v,
+// v is a pointer to a slice of the generated view type.
+func DBQuery(ctx *Context, query *SqlQuery, v any) *Error {
+	if records, e := ctx.Tx().Query(query); e != nil {
+		return WrapError(e)
+	} else {
+		return recordsToValue(records, v)
+	}
+}
+
+// DBQueryPage queries a page with the transaction of ctx and decodes the
+// records into v, it returns the cursor of the next page.
+func DBQueryPage(ctx *Context, query *SqlQuery, v any) (string, *Error) {
+	if records, nextCursor, e := ctx.Tx().QueryPage(query); e != nil {
+		return "", WrapError(e)
+	} else if e := recordsToValue(records, v); e != nil {
+		return "", e
+	} else {
+		return nextCursor, nil
+	}
+}
+
+// DBCount counts the records matched by query with the transaction of ctx
+func DBCount(ctx *Context, query *SqlQuery) (int64, *Error) {
+	if count, e := ctx.Tx().Count(query); e != nil {
+		return 0, WrapError(e)
+	} else {
+		return count, nil
+	}
+}
+
+// DBAggregate returns the aggregates of query with the transaction of ctx
+func DBAggregate(ctx *Context, query *SqlQuery) ([]Record, *Error) {
+	if records, e := ctx.Tx().Aggregate(query); e != nil {
+		return nil, WrapError(e)
+	} else {
+		return records, nil
+	}
+}
+
+// DBGet decodes the view of the record with id into v
+func DBGet(ctx *Context, table string, view string, id string, v any) *Error {
+	tx := ctx.Tx()
+	dbTable := tx.dbMgr.GetService(table)
+
+	if dbTable == nil {
+		return Errorf("Get: table %s not found", table)
+	}
+
+	if records, e := tx.query(dbTable, NewQuery(table).View(view).And("id", SqlEqual, id), 0); e != nil {
+		return WrapError(e)
+	} else if len(records) == 0 {
+		return Errorf("Get %s: record %s not found", table, id).SetCode(ErrDBRecordNotFound)
+	} else {
+		return recordsToValue(records[0], v)
+	}
+}
+
+func recordsToValue(records any, v any) *Error {
+	if data, e := json.Marshal(records); e != nil {
+		return WrapError(e)
+	} else {
+		return JsonUnmarshal(data, v)
+	}
+}
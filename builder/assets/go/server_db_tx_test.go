@@ -0,0 +1,92 @@
+package _rt_package_name_
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"testing"
+
+	"github.com/ootiny/capi/utils"
+)
+
+func TestSqlRowGroup_matchUpsertRows(t *testing.T) {
+	fnGroup := func() *sqlRowGroup {
+		return &sqlRowGroup{
+			keys:    []string{"code", "id"},
+			rows:    [][]any{{"a", "id-a"}, {"b", "id-b"}, {"c", "id-c"}},
+			indexes: []int{0, 2, 3},
+		}
+	}
+	fnKey := func(key string) sql.NullString {
+		return sql.NullString{String: key, Valid: true}
+	}
+
+	t.Run("unordered", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		ids := []string{"id-a", "x", "id-b", "id-c"}
+		// b is inserted, c and a update the existing records
+		e := fnGroup().matchUpsertRows(0, 3, "code", ids, []sqlUpsertRow{
+			{id: "old-c", key: fnKey("c")},
+			{id: "id-b", key: fnKey("b")},
+			{id: "old-a", key: fnKey("a")},
+		})
+		assert(e, ids).Equals(nil, []string{"old-a", "x", "id-b", "old-c"})
+	})
+
+	t.Run("chunk", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		ids := []string{"id-a", "x", "id-b", "id-c"}
+		e := fnGroup().matchUpsertRows(1, 3, "code", ids, []sqlUpsertRow{
+			{id: "old-c", key: fnKey("c")},
+			{id: "old-b", key: fnKey("b")},
+		})
+		assert(e, ids).Equals(nil, []string{"id-a", "x", "old-b", "old-c"})
+	})
+
+	t.Run("unmatched", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		ids := []string{"id-a", "x", "id-b", "id-c"}
+		e := fnGroup().matchUpsertRows(1, 3, "code", ids, []sqlUpsertRow{
+			{id: "old-a", key: fnKey("a")},
+			{id: "id-c", key: fnKey("c")},
+		})
+		assert(e).IsNotNil()
+	})
+}
+
+type testStatus string
+
+func TestSQLTransaction_Upsert(t *testing.T) {
+	conn := &testSqlConn{}
+	manager := &SQLManager{
+		agent: NewPGAgent(),
+		db:    sql.OpenDB(conn),
+		tableMap: map[string]*DBTable{"db_test": {
+			Table: "db_test",
+			Columns: map[string]*DBTableColumn{
+				"id":     {Type: "PK"},
+				"status": {Type: "Enum", Unique: true, Enum: []string{"open", "closed"}},
+				"area":   {Type: "Float64", Unique: true},
+			},
+		}},
+		mutex: &sync.Mutex{},
+	}
+
+	t.Run("enum", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		// the existing record of the status is updated
+		conn.rows = [][]driver.Value{{"old-id", "open"}}
+		tx := manager.NewTransaction(SqlLevelReadCommitted, false)
+		ids, e := tx.Upsert("db_test", "status", []Record{{"status": testStatus("open")}})
+		assert(e, ids).Equals(nil, []string{"old-id"})
+		assert(tx.Close(true)).IsNil()
+	})
+
+	t.Run("float", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		tx := manager.NewTransaction(SqlLevelReadCommitted, false)
+		_, e := tx.Upsert("db_test", "area", []Record{{"area": 1.5}})
+		assert(e).IsNotNil()
+		assert(tx.Close(false)).IsNil()
+	})
+}
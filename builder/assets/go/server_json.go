@@ -3,6 +3,7 @@ package _rt_package_name_
 import (
 	"encoding/json"
 	"strconv"
+	"time"
 )
 
 // ==========================================
@@ -200,3 +201,79 @@ func (s JsonString) MarshalJSON() ([]byte, error) {
 	}
 	return json.Marshal(s.Val)
 }
+
+// ==========================================
+// 6. Date (YYYY-MM-DD)
+// ==========================================
+
+const DateLayout = "2006-01-02"
+
+// Date 只保留日期部分，JSON 格式为 "2006-01-02"
+type Date struct {
+	time.Time
+}
+
+func NewDate(t time.Time) Date {
+	return Date{Time: time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)}
+}
+
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return Date{}, err
+	}
+	return Date{Time: t}, nil
+}
+
+func (d Date) String() string {
+	return d.Format(DateLayout)
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if isNull(data) {
+		d.Time = time.Time{}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	v, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+// ==========================================
+// 7. GeoPoint (WGS84 经纬度)
+// ==========================================
+
+// GeoPoint 是一个地理坐标，JSON 格式为 {"latitude": 0, "longitude": 0}
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+func NewGeoPoint(latitude float64, longitude float64) GeoPoint {
+	return GeoPoint{Latitude: latitude, Longitude: longitude}
+}
+
+// Valid 检查纬度在 [-90, 90]，经度在 [-180, 180]
+func (g GeoPoint) Valid() bool {
+	return g.Latitude >= -90 && g.Latitude <= 90 &&
+		g.Longitude >= -180 && g.Longitude <= 180
+}
+
+// String 返回 point 格式 "(经度,纬度)"，即 x 为经度，y 为纬度
+func (g GeoPoint) String() string {
+	return "(" + strconv.FormatFloat(g.Longitude, 'f', -1, 64) +
+		"," + strconv.FormatFloat(g.Latitude, 'f', -1, 64) + ")"
+}
@@ -0,0 +1,214 @@
+package _rt_package_name_
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ootiny/capi/utils"
+)
+
+func TestNewRequestID(t *testing.T) {
+	assert := utils.NewAssert(t)
+	assert(newRequestID("abc-123")).Equals("abc-123")
+
+	for _, clientID := range []string{"", "a b", "a\nb", "中文", strings.Repeat("a", gRequestIDMaxLength+1)} {
+		requestID := newRequestID(clientID)
+		assert(len(requestID)).Equals(32)
+		assert(requestID == clientID).IsFalse()
+	}
+}
+
+func TestEvalAction_log(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	SetLogger(newLogger(&ServerLogConfig{Level: "debug", Format: "json"}, buffer))
+	RegisterHandler("test:Ok", func(ctx *Context, data []byte) *Return {
+		ctx.Logger().Debug("handling")
+		return &Return{}
+	})
+	RegisterHandler("test:Fail", func(ctx *Context, data []byte) *Return {
+		err := WrapError(Errorf("connection refused"))
+		return &Return{Code: err.Code(), Message: err.Error(), Err: err}
+	})
+	RegisterHandler("test:Panic", func(ctx *Context, data []byte) *Return {
+		panic("boom")
+	})
+	defer func() {
+		SetLogger(nil)
+		for _, action := range []string{"test:Ok", "test:Fail", "test:Panic"} {
+			delete(gAPIMap, action)
+		}
+	}()
+
+	fnEval := func(action string, requestID string) (*testResponse, []map[string]any) {
+		buffer.Reset()
+		w := &testResponse{headers: map[string]string{}}
+		evalAction(w, &testRequest{
+			method:  http.MethodPost,
+			headers: map[string]string{"X-Request-Id": requestID},
+			action:  action,
+		})
+
+		lines := []map[string]any{}
+		for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+			item := map[string]any{}
+			_ = json.Unmarshal([]byte(line), &item)
+			lines = append(lines, item)
+		}
+		return w, lines
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		w, lines := fnEval("test:Ok", "req-1")
+		assert(w.headers["X-Request-Id"], len(lines)).Equals("req-1", 2)
+		assert(lines[0]["msg"], lines[0]["request_id"], lines[0]["action"]).Equals("handling", "req-1", "test:Ok")
+		assert(lines[1]["msg"], lines[1]["level"], lines[1]["code"]).Equals("action", "INFO", float64(0))
+		assert(lines[1]["duration"]).IsNotNil()
+	})
+
+	t.Run("internal error", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		w, lines := fnEval("test:Fail", "")
+		assert(len(w.headers["X-Request-Id"]), len(lines)).Equals(32, 1)
+		assert(lines[0]["request_id"]).Equals(w.headers["X-Request-Id"])
+		assert(lines[0]["level"], lines[0]["message"]).Equals("ERROR", "connection refused")
+		assert(strings.Contains(lines[0]["trace"].(string), "server_log_test.go")).IsTrue()
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		_, lines := fnEval("test:Panic", "req-2")
+		assert(len(lines)).Equals(2)
+		assert(lines[0]["msg"], lines[0]["reason"]).Equals("action panic", "boom")
+		assert(strings.Contains(lines[0]["stack"].(string), "server_log_test.go")).IsTrue()
+		assert(lines[1]["level"], lines[1]["code"]).Equals("ERROR", float64(ErrActionExec))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		_, lines := fnEval("test:None", "req-3")
+		assert(len(lines)).Equals(1)
+		assert(lines[0]["level"], lines[0]["code"]).Equals("WARN", float64(ErrActionNotFound))
+	})
+}
+
+func TestEvalAction_errors(t *testing.T) {
+	buf := &bytes.Buffer{}
+	SetLogger(newLogger(&ServerLogConfig{}, buf))
+	RegisterHandler("test:Internal", func(ctx *Context, data []byte) *Return {
+		err := Errorf("pq: relation \"city\" does not exist")
+		return &Return{Code: err.Code(), Message: err.Error(), Err: err}
+	})
+	RegisterHandler("test:Public", func(ctx *Context, data []byte) *Return {
+		err := Errorf("record x not found").SetCode(ErrDBRecordNotFound)
+		return &Return{Code: err.Code(), Message: err.Error(), Err: err}
+	})
+	RegisterHandler("test:Registered", func(ctx *Context, data []byte) *Return {
+		return &Return{Code: 9001, Message: "quota exceeded"}
+	})
+	RegisterHandler("test:Panic", func(ctx *Context, data []byte) *Return {
+		panic("secret state")
+	})
+	RegisterHandler("test:Status", func(ctx *Context, data []byte) *Return {
+		err := Errorf("city x not found").SetCode(9002).SetStatus(http.StatusNotFound)
+		return &Return{Code: err.Code(), Message: err.Error(), Err: err}
+	})
+	// the decode of the generated handlers
+	RegisterHandler("test:Decode", func(ctx *Context, data []byte) *Return {
+		var v struct {
+			Id string `json:"id" required:"true"`
+		}
+		if err := ctx.Unmarshal(data, &v); err != nil {
+			return &Return{Code: ErrActionDecode, Message: err.Error(), Err: err}
+		}
+		return &Return{Data: v.Id}
+	})
+	defer func() {
+		SetLogger(nil)
+		SetExposeErrors(false)
+		for _, action := range []string{"test:Internal", "test:Public", "test:Registered", "test:Panic", "test:Status", "test:Decode"} {
+			delete(gAPIMap, action)
+		}
+		gPublicErrorCodesMutex.Lock()
+		delete(gPublicErrorCodes, 9001)
+		delete(gPublicErrorCodes, 9002)
+		gPublicErrorCodesMutex.Unlock()
+	}()
+
+	fnEval := func(action string) *Return {
+		return evalAction(&testResponse{headers: map[string]string{}}, &testRequest{
+			method:  http.MethodPost,
+			headers: map[string]string{"X-Request-Id": "req-1"},
+			action:  action,
+		})
+	}
+
+	t.Run("hidden", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		ret := fnEval("test:Internal")
+		assert(ret.Code, ret.Message, ret.RequestID).Equals(ErrCodeGeneral, gInternalErrorMessage, "req-1")
+		ret = fnEval("test:Panic")
+		assert(ret.Code, ret.Message, ret.RequestID).Equals(ErrActionExec, gInternalErrorMessage, "req-1")
+
+		// the code is internal until it is registered
+		ret = fnEval("test:Registered")
+		assert(ret.Message).Equals(gInternalErrorMessage)
+	})
+
+	t.Run("public", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		ret := fnEval("test:Public")
+		assert(ret.Code, ret.Message, ret.RequestID).Equals(ErrDBRecordNotFound, "record not found", "")
+		// the detailed message of a db error is only logged
+		assert(strings.Contains(buf.String(), "record x not found")).IsTrue()
+		ret = fnEval("test:None")
+		assert(ret.Code, ret.Message).Equals(ErrActionNotFound, "api test:None not found")
+
+		RegisterPublicErrorCode(9001)
+		ret = fnEval("test:Registered")
+		assert(ret.Code, ret.Message, ret.RequestID).Equals(9001, "quota exceeded", "")
+	})
+
+	t.Run("exposed", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		SetExposeErrors(true)
+		ret := fnEval("test:Internal")
+		assert(ret.Message, ret.RequestID).Equals(`pq: relation "city" does not exist`, "req-1")
+		ret = fnEval("test:Panic")
+		assert(ret.Message).Equals("action exec error: secret state")
+		ret = fnEval("test:Public")
+		assert(ret.Message).Equals("record x not found")
+	})
+	t.Run("status", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		fnStatus := func(action string) int {
+			w := &testResponse{headers: map[string]string{}}
+			apiHandler(w, &testRequest{method: http.MethodPost, headers: map[string]string{}, action: action})
+			return w.code
+		}
+
+		// the status of an internal error is hidden with its message
+		assert(fnStatus("test:Status")).Equals(http.StatusOK)
+		RegisterPublicErrorCode(9002)
+		assert(fnStatus("test:Status")).Equals(http.StatusNotFound)
+		assert(fnStatus("test:Public")).Equals(http.StatusOK)
+	})
+	t.Run("decode", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		SetExposeErrors(false)
+		w := &testResponse{headers: map[string]string{}}
+		apiHandler(w, &testRequest{
+			method:  http.MethodPost,
+			headers: map[string]string{},
+			action:  "test:Decode",
+			data:    []byte(`{"id":`),
+		})
+
+		var ret Return
+		assert(w.code, json.Unmarshal(w.body, &ret)).Equals(http.StatusOK, nil)
+		assert(ret.Code, ret.Message, ret.RequestID).Equals(ErrActionDecode, "unexpected end of JSON input", "")
+	})
+}
@@ -0,0 +1,189 @@
+package _rt_package_name_
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// gMetricsBuckets are the upper bounds of the duration histograms in seconds
+var gMetricsBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// gMetricsUnknownAction is the action label of the actions that are not
+// registered, so that the requests can not create labels
+const gMetricsUnknownAction = "unknown"
+
+var gMetrics = newMetrics()
+
+type metricsHistogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (p *metricsHistogram) observe(v float64) {
+	for i, bound := range gMetricsBuckets {
+		if v <= bound {
+			p.counts[i]++
+		}
+	}
+	p.sum += v
+	p.count++
+}
+
+type metricsAction struct {
+	codes    map[int]uint64
+	duration *metricsHistogram
+}
+
+// Metrics are the statistics of the runtime in the Prometheus text format
+type Metrics struct {
+	mutex       sync.Mutex
+	actions     map[string]*metricsAction
+	txCommits   atomic.Uint64
+	txRollbacks atomic.Uint64
+	txErrors    atomic.Uint64
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{actions: map[string]*metricsAction{}}
+}
+
+// observeAction records a request of the action, code is the code of the
+// return and 0 is a success
+func (p *Metrics) observeAction(action string, code int, duration time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	item, ok := p.actions[action]
+	if !ok {
+		item = &metricsAction{
+			codes:    map[int]uint64{},
+			duration: &metricsHistogram{counts: make([]uint64, len(gMetricsBuckets))},
+		}
+		p.actions[action] = item
+	}
+
+	item.codes[code]++
+	item.duration.observe(duration.Seconds())
+}
+
+// observeTx records the close of a transaction
+func (p *Metrics) observeTx(commit bool, err error) {
+	if err != nil {
+		p.txErrors.Add(1)
+	} else if commit {
+		p.txCommits.Add(1)
+	} else {
+		p.txRollbacks.Add(1)
+	}
+}
+
+// metricsLabel escapes a label value of the Prometheus text format
+func metricsLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func metricsFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// WriteTo writes the metrics in the Prometheus text format
+func (p *Metrics) WriteTo(w io.Writer) (int64, error) {
+	sb := &strings.Builder{}
+	fnHeader := func(name string, kind string, help string) {
+		fmt.Fprintf(sb, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	p.mutex.Lock()
+	actionNames := slices.Sorted(maps.Keys(p.actions))
+
+	fnHeader("capi_action_requests_total", "counter", "The count of the requests by action.")
+	for _, name := range actionNames {
+		fmt.Fprintf(sb, "capi_action_requests_total{action=\"%s\"} %d\n", metricsLabel(name), p.actions[name].duration.count)
+	}
+
+	fnHeader("capi_action_errors_total", "counter", "The count of the failed requests by action and error code.")
+	for _, name := range actionNames {
+		for _, code := range slices.Sorted(maps.Keys(p.actions[name].codes)) {
+			if code != 0 {
+				fmt.Fprintf(
+					sb, "capi_action_errors_total{action=\"%s\",code=\"%d\"} %d\n",
+					metricsLabel(name), code, p.actions[name].codes[code],
+				)
+			}
+		}
+	}
+
+	fnHeader("capi_action_duration_seconds", "histogram", "The duration of the requests by action.")
+	for _, name := range actionNames {
+		label, histogram := metricsLabel(name), p.actions[name].duration
+		for i, bound := range gMetricsBuckets {
+			fmt.Fprintf(
+				sb, "capi_action_duration_seconds_bucket{action=\"%s\",le=\"%s\"} %d\n",
+				label, metricsFloat(bound), histogram.counts[i],
+			)
+		}
+		fmt.Fprintf(sb, "capi_action_duration_seconds_bucket{action=\"%s\",le=\"+Inf\"} %d\n", label, histogram.count)
+		fmt.Fprintf(sb, "capi_action_duration_seconds_sum{action=\"%s\"} %s\n", label, metricsFloat(histogram.sum))
+		fmt.Fprintf(sb, "capi_action_duration_seconds_count{action=\"%s\"} %d\n", label, histogram.count)
+	}
+	p.mutex.Unlock()
+
+	fnHeader("capi_db_transactions_total", "counter", "The count of the closed transactions by result.")
+	fmt.Fprintf(sb, "capi_db_transactions_total{result=\"commit\"} %d\n", p.txCommits.Load())
+	fmt.Fprintf(sb, "capi_db_transactions_total{result=\"rollback\"} %d\n", p.txRollbacks.Load())
+	fmt.Fprintf(sb, "capi_db_transactions_total{result=\"error\"} %d\n", p.txErrors.Load())
+
+	if dbManager := GetDBManager(); dbManager != nil {
+		primary, replicas := dbManager.Stats()
+		dbStats := append([]sql.DBStats{primary}, replicas...)
+		dbNames := []string{"primary"}
+		for i := range replicas {
+			dbNames = append(dbNames, fmt.Sprintf("replica%d", i))
+		}
+
+		items := []struct {
+			name  string
+			kind  string
+			help  string
+			value func(stats sql.DBStats) string
+		}{
+			{"capi_db_connections_max_open", "gauge", "The max count of the open connections.", func(stats sql.DBStats) string {
+				return strconv.Itoa(stats.MaxOpenConnections)
+			}},
+			{"capi_db_connections_open", "gauge", "The count of the open connections.", func(stats sql.DBStats) string {
+				return strconv.Itoa(stats.OpenConnections)
+			}},
+			{"capi_db_connections_in_use", "gauge", "The count of the connections in use.", func(stats sql.DBStats) string {
+				return strconv.Itoa(stats.InUse)
+			}},
+			{"capi_db_connections_idle", "gauge", "The count of the idle connections.", func(stats sql.DBStats) string {
+				return strconv.Itoa(stats.Idle)
+			}},
+			{"capi_db_connections_wait_total", "counter", "The count of the waits for a connection.", func(stats sql.DBStats) string {
+				return strconv.FormatInt(stats.WaitCount, 10)
+			}},
+			{"capi_db_connections_wait_seconds_total", "counter", "The total time of the waits for a connection.", func(stats sql.DBStats) string {
+				return metricsFloat(stats.WaitDuration.Seconds())
+			}},
+		}
+
+		for _, item := range items {
+			fnHeader(item.name, item.kind, item.help)
+			for i, dbName := range dbNames {
+				fmt.Fprintf(sb, "%s{db=\"%s\"} %s\n", item.name, dbName, item.value(dbStats[i]))
+			}
+		}
+	}
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
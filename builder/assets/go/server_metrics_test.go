@@ -0,0 +1,54 @@
+package _rt_package_name_
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ootiny/capi/utils"
+)
+
+func TestMetrics_WriteTo(t *testing.T) {
+	assert := utils.NewAssert(t)
+	gDBManager = &SQLManager{db: sql.OpenDB(&testSqlConn{}), mutex: &sync.Mutex{}}
+	defer func() { gDBManager = nil }()
+
+	metrics := newMetrics()
+	metrics.observeAction("city:Query", 0, 20*time.Millisecond)
+	metrics.observeAction("city:Query", ErrDBRecordNotFound, 2*time.Second)
+	metrics.observeAction("a\"b", 0, time.Millisecond)
+	metrics.observeTx(true, nil)
+	metrics.observeTx(false, nil)
+	metrics.observeTx(true, sql.ErrTxDone)
+
+	sb := &strings.Builder{}
+	_, e := metrics.WriteTo(sb)
+	assert(e).IsNil()
+	text := sb.String()
+
+	for _, line := range []string{
+		"# TYPE capi_action_requests_total counter",
+		`capi_action_requests_total{action="city:Query"} 2`,
+		`capi_action_requests_total{action="a\"b"} 1`,
+		fmt.Sprintf(`capi_action_errors_total{action="city:Query",code="%d"} 1`, ErrDBRecordNotFound),
+		"# TYPE capi_action_duration_seconds histogram",
+		`capi_action_duration_seconds_bucket{action="city:Query",le="0.01"} 0`,
+		`capi_action_duration_seconds_bucket{action="city:Query",le="0.025"} 1`,
+		`capi_action_duration_seconds_bucket{action="city:Query",le="2.5"} 2`,
+		`capi_action_duration_seconds_bucket{action="city:Query",le="+Inf"} 2`,
+		`capi_action_duration_seconds_sum{action="city:Query"} 2.02`,
+		`capi_action_duration_seconds_count{action="city:Query"} 2`,
+		`capi_db_transactions_total{result="commit"} 1`,
+		`capi_db_transactions_total{result="rollback"} 1`,
+		`capi_db_transactions_total{result="error"} 1`,
+		`capi_db_connections_open{db="primary"} 0`,
+	} {
+		assert(strings.Contains(text, line+"\n")).IsTrue()
+	}
+
+	// the successes are not errors
+	assert(strings.Contains(text, `code="0"`)).IsFalse()
+}
@@ -0,0 +1,122 @@
+package _rt_package_name_
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ootiny/capi/utils"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	assert := utils.NewAssert(t)
+
+	traceID, parentID, sampled, ok := parseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert(ok, sampled).Equals(true, true)
+	assert(traceID.String(), parentID.String()).Equals("4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
+
+	_, _, sampled, ok = parseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	assert(ok, sampled).Equals(true, false)
+
+	// the future versions can have more fields
+	_, _, _, ok = parseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	assert(ok).IsTrue()
+
+	for _, traceParent := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0x",
+	} {
+		_, _, _, ok := parseTraceParent(traceParent)
+		assert(ok).IsFalse()
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	assert := utils.NewAssert(t)
+
+	// the collector stand-in records the posted traces
+	received := []otlpTraces{}
+	mutex := sync.Mutex{}
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var traces otlpTraces
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "Bearer t" ||
+			json.Unmarshal(body, &traces) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mutex.Lock()
+		received = append(received, traces)
+		mutex.Unlock()
+	}))
+	defer collector.Close()
+
+	gDBManager = &SQLManager{agent: NewPGAgent(), db: sql.OpenDB(&testSqlConn{}), mutex: &sync.Mutex{}}
+	RegisterHandler("test:Trace", func(ctx *Context, data []byte) *Return {
+		var v struct{ Name string }
+		if err := ctx.Unmarshal(data, &v); err != nil {
+			return &Return{Code: ErrActionExec, Message: err.Error()}
+		} else if tx, e := ctx.Tx().GetTx(); e != nil {
+			return &Return{Code: ErrInternal, Message: e.Error()}
+		} else if _, e := ctx.Tx().execStmt(tx, "SELECT 1;"); e != nil {
+			return &Return{Code: ErrInternal, Message: e.Error()}
+		}
+		return &Return{Data: v.Name}
+	})
+	defer func() {
+		gDBManager = nil
+		delete(gAPIMap, "test:Trace")
+	}()
+
+	assert(SetSpanExporter(NewOTLPExporter(collector.URL+"/", "test", map[string]string{"Authorization": "Bearer t"}))).IsNil()
+	ret := evalAction(&testResponse{headers: map[string]string{}}, &testRequest{
+		method:  http.MethodPost,
+		headers: map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		action:  "test:Trace",
+		data:    []byte(`{"Name": "x"}`),
+	})
+	assert(ret.Code, ret.Data).Equals(0, "x")
+	assert(SetSpanExporter(nil)).IsNil()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert(len(received)).Equals(1)
+	resourceSpans := received[0].ResourceSpans[0]
+	assert(*resourceSpans.Resource.Attributes[0].Value.StringValue).Equals("test")
+
+	spans := map[string]*otlpSpan{}
+	for _, span := range resourceSpans.ScopeSpans[0].Spans {
+		spans[span.Name] = span
+		assert(span.TraceID).Equals("4bf92f3577b34da6a3ce929d0e0e4736")
+	}
+	assert(len(spans)).Equals(3)
+
+	action := spans["action test:Trace"]
+	assert(action.ParentSpanID, action.Kind).Equals("00f067aa0ba902b7", SpanKindServer)
+	assert(spans["decode"].ParentSpanID).Equals(action.SpanID)
+	assert(spans["sql exec"].ParentSpanID, spans["sql exec"].Kind).Equals(action.SpanID, SpanKindClient)
+	assert(*spans["sql exec"].Attributes[0].Value.StringValue).Equals("SELECT 1;")
+}
+
+func TestSpan_disabled(t *testing.T) {
+	assert := utils.NewAssert(t)
+	span := StartSpan("action", SpanKindServer, "")
+	assert(span == nil).IsTrue()
+
+	// the methods of a nil span do nothing
+	child := span.StartChild("child", SpanKindInternal)
+	child.SetAttribute("key", "value")
+	child.SetError(io.EOF)
+	child.Finish()
+	assert(child == nil, span.TraceParent()).Equals(true, "")
+}
@@ -27,7 +27,9 @@ func toGolangName(name string) string {
 	}
 }
 
-func toGolangType(location string, goModule string, currentPackage string, name string) (string, string) {
+// toGolangType returns the go type of a meta type and its import, the types
+// of the runtime are qualified by goPackage that is imported from goModule
+func toGolangType(location string, goModule string, goPackage string, currentPackage string, name string) (string, string) {
 	name = strings.TrimSpace(name)
 
 	switch name {
@@ -39,17 +41,27 @@ func toGolangType(location string, goModule string, currentPackage string, name
 		return "int64", ""
 	case "Bool":
 		return "bool", ""
+	case "Any":
+		return "any", ""
 	case "Bytes":
 		return "[]byte", ""
+	case "Time":
+		return "time.Time", "\t\"time\""
+	case "Date":
+		return fmt.Sprintf("%s.Date", goPackage), fmt.Sprintf("\t\"%s\"", goModule)
+	case "GeoPoint":
+		return fmt.Sprintf("%s.GeoPoint", goPackage), fmt.Sprintf("\t\"%s\"", goModule)
+	case "Decimal", "UUID":
+		return "string", ""
 	default:
 		// if name is List<innter>, then return []inner
 		if strings.HasPrefix(name, "List<") && strings.HasSuffix(name, ">") {
 			innerType := name[5 : len(name)-1]
-			ret, pkg := toGolangType(location, goModule, currentPackage, innerType)
+			ret, pkg := toGolangType(location, goModule, goPackage, currentPackage, innerType)
 			return fmt.Sprintf("[]%s", ret), pkg
 		} else if strings.HasPrefix(name, "Map<") && strings.HasSuffix(name, ">") {
 			innerType := name[4 : len(name)-1] // Remove "Map<" and ">"
-			ret, pkg := toGolangType(location, goModule, currentPackage, innerType)
+			ret, pkg := toGolangType(location, goModule, goPackage, currentPackage, innerType)
 			return fmt.Sprintf("map[string]%s", ret), pkg
 		} else if strings.HasPrefix(name, DBPrefix) || strings.HasPrefix(name, APIPrefix) {
 			nameArr := strings.Split(name, "@")
@@ -71,6 +83,18 @@ func toGolangType(location string, goModule string, currentPackage string, name
 	}
 }
 
+// toGolangEnumConst converts an enum value to the name of its go constant,
+// e.g. ("Status", "in-review") => "StatusInReview"
+func toGolangEnumConst(enumName string, value string) string {
+	ret := enumName
+	for _, part := range strings.FieldsFunc(value, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		ret += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return ret
+}
+
 type GoBuilder struct{}
 
 func (p *GoBuilder) BuildClient(ctx *BuildContext) (map[string]string, error) {
@@ -88,12 +112,17 @@ func (p *GoBuilder) BuildServer(ctx *BuildContext) (map[string]string, error) {
 			fmt.Sprintf("assets/go/%s", goApiEngineMap[ctx.output.HttpEngine]),
 			fmt.Sprintf("assets/go/%s", goDBAgentMap[ctx.rtConfig.DB.Connect.Driver]),
 			"assets/go/pub_error.go",
+			"assets/go/server_admin.go",
 			"assets/go/server_common.go",
 			"assets/go/server_config.go",
+			"assets/go/server_cors.go",
 			"assets/go/server_db_common.go",
 			"assets/go/server_db_manager.go",
 			"assets/go/server_db_tx.go",
 			"assets/go/server_json.go",
+			"assets/go/server_log.go",
+			"assets/go/server_metrics.go",
+			"assets/go/server_trace.go",
 		},
 	)
 	if err != nil {
@@ -126,9 +155,53 @@ func (p *GoBuilder) BuildServer(ctx *BuildContext) (map[string]string, error) {
 		}
 	}
 
+	// build server config
+	if fileMap, err := p.buildServerConfig(ctx, metas); err != nil {
+		return nil, err
+	} else {
+		maps.Copy(ret, fileMap)
+	}
+
 	return ret, nil
 }
 
+// buildServerConfig builds the server config embedded into the server,
+// the cors methods are derived from the actions of the metas
+func (p *GoBuilder) buildServerConfig(ctx *BuildContext, metas []*APIMeta) (map[string]string, error) {
+	config := *ctx.rtConfig.Server
+
+	if config.CORS != nil {
+		cors := *config.CORS
+		if len(cors.Methods) == 0 {
+			for _, apiMeta := range metas {
+				for _, action := range apiMeta.Actions {
+					if method := strings.ToUpper(action.Method); !slices.Contains(cors.Methods, method) {
+						cors.Methods = append(cors.Methods, method)
+					}
+				}
+			}
+			slices.Sort(cors.Methods)
+		}
+
+		// the client sends the json data with Content-Type
+		if !slices.ContainsFunc(cors.Headers, func(header string) bool {
+			return strings.EqualFold(header, "Content-Type")
+		}) {
+			cors.Headers = append([]string{"Content-Type"}, cors.Headers...)
+		}
+
+		config.CORS = &cors
+	}
+
+	if configContent, err := json.MarshalIndent(config, "", "  "); err != nil {
+		return nil, fmt.Errorf("failed to marshal server config: %v", err)
+	} else {
+		return map[string]string{
+			filepath.Join(ctx.output.Dir, "server.json"): string(configContent),
+		}, nil
+	}
+}
+
 func (p *GoBuilder) buildServerWithMeta(ctx *BuildContext, apiMeta *APIMeta) (map[string]string, error) {
 	if apiMeta.Namespace == "" {
 		return nil, fmt.Errorf("namespace is required")
@@ -150,22 +223,61 @@ func (p *GoBuilder) buildServerWithMeta(ctx *BuildContext, apiMeta *APIMeta) (ma
 
 	needImportBasePackage := false
 
+	// enums
+	for _, name := range slices.Sorted(maps.Keys(apiMeta.Enums)) {
+		enum := apiMeta.Enums[name]
+		consts := []string{}
+		constNames := []string{}
+		// the const names are checked by CollectEnums
+		for _, value := range enum.Values {
+			constName := toGolangEnumConst(name, value)
+			constNames = append(constNames, constName)
+			consts = append(consts, fmt.Sprintf("\t%s %s = %q", constName, name, value))
+		}
+
+		defines = append(defines, fmt.Sprintf(
+			"// enum: %s@%s",
+			apiMeta.Namespace,
+			name,
+		))
+		defines = append(defines, fmt.Sprintf(
+			"type %s string\n\nconst (\n%s\n)\n",
+			name,
+			strings.Join(consts, "\n"),
+		))
+		defines = append(defines, fmt.Sprintf(
+			"func (v %s) Valid() bool {\n\tswitch v {\n\tcase %s:\n\t\treturn true\n\tdefault:\n\t\treturn false\n\t}\n}\n",
+			name,
+			strings.Join(constNames, ", "),
+		))
+	}
+
 	// definitions
-	for name, define := range apiMeta.Definitions {
+	for _, name := range slices.Sorted(maps.Keys(apiMeta.Definitions)) {
+		define := apiMeta.Definitions[name]
 		if len(define.Attributes) > 0 {
 			attributes := []string{}
 			fullDefineName := apiMeta.Namespace + "@" + name
 			for _, attribute := range define.Attributes {
-				attrType, pkg := toGolangType(ctx.location, ctx.output.GoModule, currentPackage, attribute.Type)
+				attrType, pkg := toGolangType(ctx.location, ctx.output.GoModule, ctx.output.GoPackage, currentPackage, attribute.Type)
 				if pkg != "" {
 					imports = append(imports, pkg)
 				}
 
+				jsonName := attribute.Name
+				if define.Partial && !attribute.Required {
+					// nil means the attribute is omitted
+					if !strings.HasPrefix(attrType, "[]") && !strings.HasPrefix(attrType, "map[") {
+						attrType = "*" + attrType
+					}
+					jsonName += ",omitempty"
+				}
+
 				attributes = append(attributes, fmt.Sprintf(
 					"\t%s %s `json:\"%s\" required:\"%t\"`",
 					toGolangName(attribute.Name),
 					attrType,
-					attribute.Name,
+					jsonName,
 					attribute.Required,
 				))
 			}
@@ -209,11 +321,69 @@ func (p *GoBuilder) buildServerWithMeta(ctx *BuildContext, apiMeta *APIMeta) (ma
 		}
 	}
 
+	// errors
+	for _, name := range slices.Sorted(maps.Keys(apiMeta.Errors)) {
+		apiError := apiMeta.Errors[name]
+		format, args := apiError.Format()
+		parameters := []string{}
+		for _, parameter := range apiError.Parameters() {
+			parameters = append(parameters, parameter+" any")
+		}
+
+		errorStr := fmt.Sprintf("%s.Errorf(%q", ctx.output.GoPackage, format)
+		if len(args) > 0 {
+			errorStr += ", " + strings.Join(args, ", ")
+		}
+		errorStr += fmt.Sprintf(").SetCode(ErrCode%s)", name)
+		if apiError.Status != 0 {
+			errorStr += fmt.Sprintf(".SetStatus(%d)", apiError.Status)
+		}
+
+		defines = append(defines, fmt.Sprintf(
+			"// error: %s@%s",
+			apiMeta.Namespace,
+			name,
+		))
+		defines = append(defines, fmt.Sprintf(
+			"const ErrCode%s = %d\n",
+			name,
+			apiError.Code,
+		))
+		if apiError.Description != "" {
+			defines = append(defines, fmt.Sprintf("// Err%s: %s", name, apiError.Description))
+		}
+		defines = append(defines, fmt.Sprintf(
+			"func Err%s(%s) *%s.Error {\n\treturn %s\n}\n",
+			name,
+			strings.Join(parameters, ", "),
+			ctx.output.GoPackage,
+			errorStr,
+		))
+
+		registerFuncs = append(registerFuncs, fmt.Sprintf(
+			"\t%s.RegisterPublicErrorCode(ErrCode%s)",
+			ctx.output.GoPackage,
+			name,
+		))
+		needImportBasePackage = true
+	}
+
+	// db table functions
+	if strings.HasPrefix(apiMeta.Namespace, DBPrefix) {
+		if dbFuncs, err := p.buildDBTableFuncs(ctx, apiMeta); err != nil {
+			return nil, err
+		} else {
+			defines = append(defines, dbFuncs...)
+			needImportBasePackage = true
+		}
+	}
+
 	// actions
 	if len(apiMeta.Actions) > 0 {
 		needImportBasePackage = true
 
-		for name, action := range apiMeta.Actions {
+		for _, name := range slices.Sorted(maps.Keys(apiMeta.Actions)) {
+			action := apiMeta.Actions[name]
 			parameters := []string{
 				fmt.Sprintf("ctx *%s.Context", ctx.output.GoPackage),
 			}
@@ -223,7 +393,7 @@ func (p *GoBuilder) buildServerWithMeta(ctx *BuildContext, apiMeta *APIMeta) (ma
 			}
 			fullActionName := apiMeta.Namespace + ":" + name
 			for _, parameter := range action.Parameters {
-				typeName, typePkg := toGolangType(ctx.location, ctx.output.GoModule, currentPackage, parameter.Type)
+				typeName, typePkg := toGolangType(ctx.location, ctx.output.GoModule, ctx.output.GoPackage, currentPackage, parameter.Type)
 				if typePkg != "" {
 					imports = append(imports, typePkg)
 				}
@@ -243,7 +413,7 @@ func (p *GoBuilder) buildServerWithMeta(ctx *BuildContext, apiMeta *APIMeta) (ma
 				callParameters = append(callParameters, "v."+goParameterName)
 			}
 
-			returnType, typePkg := toGolangType(ctx.location, ctx.output.GoModule, currentPackage, action.Return.Type)
+			returnType, typePkg := toGolangType(ctx.location, ctx.output.GoModule, ctx.output.GoPackage, currentPackage, action.Return.Type)
 			if typePkg != "" {
 				imports = append(imports, typePkg)
 			}
@@ -278,7 +448,10 @@ func (p *GoBuilder) buildServerWithMeta(ctx *BuildContext, apiMeta *APIMeta) (ma
 
 			if len(structParameters) > 0 {
 				funcBody += fmt.Sprintf("\n\t\tvar v struct {\n\t%s\n\t\t}", strings.Join(structParameters, "\n"))
-				funcBody += fmt.Sprintf("\n\t\tif err := %s.JsonUnmarshal(data, &v); err != nil {\n\t\t\treturn nil\n\t\t}\n", ctx.output.GoPackage)
+				funcBody += fmt.Sprintf(
+					"\n\t\tif err := ctx.Unmarshal(data, &v); err != nil {\n\t\t\treturn &%s.Return{Code: %s.ErrActionDecode, Message: err.Error(), Err: err}\n\t\t}\n",
+					ctx.output.GoPackage, ctx.output.GoPackage,
+				)
 			}
 
 			funcBody += fmt.Sprintf(
@@ -287,13 +460,13 @@ func (p *GoBuilder) buildServerWithMeta(ctx *BuildContext, apiMeta *APIMeta) (ma
 			)
 			if returnType == "" {
 				funcBody += fmt.Sprintf(
-					" else if err := fn%s(%s); err != nil {\n\t\t\treturn &%s.Return{Code: err.Code(), Message: err.Error()}\n\t\t}",
+					" else if err := fn%s(%s); err != nil {\n\t\t\treturn &%s.Return{Code: err.Code(), Message: err.Error(), Err: err}\n\t\t}",
 					name, strings.Join(callParameters, ", "), ctx.output.GoPackage,
 				)
 				funcBody += fmt.Sprintf(" else {\n\t\t\treturn &%s.Return{}\n\t\t}", ctx.output.GoPackage)
 			} else {
 				funcBody += fmt.Sprintf(
-					" else if result, err := fn%s(%s); err != nil {\n\t\t\treturn &%s.Return{Code: err.Code(), Message: err.Error()}\n\t\t}",
+					" else if result, err := fn%s(%s); err != nil {\n\t\t\treturn &%s.Return{Code: err.Code(), Message: err.Error(), Err: err}\n\t\t}",
 					name, strings.Join(callParameters, ", "), ctx.output.GoPackage,
 				)
 				funcBody += fmt.Sprintf(" else {\n\t\t\treturn &%s.Return{Data: result}\n\t\t}", ctx.output.GoPackage)
@@ -316,6 +489,7 @@ func (p *GoBuilder) buildServerWithMeta(ctx *BuildContext, apiMeta *APIMeta) (ma
 
 	importsContent := ""
 	if len(imports) > 0 {
+		slices.Sort(imports)
 		imports = slices.Compact(imports)
 		importsContent = fmt.Sprintf("import (\n%s\n)\n", strings.Join(imports, "\n")) + "\n"
 	}
@@ -353,21 +527,29 @@ import "embed"
 
 //go:embed all:db
 var gDBAssets embed.FS
-var gDBManager *SQLManager
 
-func GetDBManager() *SQLManager {
-	return gDBManager
-}
+//go:embed server.json
+var gServerConfigContent string
 
+// the tables are migrated by Server.Run after it listens,
+// call GetDBManager().Open() to use the db without the server
 func init() {
 	if dbManager, err := NewSQLManager(&gDBAssets); err != nil {
 		panic(DebugError(err))
-	} else if err := dbManager.Open(); err != nil {
-		panic(DebugError(err))
 	} else {
 		gDBManager = dbManager
 	}
 }
+
+// Run runs the server of the config embedded at build time, the file of
+// CAPI_SERVER_CONFIG and the CAPI_SERVER_* environment variables override it
+func Run() error {
+	if config, err := LoadServerConfig(gServerConfigContent); err != nil {
+		return err
+	} else {
+		return NewServer(config).Run()
+	}
+}
 `
 	ret := map[string]string{}
 
@@ -389,7 +571,7 @@ func init() {
 
 	tableDir := filepath.Join(assetDir, "tables")
 	for _, dbMeta := range ctx.dbMetas {
-		if dbTable, err := dbMeta.ToDBTable(); err != nil {
+		if dbTable, err := dbMeta.ToDBTable(ctx.enums); err != nil {
 			return nil, err
 		} else if tableContent, err := json.MarshalIndent(dbTable, "", "  "); err != nil {
 			return nil, fmt.Errorf("failed to marshal db table: %v", err)
@@ -400,3 +582,143 @@ func init() {
 
 	return ret, nil
 }
+
+// buildDBTableFuncs builds the functions to write and read the table of a db meta,
+// the views of the table are read by Query<View> and Get<View>
+func (p *GoBuilder) buildDBTableFuncs(ctx *BuildContext, apiMeta *APIMeta) ([]string, error) {
+	rt := ctx.output.GoPackage
+	ret := []string{}
+
+	dbMeta := ctx.getDBTableMeta(apiMeta.Namespace)
+	if dbMeta == nil {
+		return nil, fmt.Errorf("%s: db meta not found", apiMeta.Namespace)
+	}
+
+	views := slices.Sorted(maps.Keys(dbMeta.Views))
+	for _, name := range views {
+		switch name {
+		case "Insert", "InsertMany", "Upsert", "Modify", "UpdateMany", "Remove", "Restore", "Table":
+			return nil, fmt.Errorf("%s: views.%s: the name is reserved", apiMeta.Namespace, name)
+		}
+	}
+
+	ret = append(ret, fmt.Sprintf(
+		"// table: %s\nconst Table = %q\n",
+		apiMeta.Namespace,
+		NamespaceToTableName(apiMeta.Namespace),
+	))
+
+	// insert
+	if create := apiMeta.Definitions["Create"]; create != nil {
+		values := []string{}
+		for _, attribute := range create.Attributes {
+			values = append(values, fmt.Sprintf("\t\t%q: v.%s,", attribute.Name, toGolangName(attribute.Name)))
+		}
+		ret = append(ret, fmt.Sprintf(
+			"func createRecord(v Create) %s.Record {\n\treturn %s.Record{\n%s\n\t}\n}\n",
+			rt, rt, strings.Join(values, "\n"),
+		))
+		ret = append(ret, fmt.Sprintf(
+			"// Insert inserts a record and returns its id\nfunc Insert(ctx *%s.Context, v Create) (string, *%s.Error) {\n\treturn %s.DBInsert(ctx, Table, createRecord(v))\n}\n",
+			rt, rt, rt,
+		))
+		ret = append(ret, fmt.Sprintf(
+			"// InsertMany inserts the records and returns their ids\nfunc InsertMany(ctx *%s.Context, vs []Create) ([]string, *%s.Error) {\n\trecords := make([]%s.Record, len(vs))\n\tfor i, v := range vs {\n\t\trecords[i] = createRecord(v)\n\t}\n\treturn %s.DBInsertMany(ctx, Table, records)\n}\n",
+			rt, rt, rt, rt,
+		))
+		ret = append(ret, fmt.Sprintf(
+			"// Upsert inserts the records, a record that has the same value of the id or\n// unique column as an existing record updates it, it returns their ids\nfunc Upsert(ctx *%s.Context, column string, vs []Create) ([]string, *%s.Error) {\n\trecords := make([]%s.Record, len(vs))\n\tfor i, v := range vs {\n\t\trecords[i] = createRecord(v)\n\t}\n\treturn %s.DBUpsert(ctx, Table, column, records)\n}\n",
+			rt, rt, rt, rt,
+		))
+	}
+
+	// modify
+	if update := apiMeta.Definitions["Update"]; update != nil {
+		values := []string{}
+		for _, attribute := range update.Attributes {
+			if attribute.Name == "id" {
+				continue
+			}
+			if attribute.Required {
+				values = append(values, fmt.Sprintf("\trecord[%q] = v.%s", attribute.Name, toGolangName(attribute.Name)))
+				continue
+			}
+			attrType, _ := toGolangType(ctx.location, ctx.output.GoModule, ctx.output.GoPackage, "", attribute.Type)
+			value := "v." + toGolangName(attribute.Name)
+			if !strings.HasPrefix(attrType, "[]") && !strings.HasPrefix(attrType, "map[") {
+				value = "*" + value
+			}
+			values = append(values, fmt.Sprintf(
+				"\tif v.%s != nil {\n\t\trecord[%q] = %s\n\t}",
+				toGolangName(attribute.Name), attribute.Name, value,
+			))
+		}
+		ret = append(ret, fmt.Sprintf(
+			"// Modify updates the attributes of the record that are not omitted\nfunc Modify(ctx *%s.Context, v Update) *%s.Error {\n\trecord := %s.Record{}\n%s\n\treturn %s.DBUpdate(ctx, Table, v.Id, record)\n}\n",
+			rt, rt, rt, strings.Join(values, "\n"), rt,
+		))
+	}
+
+	// remove
+	ret = append(ret, fmt.Sprintf(
+		"// Remove deletes the record\nfunc Remove(ctx *%s.Context, v Delete) *%s.Error {\n\treturn %s.DBDelete(ctx, Table, v.Id)\n}\n",
+		rt, rt, rt,
+	))
+
+	// restore
+	softDelete := dbMeta.SoftDelete
+	if softDelete {
+		ret = append(ret, fmt.Sprintf(
+			"// Restore restores the deleted record\nfunc Restore(ctx *%s.Context, v Delete) *%s.Error {\n\treturn %s.DBRestore(ctx, Table, v.Id)\n}\n",
+			rt, rt, rt,
+		))
+	}
+
+	// query
+	queryOptions := ""
+	if softDelete {
+		queryOptions = "\n\tif q.IncludeDeleted != nil && *q.IncludeDeleted {\n\t\tquery.IncludeDeleted()\n\t}"
+	}
+	if searchColumns := dbMeta.getSearchColumns(); len(searchColumns) > 0 {
+		searchWheres := []string{}
+		searchOrders := []string{}
+		for _, column := range searchColumns {
+			searchWheres = append(searchWheres, fmt.Sprintf(".Or(%q, %s.SqlSearch, *q.Search)", column, rt))
+			searchOrders = append(searchOrders, fmt.Sprintf("\t\t\tquery.OrderBySearch(%q, *q.Search)", column))
+		}
+		queryOptions += fmt.Sprintf(
+			"\n\tif q.Search != nil && *q.Search != \"\" {\n\t\tquery.AndChild(%s.NewQuery(Table)%s)\n\t\tif len(q.Orders) == 0 {\n%s\n\t\t}\n\t}",
+			rt, strings.Join(searchWheres, ""), strings.Join(searchOrders, "\n"),
+		)
+	}
+	ret = append(ret, fmt.Sprintf(
+		"func newQuery(q Query) *%s.SqlQuery {\n\tquery := %s.NewWebQuery(Table, q.Where, q.Orders)\n\tif q.Limit != nil {\n\t\tquery.Limit(int(*q.Limit))\n\t}\n\tif q.Offset != nil {\n\t\tquery.Offset(int(*q.Offset))\n\t}\n\tif q.Cursor != nil {\n\t\tquery.Cursor(*q.Cursor)\n\t}%s\n\treturn query\n}\n",
+		rt, rt, queryOptions,
+	))
+
+	ret = append(ret, fmt.Sprintf(
+		"// UpdateMany updates the columns in record of the records matched by the\n// where of q and returns the count of the updated records\nfunc UpdateMany(ctx *%s.Context, q Query, record %s.Record) (int64, *%s.Error) {\n\treturn %s.DBUpdateMany(ctx, newQuery(q), record)\n}\n",
+		rt, rt, rt, rt,
+	))
+
+	for _, view := range views {
+		ret = append(ret, fmt.Sprintf(
+			"// Query%s returns the %s view of the matched records\nfunc Query%s(ctx *%s.Context, q Query) ([]%s, *%s.Error) {\n\tret := []%s{}\n\tif err := %s.DBQuery(ctx, newQuery(q).View(%q), &ret); err != nil {\n\t\treturn nil, err\n\t}\n\treturn ret, nil\n}\n",
+			view, view, view, rt, view, rt, view, rt, view,
+		))
+		ret = append(ret, fmt.Sprintf(
+			"// Query%sPage returns a page of the %s view of the matched records\nfunc Query%sPage(ctx *%s.Context, q Query) (*%sPage, *%s.Error) {\n\tret := &%sPage{Items: []%s{}}\n\tif cursor, err := %s.DBQueryPage(ctx, newQuery(q).View(%q), &ret.Items); err != nil {\n\t\treturn nil, err\n\t} else {\n\t\tret.NextCursor = cursor\n\t\treturn ret, nil\n\t}\n}\n",
+			view, view, view, rt, view, rt, view, view, rt, view,
+		))
+		ret = append(ret, fmt.Sprintf(
+			"// Count%s returns the count of the records matched by Query%s without limit and offset\nfunc Count%s(ctx *%s.Context, q Query) (int64, *%s.Error) {\n\treturn %s.DBCount(ctx, newQuery(q).View(%q))\n}\n",
+			view, view, view, rt, rt, rt, view,
+		))
+		ret = append(ret, fmt.Sprintf(
+			"// Get%s returns the %s view of the record\nfunc Get%s(ctx *%s.Context, id string) (*%s, *%s.Error) {\n\tvar ret %s\n\tif err := %s.DBGet(ctx, Table, %q, id, &ret); err != nil {\n\t\treturn nil, err\n\t}\n\treturn &ret, nil\n}\n",
+			view, view, view, rt, view, rt, view, rt, view,
+		))
+	}
+
+	return ret, nil
+}
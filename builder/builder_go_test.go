@@ -0,0 +1,62 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ootiny/capi/utils"
+)
+
+func TestToGolangType(t *testing.T) {
+	t.Run("runtime types", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		// the go package can differ from the last element of the go module
+		assert(toGolangType(MainLocation, "example.com/app/rt", "capirt", "db_city", "Date")).Equals(
+			"capirt.Date", "\t\"example.com/app/rt\"",
+		)
+		assert(toGolangType(MainLocation, "example.com/app/rt", "capirt", "db_city", "List<GeoPoint>")).Equals(
+			"[]capirt.GeoPoint", "\t\"example.com/app/rt\"",
+		)
+	})
+
+	t.Run("meta types", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(toGolangType(MainLocation, "example.com/app/rt", "capirt", "db_city", "DB.City@Full")).Equals("Full", "")
+		assert(toGolangType(MainLocation, "example.com/app/rt", "capirt", "", "Map<Int64>")).Equals("map[string]int64", "")
+	})
+}
+
+func TestToGolangEnumConst(t *testing.T) {
+	assert := utils.NewAssert(t)
+	assert(toGolangEnumConst("Status", "open")).Equals("StatusOpen")
+	assert(toGolangEnumConst("Status", "in-review")).Equals("StatusInReview")
+	assert(toGolangEnumConst("Status", "in_review")).Equals("StatusInReview")
+	assert(toGolangEnumConst("Status", "2nd level")).Equals("Status2ndLevel")
+	assert(toGolangEnumConst("Status", "-")).Equals("Status")
+}
+
+func TestGoBuilder_buildServerWithMeta_enums(t *testing.T) {
+	assert := utils.NewAssert(t)
+	ctx := &BuildContext{
+		location: MainLocation,
+		output:   &RTOutputConfig{Dir: "/out", GoModule: "example.com/app/rt", GoPackage: "rt"},
+	}
+
+	fileMap, e := (&GoBuilder{}).buildServerWithMeta(ctx, &APIMeta{
+		Namespace: "API.City",
+		Enums:     map[string]*EnumMeta{"Status": {Values: []string{"open", "in-review"}}},
+	})
+	assert(e).IsNil()
+	assert(len(fileMap)).Equals(1)
+
+	for _, content := range fileMap {
+		assert(strings.Contains(
+			content,
+			"type Status string\n\nconst (\n\tStatusOpen Status = \"open\"\n\tStatusInReview Status = \"in-review\"\n)\n",
+		)).IsTrue()
+		assert(strings.Contains(
+			content,
+			"func (v Status) Valid() bool {\n\tswitch v {\n\tcase StatusOpen, StatusInReview:\n\t\treturn true\n\tdefault:\n\t\treturn false\n\t}\n}\n",
+		)).IsTrue()
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"maps"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/ootiny/capi/utils"
@@ -21,7 +22,17 @@ func toTypeScriptType(location string, currentPackage string, name string) (stri
 		return "number", ""
 	case "Bool":
 		return "boolean", ""
+	case "Any":
+		return "unknown", ""
 	case "Bytes":
+		// base64 encoded
+		return "string", ""
+	case "Time", "Date":
+		// ISO 8601, e.g. "2006-01-02T15:04:05Z" and "2006-01-02"
+		return "string", ""
+	case "GeoPoint":
+		return "{ latitude: number; longitude: number }", ""
+	case "Decimal", "UUID":
 		return "string", ""
 	default:
 		// if name is List<innter>, then return []inner
@@ -59,6 +70,29 @@ func (p *TypescriptBuilder) BuildServer(ctx *BuildContext) (map[string]string, e
 	return nil, fmt.Errorf("not implemented")
 }
 
+// getPageAction returns the item type and the query parameter name if the
+// action takes a db query and returns a page of a view, e.g. DB.City@FullPage
+func (p *TypescriptBuilder) getPageAction(ctx *BuildContext, action *APIActionMeta) (string, string) {
+	namespace, pageName, ok := strings.Cut(action.Return.Type, "@")
+	if !ok || !strings.HasPrefix(namespace, DBPrefix) || !strings.HasSuffix(pageName, "Page") {
+		return "", ""
+	}
+
+	dbMeta := ctx.getDBTableMeta(namespace)
+	viewName := strings.TrimSuffix(pageName, "Page")
+	if dbMeta == nil || dbMeta.Views[viewName] == nil {
+		return "", ""
+	}
+
+	for _, parameter := range action.Parameters {
+		if parameter.Type == namespace+"@Query" {
+			return namespace + "@" + viewName, parameter.Name
+		}
+	}
+
+	return "", ""
+}
+
 func (p *TypescriptBuilder) BuildClient(ctx *BuildContext) (map[string]string, error) {
 	ret, err := CopyAssetsFiles(
 		ctx.output.Dir,
@@ -123,9 +157,31 @@ func (p *TypescriptBuilder) buildClientWithMetaNode(ctx *BuildContext, metaNode
 
 	// needImportFetchJson := false
 
+	// enums
+	if metaNode.meta != nil {
+		for _, name := range slices.Sorted(maps.Keys(metaNode.meta.Enums)) {
+			values := []string{}
+			for _, value := range metaNode.meta.Enums[name].Values {
+				values = append(values, fmt.Sprintf("%q", value))
+			}
+
+			defines = append(defines, fmt.Sprintf(
+				"// enum: %s@%s",
+				metaNode.meta.Namespace,
+				name,
+			))
+			defines = append(defines, fmt.Sprintf(
+				"export type %s = %s;\n",
+				name,
+				strings.Join(values, " | "),
+			))
+		}
+	}
+
 	// definitions
 	if metaNode.meta != nil {
-		for name, define := range metaNode.meta.Definitions {
+		for _, name := range slices.Sorted(maps.Keys(metaNode.meta.Definitions)) {
+			define := metaNode.meta.Definitions[name]
 			if len(define.Attributes) > 0 {
 				attributes := []string{}
 				fullDefineName := metaNode.meta.Namespace + "@" + name
@@ -135,9 +191,14 @@ func (p *TypescriptBuilder) buildClientWithMetaNode(ctx *BuildContext, metaNode
 						imports = append(imports, pkg)
 					}
 
+					attrName := attribute.Name
+					if define.Partial && !attribute.Required {
+						attrName += "?"
+					}
+
 					attributes = append(attributes, fmt.Sprintf(
 						"  %s: %s;",
-						attribute.Name,
+						attrName,
 						attrType,
 					))
 				}
@@ -156,10 +217,37 @@ func (p *TypescriptBuilder) buildClientWithMetaNode(ctx *BuildContext, metaNode
 		}
 	}
 
+	clientUtils := []string{}
+
+	// errors, fetchJson throws the registered class of the code
+	if metaNode.meta != nil && len(metaNode.meta.Errors) > 0 {
+		clientUtils = append(clientUtils, "ApiError", "registerApiError")
+		for _, name := range slices.Sorted(maps.Keys(metaNode.meta.Errors)) {
+			apiError := metaNode.meta.Errors[name]
+			className := name + "Error"
+
+			defines = append(defines, fmt.Sprintf(
+				"// error: %s@%s",
+				metaNode.meta.Namespace,
+				name,
+			))
+			defines = append(defines, fmt.Sprintf(
+				"export class %s extends ApiError {\n\tdeclare readonly code: %d;\n\tconstructor(message: string, requestId?: string) {\n\t\tsuper(%d, message, requestId);\n\t\tthis.name = %q;\n\t}\n}\nregisterApiError(%d, %s);\n",
+				className,
+				apiError.Code,
+				apiError.Code,
+				className,
+				apiError.Code,
+				className,
+			))
+		}
+	}
+
 	// actions
 	if metaNode.meta != nil && len(metaNode.meta.Actions) > 0 {
-		imports = append(imports, "import { fetchJson } from \"../client_utils\";")
-		for name, action := range metaNode.meta.Actions {
+		clientUtils = append(clientUtils, "fetchJson")
+		for _, name := range slices.Sorted(maps.Keys(metaNode.meta.Actions)) {
+			action := metaNode.meta.Actions[name]
 			if len(action.Parameters) > 0 {
 				attributes := []string{}
 				dataAttrs := []string{}
@@ -194,15 +282,52 @@ func (p *TypescriptBuilder) buildClientWithMetaNode(ctx *BuildContext, metaNode
 				actionStr += fmt.Sprintf("\t\treturn fetchJson(this.url, \"%s\", \"%s\", { %s })\n", fullActionName, method, strings.Join(dataAttrs, ", "))
 				actionStr += "\t}\n"
 
+				// a page action can be iterated by the items of all the pages
+				if itemType, queryName := p.getPageAction(ctx, action); itemType != "" {
+					iteratorName := name + "Iterator"
+					if _, ok := metaNode.meta.Actions[iteratorName]; ok {
+						return nil, fmt.Errorf("%s: the action name is used by the iterator of %s", iteratorName, name)
+					}
+
+					itemType, pkg := toTypeScriptType(ctx.location, currentPackage, itemType)
+					if pkg != "" {
+						imports = append(imports, pkg)
+					}
+
+					callAttrs := []string{}
+					for _, attribute := range action.Parameters {
+						if attribute.Name == queryName {
+							callAttrs = append(callAttrs, fmt.Sprintf("{ ...%s, cursor }", attribute.Name))
+						} else {
+							callAttrs = append(callAttrs, attribute.Name)
+						}
+					}
+
+					clientUtils = append(clientUtils, "paginate")
+					actionStr += fmt.Sprintf("\n\t// iterator: %s\n", fullActionName)
+					actionStr += fmt.Sprintf("\tasync *%s(%s): AsyncGenerator<%s> {\n", iteratorName, strings.Join(attributes, ", "), itemType)
+					actionStr += fmt.Sprintf("\t\tyield* paginate((cursor) => this.%s(%s));\n", name, strings.Join(callAttrs, ", "))
+					actionStr += "\t}\n"
+				}
+
 				actions = append(actions, actionStr)
 			}
 		}
 	}
 
+	if len(clientUtils) > 0 {
+		slices.Sort(clientUtils)
+		imports = append(imports, fmt.Sprintf(
+			"import { %s } from \"../client_utils\";",
+			strings.Join(slices.Compact(clientUtils), ", "),
+		))
+	}
+
 	// children
 	childrenDefineContent := ""
 	childrenConstructorContent := ""
-	for name, child := range metaNode.children {
+	for _, name := range slices.Sorted(maps.Keys(metaNode.children)) {
+		child := metaNode.children[name]
 		tagetPackage := NamespaceToFolder(ctx.location, child.namespace)
 		if tagetPackage != currentPackage {
 			if metaNode.namespace == "API" {
@@ -223,7 +348,7 @@ func (p *TypescriptBuilder) buildClientWithMetaNode(ctx *BuildContext, metaNode
 		for _, importStr := range imports {
 			importMap[importStr] = true
 		}
-		for importStr := range importMap {
+		for _, importStr := range slices.Sorted(maps.Keys(importMap)) {
 			importsContent += importStr + "\n"
 		}
 	}
@@ -34,14 +34,22 @@ const APIPrefix = "API."
 var DBBaseTypes = []string{
 	"PK", "Bool", "Int64", "Float64",
 	"String", "String16", "String32", "String64", "String256",
+	"Time", "Date", "Decimal", "UUID", "Bytes", "GeoPoint",
 	"List<String>", "Map<String>",
 }
 
 var APIBaseTypes = []string{
+	"Any",
 	"String",
 	"Float64",
 	"Int64",
 	"Bool",
+	"Time",
+	"Date",
+	"Decimal",
+	"UUID",
+	"Bytes",
+	"GeoPoint",
 }
 
 // 将DB类型转换为API类型
@@ -89,6 +97,8 @@ func DBTypeToApiType(dbColumnType string, viewName string) (string, error) {
 		return "Float64", nil
 	case "String", "String16", "String32", "String64", "String256":
 		return "String", nil
+	case "Time", "Date", "Decimal", "UUID", "Bytes", "GeoPoint":
+		return dbColumnType, nil
 	case "List<String>":
 		return "List<String>", nil
 	case "Map<String>":
@@ -96,18 +106,25 @@ func DBTypeToApiType(dbColumnType string, viewName string) (string, error) {
 	default:
 		if strings.HasPrefix(dbColumnType, "List<") && strings.HasSuffix(dbColumnType, ">") {
 			innerType := dbColumnType[5 : len(dbColumnType)-1]
-			if strings.HasPrefix(innerType, DBPrefix) {
-				return DBTypeToApiType(innerType, viewName)
-			} else {
+			if !strings.HasPrefix(innerType, DBPrefix) {
 				return "", fmt.Errorf("invalid column type: %s", dbColumnType)
+			} else if apiType, err := DBTypeToApiType(innerType, viewName); err != nil {
+				return "", err
+			} else {
+				return "List<" + apiType + ">", nil
 			}
 		} else if strings.HasPrefix(dbColumnType, "Map<") && strings.HasSuffix(dbColumnType, ">") {
 			innerType := dbColumnType[4 : len(dbColumnType)-1]
-			if strings.HasPrefix(innerType, DBPrefix) {
-				return DBTypeToApiType(innerType, viewName)
-			} else {
+			if !strings.HasPrefix(innerType, DBPrefix) {
 				return "", fmt.Errorf("invalid column type: %s", dbColumnType)
+			} else if apiType, err := DBTypeToApiType(innerType, viewName); err != nil {
+				return "", err
+			} else {
+				return "Map<" + apiType + ">", nil
 			}
+		} else if strings.HasPrefix(dbColumnType, APIPrefix) && strings.Contains(dbColumnType, "@") {
+			// enum declared in an api meta
+			return dbColumnType, nil
 		} else if strings.HasPrefix(dbColumnType, DBPrefix) {
 			columnArray := strings.Split(dbColumnType, "@")
 			if len(columnArray) == 2 {
@@ -123,6 +140,20 @@ func DBTypeToApiType(dbColumnType string, viewName string) (string, error) {
 	}
 }
 
+// DBTypeToLinkIDType converts link column types to the types of the stored
+// ids, e.g. "List<DB.Geo>" => "List<String>", other types are unchanged.
+func DBTypeToLinkIDType(dbColumnType string) string {
+	if strings.HasPrefix(dbColumnType, "List<"+DBPrefix) {
+		return "List<String>"
+	} else if strings.HasPrefix(dbColumnType, "Map<"+DBPrefix) {
+		return "Map<String>"
+	} else if strings.HasPrefix(dbColumnType, DBPrefix) && !strings.Contains(dbColumnType, "@") {
+		return "String"
+	} else {
+		return dbColumnType
+	}
+}
+
 func DBTypeToTableColumn(dbType string) (*DBTableColumn, error) {
 	strType := ""
 	strTable := ""
@@ -137,6 +168,8 @@ func DBTypeToTableColumn(dbType string) (*DBTableColumn, error) {
 		strType = "Float64"
 	case "String", "String16", "String32", "String64", "String256":
 		strType = "String"
+	case "Time", "Date", "Decimal", "UUID", "Bytes", "GeoPoint":
+		strType = dbType
 	case "List<String>":
 		strType = "List<String>"
 	case "Map<String>":
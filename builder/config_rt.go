@@ -17,10 +17,17 @@ type RTOutputConfig struct {
 }
 
 type RTConfig struct {
-	Listen       string            `json:"listen"`
-	Outputs      []*RTOutputConfig `json:"outputs"`
-	DB           *DBConfig         `json:"db"`
-	__filepath__ string
+	Listen  string            `json:"listen"`
+	Outputs []*RTOutputConfig `json:"outputs"`
+	DB      *DBConfig         `json:"db"`
+	// Server is the config of the generated server, the top level listen and
+	// adminAddress are the defaults of its fields
+	Server       *ServerConfig `json:"server"`
+	AdminAddress string        `json:"adminAddress"`
+	// AllowLiteralSecrets allows the literal secrets to be embedded into the
+	// server, otherwise they must be references like ${env:NAME}
+	AllowLiteralSecrets bool `json:"allowLiteralSecrets"`
+	__filepath__        string
 }
 
 func (c *RTConfig) GetFilePath() string {
@@ -64,6 +71,30 @@ func LoadRTConfig() (*RTConfig, error) {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	if config.DB != nil {
+		if err := config.DB.check(); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Server == nil {
+		config.Server = &ServerConfig{}
+	}
+	if config.Server.Listen == "" {
+		config.Server.Listen = config.Listen
+	}
+	if config.Server.Listen == "" {
+		config.Server.Listen = "0.0.0.0:8080"
+	}
+	if config.Server.AdminAddress == "" {
+		config.Server.AdminAddress = config.AdminAddress
+	}
+	if err := config.Server.check(); err != nil {
+		return nil, err
+	} else if err := config.checkSecrets(); err != nil {
+		return nil, err
+	}
+
 	projectDir := filepath.Dir(configPath)
 
 	for i, output := range config.Outputs {
@@ -87,3 +118,30 @@ func LoadRTConfig() (*RTConfig, error) {
 
 	return &config, nil
 }
+
+// checkSecrets refuses the literal secrets of the db and server configs, the
+// configs are embedded into the server and the references are resolved at
+// server start
+func (c *RTConfig) checkSecrets() error {
+	if c.AllowLiteralSecrets {
+		return nil
+	}
+
+	secrets := []struct{ name, value string }{
+		{"server.adminToken", c.Server.AdminToken},
+	}
+	if c.DB != nil {
+		secrets = append(secrets, struct{ name, value string }{"db.connect.password", c.DB.Connect.Password})
+	}
+
+	for _, secret := range secrets {
+		if gConfigRefRegex.ReplaceAllString(secret.value, "") != "" {
+			return fmt.Errorf(
+				"%s is a literal secret, use ${env:NAME} or ${file:path}, or set allowLiteralSecrets to embed it",
+				secret.name,
+			)
+		}
+	}
+
+	return nil
+}
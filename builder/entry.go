@@ -18,9 +18,20 @@ type BuildContext struct {
 	rtConfig *RTConfig
 	apiMetas []*APIMeta
 	dbMetas  []*DBTableMeta
+	enums    map[string]*EnumMeta
 	output   *RTOutputConfig
 }
 
+// getDBTableMeta returns the db meta of the table namespace
+func (p *BuildContext) getDBTableMeta(namespace string) *DBTableMeta {
+	for _, meta := range p.dbMetas {
+		if meta.Table == namespace {
+			return meta
+		}
+	}
+	return nil
+}
+
 func Build() error {
 	rtConfig, err := LoadRTConfig()
 	if err != nil {
@@ -77,6 +88,15 @@ func Build() error {
 			return fmt.Errorf("error walking project directory: %w", walkErr)
 		}
 
+		enums, err := CollectEnums(apiMetas, dbMetas)
+		if err != nil {
+			return err
+		}
+
+		if err := CheckErrors(apiMetas, output.GoPackage); err != nil {
+			return err
+		}
+
 		var builder IBuilder
 		var fileMap map[string]string
 
@@ -85,6 +105,7 @@ func Build() error {
 			rtConfig: rtConfig,
 			apiMetas: apiMetas,
 			dbMetas:  dbMetas,
+			enums:    enums,
 			output:   output,
 		}
 
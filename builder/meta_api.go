@@ -2,9 +2,23 @@ package builder
 
 import (
 	"fmt"
+	"go/token"
+	"maps"
+	"regexp"
+	"slices"
 	"strings"
 )
 
+// gAPIErrorMinCode is the min code of the declared errors, the smaller codes
+// are reserved by the runtime
+const gAPIErrorMinCode = 10000
+
+var gAPIErrorNameRegex = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+
+// gAPIErrorParameterRegex matches the parameters of the message templates,
+// e.g. "city {id} not found"
+var gAPIErrorParameterRegex = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
 type APIDefinitionAttributeMeta struct {
 	Name        string `json:"name" required:"true"`
 	Type        string `json:"type" required:"true"`
@@ -15,6 +29,9 @@ type APIDefinitionAttributeMeta struct {
 type APIDefinitionMeta struct {
 	Description string                        `json:"description"`
 	Attributes  []*APIDefinitionAttributeMeta `json:"attributes"`
+	// Partial definitions can omit every attribute that is not required,
+	// omitted attributes are distinguishable from zero values.
+	Partial bool `json:"partial"`
 }
 
 type APIActionParameterMeta struct {
@@ -36,12 +53,54 @@ type APIActionMeta struct {
 	Return      *APIActionReturnMeta      `json:"return"`
 }
 
+// APIErrorMeta is a declared error of the api, its message is public
+type APIErrorMeta struct {
+	Code int `json:"code" required:"true"`
+	// Message is the template of the message, {name} is a parameter
+	Message string `json:"message" required:"true"`
+	// Status is the http status of the response, 0 is 200
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+}
+
+// Parameters returns the distinct parameters of the message template in order
+func (p *APIErrorMeta) Parameters() []string {
+	ret := []string{}
+	for _, match := range gAPIErrorParameterRegex.FindAllStringSubmatch(p.Message, -1) {
+		if !slices.Contains(ret, match[1]) {
+			ret = append(ret, match[1])
+		}
+	}
+	return ret
+}
+
+// Format returns the fmt format of the message template and the parameters
+// of its verbs, e.g. "city %v not found" and ["id"]
+func (p *APIErrorMeta) Format() (string, []string) {
+	args := []string{}
+	format := gAPIErrorParameterRegex.ReplaceAllStringFunc(
+		strings.ReplaceAll(p.Message, "%", "%%"),
+		func(s string) string {
+			args = append(args, s[1:len(s)-1])
+			return "%v"
+		},
+	)
+	return format, args
+}
+
+type EnumMeta struct {
+	Description string   `json:"description"`
+	Values      []string `json:"values" required:"true"`
+}
+
 type APIMeta struct {
 	Version      string                        `json:"version" required:"true"`
 	Namespace    string                        `json:"namespace" required:"true"`
 	Description  string                        `json:"description"`
+	Enums        map[string]*EnumMeta          `json:"enums"`
 	Definitions  map[string]*APIDefinitionMeta `json:"definitions" required:"true"`
 	Actions      map[string]*APIActionMeta     `json:"actions"`
+	Errors       map[string]*APIErrorMeta      `json:"errors"`
 	__filepath__ string
 }
 
@@ -119,3 +178,111 @@ func MakeAPIConfigTree(configlist []*APIMeta) *APIMetaNode {
 		},
 	}
 }
+
+// CollectEnums gathers the enums declared by api and db metas, keyed by
+// their full name such as "API.System.City@Status".
+func CollectEnums(apiMetas []*APIMeta, dbMetas []*DBTableMeta) (map[string]*EnumMeta, error) {
+	ret := map[string]*EnumMeta{}
+
+	add := func(namespace string, enums map[string]*EnumMeta, definitions map[string]bool) error {
+		for name, enum := range enums {
+			if enum == nil || len(enum.Values) == 0 {
+				return fmt.Errorf("%s: enum %s has no values", namespace, name)
+			}
+
+			if definitions[name] {
+				return fmt.Errorf("%s: enum %s conflicts with definition %s", namespace, name, name)
+			}
+
+			// the values are the go constants of the enum type
+			constMap := map[string]string{}
+			for _, value := range enum.Values {
+				constName := toGolangEnumConst(name, value)
+				if value == "" {
+					return fmt.Errorf("%s: enum %s has empty value", namespace, name)
+				} else if other, ok := constMap[constName]; ok && other == value {
+					return fmt.Errorf("%s: enum %s has duplicated value %s", namespace, name, value)
+				} else if ok {
+					return fmt.Errorf("%s: enum %s values %q and %q conflict as %s", namespace, name, other, value, constName)
+				} else if constName == name {
+					return fmt.Errorf("%s: enum %s value %q has no letters or digits", namespace, name, value)
+				} else {
+					constMap[constName] = value
+				}
+			}
+
+			ret[namespace+"@"+name] = enum
+		}
+
+		return nil
+	}
+
+	for _, meta := range apiMetas {
+		definitions := map[string]bool{}
+		for name := range meta.Definitions {
+			definitions[name] = true
+		}
+
+		if err := add(meta.Namespace, meta.Enums, definitions); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, meta := range dbMetas {
+		definitions := map[string]bool{
+			"Create": true, "Delete": true, "Update": true, "Query": true,
+		}
+		for name := range meta.Views {
+			definitions[name] = true
+		}
+
+		if err := add(meta.Table, meta.Enums, definitions); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+// CheckErrors checks the errors declared by the api metas, the names are
+// used by the generated code and the codes are unique, a meta may be listed
+// more than once. The parameters of the go constructors can not shadow
+// goPackage, it is empty for the other languages.
+func CheckErrors(apiMetas []*APIMeta, goPackage string) error {
+	codes := map[int]string{}
+
+	for _, meta := range apiMetas {
+		for _, name := range slices.Sorted(maps.Keys(meta.Errors)) {
+			fullName := meta.Namespace + "@" + name
+			apiError := meta.Errors[name]
+
+			if apiError == nil {
+				return fmt.Errorf("%s: error is empty", fullName)
+			} else if !gAPIErrorNameRegex.MatchString(name) {
+				return fmt.Errorf("%s: error name must match %s", fullName, gAPIErrorNameRegex.String())
+			} else if meta.Definitions[name+"Error"] != nil || meta.Enums[name+"Error"] != nil {
+				return fmt.Errorf("%s: error conflicts with %sError", fullName, name)
+			} else if apiError.Code < gAPIErrorMinCode {
+				return fmt.Errorf("%s: error code must be at least %d", fullName, gAPIErrorMinCode)
+			} else if other, ok := codes[apiError.Code]; ok && other != fullName {
+				return fmt.Errorf("%s: error code %d is used by %s", fullName, apiError.Code, other)
+			} else if apiError.Message == "" {
+				return fmt.Errorf("%s: error message is required", fullName)
+			} else if apiError.Status != 0 && (apiError.Status < 400 || apiError.Status > 599) {
+				return fmt.Errorf("%s: error status %d is not an error status", fullName, apiError.Status)
+			}
+
+			for _, parameter := range apiError.Parameters() {
+				if token.IsKeyword(parameter) {
+					return fmt.Errorf("%s: error parameter %s is a keyword", fullName, parameter)
+				} else if parameter == goPackage {
+					return fmt.Errorf("%s: error parameter %s shadows the go package", fullName, parameter)
+				}
+			}
+
+			codes[apiError.Code] = fullName
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/ootiny/capi/utils"
+)
+
+func TestAPIErrorMeta_Format(t *testing.T) {
+	t.Run("parameters", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		apiError := &APIErrorMeta{Message: "city {id} of {country} is {id}, 100% {}"}
+		format, args := apiError.Format()
+		assert(format).Equals("city %v of %v is %v, 100%% {}")
+		assert(args).Equals([]string{"id", "country", "id"})
+		assert(apiError.Parameters()).Equals([]string{"id", "country"})
+	})
+
+	t.Run("no parameters", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		apiError := &APIErrorMeta{Message: "quota exceeded"}
+		format, args := apiError.Format()
+		assert(format, args).Equals("quota exceeded", []string{})
+		assert(apiError.Parameters()).Equals([]string{})
+	})
+}
+
+func TestCheckErrors(t *testing.T) {
+	fnMeta := func(namespace string, errors map[string]*APIErrorMeta) *APIMeta {
+		return &APIMeta{
+			Version:     "config.api.v1",
+			Namespace:   namespace,
+			Definitions: map[string]*APIDefinitionMeta{"CityNotFoundError": {}},
+			Errors:      errors,
+		}
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		meta := fnMeta("API.City", map[string]*APIErrorMeta{
+			"NotFound": {Code: 10001, Message: "city {id} not found", Status: 404},
+			"Changed":  {Code: 10002, Message: "city changed"},
+		})
+		// a meta that is listed twice does not conflict with itself
+		assert(CheckErrors([]*APIMeta{meta, meta}, "runtime")).IsNil()
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		for _, apiError := range []map[string]*APIErrorMeta{
+			{"notFound": {Code: 10001, Message: "not found"}},
+			{"CityNotFound": {Code: 10001, Message: "not found"}},
+			{"NotFound": {Code: 2000, Message: "not found"}},
+			{"NotFound": {Code: 10001}},
+			{"NotFound": {Code: 10001, Message: "not found", Status: 200}},
+			{"NotFound": {Code: 10001, Message: "{type} not found"}},
+			{"NotFound": {Code: 10001, Message: "{runtime} not found"}},
+			{"NotFound": nil},
+		} {
+			assert(CheckErrors([]*APIMeta{fnMeta("API.City", apiError)}, "runtime")).IsNotNil()
+		}
+
+		assert(CheckErrors([]*APIMeta{
+			fnMeta("API.City", map[string]*APIErrorMeta{"NotFound": {Code: 10001, Message: "not found"}}),
+			fnMeta("API.Geo", map[string]*APIErrorMeta{"NotFound": {Code: 10001, Message: "not found"}}),
+		}, "runtime")).IsNotNil()
+
+		// the go package only matters to the go output
+		assert(CheckErrors([]*APIMeta{
+			fnMeta("API.City", map[string]*APIErrorMeta{"NotFound": {Code: 10001, Message: "{runtime} not found"}}),
+		}, "")).IsNil()
+	})
+}
+
+func TestCollectEnums(t *testing.T) {
+	fnCollect := func(values ...string) error {
+		_, e := CollectEnums([]*APIMeta{{
+			Namespace:   "API.City",
+			Enums:       map[string]*EnumMeta{"Status": {Values: values}},
+			Definitions: map[string]*APIDefinitionMeta{},
+		}}, nil)
+		return e
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		enums, e := CollectEnums(
+			[]*APIMeta{{Namespace: "API.City", Enums: map[string]*EnumMeta{"Status": {Values: []string{"open", "in-review"}}}}},
+			[]*DBTableMeta{{Table: "DB.City", Enums: map[string]*EnumMeta{"Level": {Values: []string{"a", "b"}}}}},
+		)
+		assert(e).IsNil()
+		assert(enums["API.City@Status"].Values, enums["DB.City@Level"].Values).Equals(
+			[]string{"open", "in-review"}, []string{"a", "b"},
+		)
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(fnCollect()).IsNotNil()
+		assert(fnCollect("open", "")).IsNotNil()
+		assert(fnCollect("open", "open")).IsNotNil()
+	})
+
+	t.Run("const names", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		assert(fnCollect("in-review", "in_review")).IsNotNil()
+		assert(fnCollect("a", "A")).IsNotNil()
+		// the const of "-" would be the enum type
+		assert(fnCollect("open", "-")).IsNotNil()
+	})
+
+	t.Run("definition conflict", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		_, e := CollectEnums([]*APIMeta{{
+			Namespace:   "API.City",
+			Enums:       map[string]*EnumMeta{"Status": {Values: []string{"open"}}},
+			Definitions: map[string]*APIDefinitionMeta{"Status": {}},
+		}}, nil)
+		assert(e).IsNotNil()
+	})
+}
@@ -2,32 +2,115 @@ package builder
 
 import (
 	"fmt"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"time"
+	"unicode"
 )
 
 type DBTableColumnMeta struct {
-	Type     string   `json:"type"`
-	Query    []string `json:"query"`
-	Unique   bool     `json:"unique"`
-	Index    bool     `json:"index"`
-	Order    bool     `json:"order"`
-	Required bool     `json:"required"`
+	Type       string   `json:"type"`
+	Query      []string `json:"query"`
+	Unique     bool     `json:"unique"`
+	Index      bool     `json:"index"`
+	Order      bool     `json:"order"`
+	Required   bool     `json:"required"`
+	OnDelete   string   `json:"onDelete"`
+	ForeignKey bool     `json:"foreignKey"`
+	Aggregate  []string `json:"aggregate"`
+	Search     bool     `json:"search"`
+	managed    bool
 }
 
-func (p *DBTableColumnMeta) ToDBTableColumn() (*DBTableColumn, error) {
+// columns maintained by the runtime, they can be viewed and queried
+// but never written by the api
+const (
+	ColumnCreatedAt = "created_at"
+	ColumnUpdatedAt = "updated_at"
+	ColumnCreatedBy = "created_by"
+	ColumnUpdatedBy = "updated_by"
+	ColumnDeletedAt = "deleted_at"
+	ColumnVersion   = "version"
+)
+
+// what happens to a link column when the linked record is deleted,
+// an empty rule keeps the link dangling
+var onDeleteRules = map[string]bool{
+	"": true, "restrict": true, "cascade": true, "setNull": true,
+}
+
+// the aggregates allowed by the column types, count is allowed for any table
+var aggregateColumnTypes = map[string]map[string]bool{
+	"sum": {"Int64": true, "Float64": true, "Decimal": true},
+	"avg": {"Int64": true, "Float64": true, "Decimal": true},
+	"min": {
+		"Int64": true, "Float64": true, "Decimal": true, "Time": true, "Date": true,
+		"String": true, "String16": true, "String32": true, "String64": true, "String256": true,
+	},
+	"max": {
+		"Int64": true, "Float64": true, "Decimal": true, "Time": true, "Date": true,
+		"String": true, "String16": true, "String32": true, "String64": true, "String256": true,
+	},
+	"groupBy": {
+		"PK": true, "LK": true, "Bool": true, "Int64": true, "Decimal": true, "Time": true, "Date": true,
+		"String": true, "String16": true, "String32": true, "String64": true, "String256": true,
+		"UUID": true, "Enum": true,
+	},
+}
+
+var indexNameRegex = regexp.MustCompile("^[_a-z][_a-z0-9]*$")
+
+// indexWhereTokenRegex matches a token of the where of a partial index, the
+// quoted identifiers, the string literals, the words, the numbers and the
+// operators. comments, statement separators and casts never match
+var indexWhereTokenRegex = regexp.MustCompile(
+	`^\s*("[^"]*"|'(?:[^']|'')*'|[A-Za-z_][A-Za-z0-9_]*|-?[0-9]+(?:\.[0-9]+)?|<>|!=|<=|>=|[=<>(),])`,
+)
+
+// indexWhereKeywords are the words allowed in the where of a partial index
+// besides the columns of the table
+var indexWhereKeywords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "IS": true, "NULL": true, "TRUE": true, "FALSE": true,
+	"IN": true, "LIKE": true, "ILIKE": true, "BETWEEN": true, "DISTINCT": true, "FROM": true,
+}
+
+var enumQueryOperators = map[string]bool{
+	"=": true, "!=": true, "in": true, "not in": true,
+}
+
+var geoPointQueryOperators = map[string]bool{
+	"within-radius": true, "within-box": true,
+}
+
+func (p *DBTableColumnMeta) ToDBTableColumn(enums map[string]*EnumMeta) (*DBTableColumn, error) {
 	// parse type
 	strType := ""
 	strTable := ""
+	enumValues := []string(nil)
 	switch p.Type {
 	case "PK", "Bool", "Int64", "Float64",
 		"String", "String16", "String32", "String64", "String256",
+		"Time", "Date", "Decimal", "UUID", "Bytes", "GeoPoint",
 		"List<String>", "Map<String>":
 		strType = p.Type
 		strTable = ""
 	default:
-		if strings.HasPrefix(p.Type, DBPrefix) {
+		if strings.Contains(p.Type, "@") {
+			if enum, ok := enums[p.Type]; !ok {
+				return nil, fmt.Errorf("invalid column type: %s: enum not found", p.Type)
+			} else {
+				strType = "Enum"
+				enumValues = enum.Values
+			}
+
+			for _, v := range p.Query {
+				if !enumQueryOperators[v] {
+					return nil, fmt.Errorf("invalid column type: %s: query \"%s\" is not allowed", p.Type, v)
+				}
+			}
+		} else if strings.HasPrefix(p.Type, DBPrefix) {
 			strType = "LK"
 			strTable = NamespaceToTableName(p.Type)
 		} else if strings.HasPrefix(p.Type, "List<") && strings.HasSuffix(p.Type, ">") {
@@ -51,23 +134,150 @@ func (p *DBTableColumnMeta) ToDBTableColumn() (*DBTableColumn, error) {
 		}
 	}
 
-	// build query map
+	// a GeoPoint column always has a spatial index, it can not be compared
+	if strType == "GeoPoint" {
+		for _, v := range p.Query {
+			if !geoPointQueryOperators[v] {
+				return nil, fmt.Errorf("invalid column type: %s: query \"%s\" is not allowed", p.Type, v)
+			}
+		}
+
+		if p.Index || p.Unique || p.Order {
+			return nil, fmt.Errorf("invalid column type: %s: index, unique and order are not allowed", p.Type)
+		}
+	}
+
+	// check link rules
+	if !onDeleteRules[p.OnDelete] {
+		return nil, fmt.Errorf("invalid onDelete: %s", p.OnDelete)
+	} else if p.OnDelete != "" && strTable == "" {
+		return nil, fmt.Errorf("onDelete is only allowed for link columns")
+	} else if p.ForeignKey && strType != "LK" {
+		return nil, fmt.Errorf("foreignKey is only allowed for single link columns")
+	}
+
+	// build query map, a search column can always be searched
 	queryMap := map[string]bool{}
 	for _, v := range p.Query {
 		queryMap[v] = true
 	}
 
+	if p.Search {
+		switch strType {
+		case "String", "String16", "String32", "String64", "String256":
+			queryMap["search"] = true
+		default:
+			return nil, fmt.Errorf("search is only allowed for string columns")
+		}
+	}
+
+	// build aggregate map
+	aggregateMap := map[string]bool{}
+	for _, v := range p.Aggregate {
+		if columnTypes, ok := aggregateColumnTypes[v]; !ok {
+			return nil, fmt.Errorf("invalid aggregate: %s", v)
+		} else if !columnTypes[strType] {
+			return nil, fmt.Errorf("aggregate %s is not allowed by type %s", v, p.Type)
+		} else {
+			aggregateMap[v] = true
+		}
+	}
+
 	return &DBTableColumn{
-		Type:      strType,
-		QueryMap:  queryMap,
-		Unique:    p.Unique,
-		Index:     p.Index,
-		Order:     p.Order,
-		Required:  p.Required,
-		LinkTable: strTable,
+		Type:         strType,
+		QueryMap:     queryMap,
+		Unique:       p.Unique,
+		Index:        p.Index,
+		Order:        p.Order,
+		Required:     p.Required,
+		LinkTable:    strTable,
+		Enum:         enumValues,
+		OnDelete:     p.OnDelete,
+		ForeignKey:   p.ForeignKey,
+		AggregateMap: aggregateMap,
+		Search:       p.Search,
+	}, nil
+}
+
+// DBTableIndexMeta is an index on several columns, the columns can be
+// suffixed by the sort direction, e.g. "name:descend"
+type DBTableIndexMeta struct {
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+	Where   string   `json:"where"`
+}
+
+func (p *DBTableIndexMeta) ToDBTableIndex(columns map[string]*DBTableColumnMeta) (*DBTableIndex, error) {
+	if len(p.Columns) == 0 {
+		return nil, fmt.Errorf("columns is empty")
+	}
+
+	indexColumns := []string{}
+	for _, column := range p.Columns {
+		columnName, direction, _ := strings.Cut(column, ":")
+		switch direction {
+		case "", "ascend", "descend":
+		default:
+			return nil, fmt.Errorf("column %s: invalid direction %s", columnName, direction)
+		}
+
+		if _, ok := columns[columnName]; !ok {
+			return nil, fmt.Errorf("column %s not found", columnName)
+		} else if slices.ContainsFunc(indexColumns, func(it string) bool {
+			name, _, _ := strings.Cut(it, ":")
+			return name == columnName
+		}) {
+			return nil, fmt.Errorf("column %s is duplicated", columnName)
+		}
+
+		indexColumns = append(indexColumns, column)
+	}
+
+	where, err := toIndexWhere(p.Where, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DBTableIndex{
+		Columns: indexColumns,
+		Unique:  p.Unique,
+		Where:   where,
 	}, nil
 }
 
+// toIndexWhere checks the where of a partial index which is written into the
+// DDL as it is. only the columns of the table, literals, operators and the
+// indexWhereKeywords are allowed, the columns are returned quoted
+func toIndexWhere(where string, columns map[string]*DBTableColumnMeta) (string, error) {
+	tokens := []string{}
+	for rest := strings.TrimSpace(where); rest != ""; rest = strings.TrimSpace(rest) {
+		match := indexWhereTokenRegex.FindStringSubmatch(rest)
+		if match == nil {
+			return "", fmt.Errorf("where: invalid token at %q", rest)
+		}
+		rest = rest[len(match[0]):]
+
+		token := match[1]
+		switch {
+		case token[0] == '"':
+			if _, ok := columns[token[1:len(token)-1]]; !ok {
+				return "", fmt.Errorf("where: column %s not found", token)
+			}
+		case token[0] == '_' || unicode.IsLetter(rune(token[0])):
+			if indexWhereKeywords[strings.ToUpper(token)] {
+				token = strings.ToUpper(token)
+			} else if _, ok := columns[token]; ok {
+				token = `"` + token + `"`
+			} else {
+				return "", fmt.Errorf("where: column %s not found", token)
+			}
+		}
+		tokens = append(tokens, token)
+	}
+
+	return strings.Join(tokens, " "), nil
+}
+
 type DBTableViewMeta struct {
 	Cache   string   `json:"cache"`
 	Columns []string `json:"columns"`
@@ -76,7 +286,13 @@ type DBTableViewMeta struct {
 type DBTableMeta struct {
 	Version      string                        `json:"version"`
 	Table        string                        `json:"table"`
+	Timestamps   bool                          `json:"timestamps"`
+	Audit        bool                          `json:"audit"`
+	SoftDelete   bool                          `json:"softDelete"`
+	Versioned    bool                          `json:"versioned"`
+	Enums        map[string]*EnumMeta          `json:"enums"`
 	Columns      map[string]*DBTableColumnMeta `json:"columns"`
+	Indexes      map[string]*DBTableIndexMeta  `json:"indexes"`
 	Views        map[string]*DBTableViewMeta   `json:"views"`
 	__filepath__ string
 }
@@ -85,24 +301,93 @@ func (p *DBTableMeta) GetFilePath() string {
 	return p.__filepath__
 }
 
-// convert columns to APIDefinitionMeta
-func (p *DBTableMeta) toAPIDefinitionMeta(columns []string, defaultView string) (*APIDefinitionMeta, error) {
+// addManagedColumns adds the columns required by the table options
+func (p *DBTableMeta) addManagedColumns() error {
+	managed := map[string]*DBTableColumnMeta{}
+
+	if p.Timestamps {
+		for _, name := range []string{ColumnCreatedAt, ColumnUpdatedAt} {
+			managed[name] = &DBTableColumnMeta{
+				Type:     "Time",
+				Query:    []string{">", "<", ">=", "<="},
+				Order:    true,
+				Required: true,
+			}
+		}
+	}
+
+	if p.Audit {
+		for _, name := range []string{ColumnCreatedBy, ColumnUpdatedBy} {
+			managed[name] = &DBTableColumnMeta{
+				Type:     "String256",
+				Query:    []string{"=", "in"},
+				Required: true,
+			}
+		}
+	}
+
+	// deleted_at keeps its zero value (epoch) until the record is deleted
+	if p.SoftDelete {
+		managed[ColumnDeletedAt] = &DBTableColumnMeta{
+			Type:  "Time",
+			Query: []string{">", "<", ">=", "<="},
+			Order: true,
+		}
+	}
+
+	// version is increased by every update, the update must send
+	// the version it has read, so every view contains it
+	if p.Versioned {
+		managed[ColumnVersion] = &DBTableColumnMeta{
+			Type:     "Int64",
+			Query:    []string{"="},
+			Required: true,
+		}
+
+		for _, view := range p.Views {
+			if !slices.Contains(view.Columns, ColumnVersion) {
+				view.Columns = append(view.Columns, ColumnVersion)
+			}
+		}
+	}
+
+	if len(managed) > 0 && p.Columns == nil {
+		p.Columns = map[string]*DBTableColumnMeta{}
+	}
+
+	for name, column := range managed {
+		if _, ok := p.Columns[name]; ok {
+			return fmt.Errorf("%s: column %s is managed by the table options, it can not be defined", p.Table, name)
+		}
+		column.managed = true
+		p.Columns[name] = column
+	}
+
+	return nil
+}
+
+// convert columns to APIDefinitionMeta, link columns without a view
+// (e.g. "geo" instead of "geo@Full") are converted to their ids
+func (p *DBTableMeta) toAPIDefinitionMeta(columns []string) (*APIDefinitionMeta, error) {
 	attributes := []*APIDefinitionAttributeMeta{}
 
 	for _, column := range columns {
 		columnName := ""
 		columnType := ""
 		columnArray := strings.Split(column, "@")
-		if len(columnArray) == 1 {
-			columnName = columnArray[0]
+		columnName = columnArray[0]
 
-			if apiType, err := DBTypeToApiType(p.Columns[columnName].Type, defaultView); err != nil {
+		if _, ok := p.Columns[columnName]; !ok {
+			return nil, fmt.Errorf("column %s not found", columnName)
+		}
+
+		if len(columnArray) == 1 {
+			if apiType, err := DBTypeToApiType(DBTypeToLinkIDType(p.Columns[columnName].Type), ""); err != nil {
 				return nil, err
 			} else {
 				columnType = apiType
 			}
 		} else {
-			columnName = columnArray[0]
 			if apiType, err := DBTypeToApiType(p.Columns[columnName].Type, columnArray[1]); err != nil {
 				return nil, err
 			} else {
@@ -122,6 +407,18 @@ func (p *DBTableMeta) toAPIDefinitionMeta(columns []string, defaultView string)
 	}, nil
 }
 
+// getSearchColumns returns the sorted names of the search columns
+func (p *DBTableMeta) getSearchColumns() []string {
+	ret := []string{}
+	for name, column := range p.Columns {
+		if column.Search {
+			ret = append(ret, name)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
 func (p *DBTableMeta) ToAPIMeta() (*APIMeta, error) {
 	definitions := map[string]*APIDefinitionMeta{}
 
@@ -130,11 +427,13 @@ func (p *DBTableMeta) ToAPIMeta() (*APIMeta, error) {
 		return nil, fmt.Errorf("Create view can not be defined")
 	}
 	columnNames := []string{}
-	for k := range p.Columns {
-		columnNames = append(columnNames, k)
+	for k, column := range p.Columns {
+		if !column.managed {
+			columnNames = append(columnNames, k)
+		}
 	}
 	sort.Strings(columnNames)
-	if apiDefinition, err := p.toAPIDefinitionMeta(columnNames, "Create"); err != nil {
+	if apiDefinition, err := p.toAPIDefinitionMeta(columnNames); err != nil {
 		return nil, err
 	} else {
 		definitions["Create"] = apiDefinition
@@ -144,7 +443,7 @@ func (p *DBTableMeta) ToAPIMeta() (*APIMeta, error) {
 	if _, ok := p.Views["Delete"]; ok {
 		return nil, fmt.Errorf("Delete view can not be defined")
 	}
-	if apiDefinition, err := p.toAPIDefinitionMeta([]string{"id"}, "Delete"); err != nil {
+	if apiDefinition, err := p.toAPIDefinitionMeta([]string{"id"}); err != nil {
 		return nil, err
 	} else {
 		if len(apiDefinition.Attributes) == 1 {
@@ -153,42 +452,103 @@ func (p *DBTableMeta) ToAPIMeta() (*APIMeta, error) {
 		definitions["Delete"] = apiDefinition
 	}
 
-	// check predefined views
+	// build update definition, every column except id (and version) can be omitted
 	if _, ok := p.Views["Update"]; ok {
 		return nil, fmt.Errorf("Update view can not be defined")
 	}
+	updateColumnNames := columnNames
+	if p.Versioned {
+		updateColumnNames = append(slices.Clone(columnNames), ColumnVersion)
+	}
+	if apiDefinition, err := p.toAPIDefinitionMeta(updateColumnNames); err != nil {
+		return nil, err
+	} else {
+		for _, attribute := range apiDefinition.Attributes {
+			attribute.Required = attribute.Name == "id" || attribute.Name == ColumnVersion
+		}
+		apiDefinition.Partial = true
+		definitions["Update"] = apiDefinition
+	}
 
+	// build query definition, see NewWebQuery in the runtime
 	if _, ok := p.Views["Query"]; ok {
 		return nil, fmt.Errorf("Query view can not be defined")
 	}
+	definitions["Query"] = &APIDefinitionMeta{
+		Partial: true,
+		Attributes: []*APIDefinitionAttributeMeta{
+			{Name: "where", Type: "Map<Any>", Description: `conditions like {"name:like": "new"}`},
+			{Name: "orders", Type: "List<String>", Description: `orders like ["name:ascend"]`},
+			{Name: "limit", Type: "Int64"},
+			{Name: "offset", Type: "Int64"},
+			{Name: "cursor", Type: "String", Description: "the nextCursor of the last page, for page queries"},
+		},
+	}
+	if len(p.getSearchColumns()) > 0 {
+		definitions["Query"].Attributes = append(definitions["Query"].Attributes, &APIDefinitionAttributeMeta{
+			Name: "search", Type: "String", Description: "full text search, ordered by relevance if orders is empty",
+		})
+	}
+	if p.SoftDelete {
+		definitions["Query"].Attributes = append(definitions["Query"].Attributes, &APIDefinitionAttributeMeta{
+			Name: "includeDeleted", Type: "Bool", Description: "include the deleted records",
+		})
+	}
 
 	// convert views
 	for name, view := range p.Views {
-		if apiDefinition, err := p.toAPIDefinitionMeta(view.Columns, ""); err != nil {
-			return nil, err
+		if apiDefinition, err := p.toAPIDefinitionMeta(view.Columns); err != nil {
+			return nil, fmt.Errorf("views.%s: %w", name, err)
 		} else {
 			definitions[name] = apiDefinition
 		}
 	}
 
+	// build page definitions of the views, see QueryPage in the runtime
+	for name := range p.Views {
+		pageName := name + "Page"
+		if _, ok := definitions[pageName]; ok {
+			return nil, fmt.Errorf("views.%s: the name is used by the page of view %s", pageName, name)
+		}
+		definitions[pageName] = &APIDefinitionMeta{
+			Attributes: []*APIDefinitionAttributeMeta{
+				{Name: "items", Type: fmt.Sprintf("List<%s@%s>", p.Table, name), Required: true},
+				{Name: "nextCursor", Type: "String", Description: "empty if it is the last page"},
+			},
+		}
+	}
+
 	return &APIMeta{
 		Version:     CurrentAPIVersion,
 		Namespace:   p.Table,
+		Enums:       p.Enums,
 		Definitions: definitions,
 	}, nil
 }
 
-func (p *DBTableMeta) ToDBTable() (*DBTable, error) {
+func (p *DBTableMeta) ToDBTable(enums map[string]*EnumMeta) (*DBTable, error) {
 	// convert columns
 	columns := map[string]*DBTableColumn{}
 	for name, column := range p.Columns {
-		if dbColumn, err := column.ToDBTableColumn(); err != nil {
-			return nil, err
+		if dbColumn, err := column.ToDBTableColumn(enums); err != nil {
+			return nil, fmt.Errorf("columns.%s: %w", name, err)
 		} else {
 			columns[name] = dbColumn
 		}
 	}
 
+	// convert indexes
+	indexes := map[string]*DBTableIndex{}
+	for name, index := range p.Indexes {
+		if !indexNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("indexes.%s: invalid name", name)
+		} else if dbIndex, err := index.ToDBTableIndex(p.Columns); err != nil {
+			return nil, fmt.Errorf("indexes.%s: %w", name, err)
+		} else {
+			indexes[name] = dbIndex
+		}
+	}
+
 	viewNames := []string{}
 	for name := range p.Views {
 		viewNames = append(viewNames, name)
@@ -264,12 +624,17 @@ func (p *DBTableMeta) ToDBTable() (*DBTable, error) {
 	}
 
 	return &DBTable{
-		Version:   CurrentDBTableVersion,
-		Table:     NamespaceToTableName(p.Table),
-		Columns:   columns,
-		Views:     views,
-		Namespace: p.Table,
-		File:      p.GetFilePath(),
+		Version:    CurrentDBTableVersion,
+		Table:      NamespaceToTableName(p.Table),
+		Timestamps: p.Timestamps,
+		Audit:      p.Audit,
+		SoftDelete: p.SoftDelete,
+		Versioned:  p.Versioned,
+		Columns:    columns,
+		Indexes:    indexes,
+		Views:      views,
+		Namespace:  p.Table,
+		File:       p.GetFilePath(),
 	}, nil
 }
 
@@ -279,6 +644,9 @@ func LoadDBTableMeta(filePath string) (*DBTableMeta, error) {
 		return nil, fmt.Errorf("failed to parse meta file: %w", err)
 	} else {
 		meta.__filepath__ = filePath
+		if err := meta.addManagedColumns(); err != nil {
+			return nil, err
+		}
 		return &meta, nil
 	}
 }
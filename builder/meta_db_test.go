@@ -0,0 +1,254 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/ootiny/capi/utils"
+)
+
+func testDBTableMeta() *DBTableMeta {
+	return &DBTableMeta{
+		Version: CurrentDBVersion,
+		Table:   "DB.Test",
+		Columns: map[string]*DBTableColumnMeta{
+			"id":   {Type: "PK", Query: []string{"="}, Required: true},
+			"name": {Type: "String", Required: true},
+			"geo":  {Type: "DB.Geo"},
+		},
+		Views: map[string]*DBTableViewMeta{
+			"Full": {Columns: []string{"id", "name", "geo@Full"}},
+		},
+	}
+}
+
+func attributeMap(definition *APIDefinitionMeta) map[string]*APIDefinitionAttributeMeta {
+	ret := map[string]*APIDefinitionAttributeMeta{}
+	for _, attribute := range definition.Attributes {
+		ret[attribute.Name] = attribute
+	}
+	return ret
+}
+
+func TestDBTableMeta_addManagedColumns(t *testing.T) {
+	t.Run("no options", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		meta := testDBTableMeta()
+		assert(meta.addManagedColumns()).IsNil()
+		assert(len(meta.Columns)).Equals(3)
+	})
+
+	t.Run("timestamps and audit", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		meta := testDBTableMeta()
+		meta.Timestamps = true
+		meta.Audit = true
+		assert(meta.addManagedColumns()).IsNil()
+		assert(meta.Columns[ColumnCreatedAt].Type).Equals("Time")
+		assert(meta.Columns[ColumnUpdatedAt].Type).Equals("Time")
+		assert(meta.Columns[ColumnCreatedBy].Type).Equals("String256")
+		assert(meta.Columns[ColumnUpdatedBy].managed).IsTrue()
+	})
+
+	t.Run("soft delete", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		meta := testDBTableMeta()
+		meta.SoftDelete = true
+		assert(meta.addManagedColumns()).IsNil()
+		assert(meta.Columns[ColumnDeletedAt].Type).Equals("Time")
+		assert(meta.Columns[ColumnDeletedAt].Required).IsFalse()
+
+		apiMeta, err := meta.ToAPIMeta()
+		assert(err).IsNil()
+		assert(attributeMap(apiMeta.Definitions["Create"])[ColumnDeletedAt]).IsNil()
+		assert(attributeMap(apiMeta.Definitions["Query"])["includeDeleted"].Type).Equals("Bool")
+	})
+
+	t.Run("versioned", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		meta := testDBTableMeta()
+		meta.Versioned = true
+		assert(meta.addManagedColumns()).IsNil()
+		assert(meta.Columns[ColumnVersion].Type).Equals("Int64")
+		assert(meta.Views["Full"].Columns[len(meta.Views["Full"].Columns)-1]).Equals(ColumnVersion)
+
+		apiMeta, err := meta.ToAPIMeta()
+		assert(err).IsNil()
+		assert(attributeMap(apiMeta.Definitions["Create"])[ColumnVersion]).IsNil()
+		assert(attributeMap(apiMeta.Definitions["Update"])[ColumnVersion].Required).IsTrue()
+		assert(attributeMap(apiMeta.Definitions["Full"])[ColumnVersion].Type).Equals("Int64")
+	})
+
+	t.Run("managed column is defined", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		meta := testDBTableMeta()
+		meta.Timestamps = true
+		meta.Columns[ColumnCreatedAt] = &DBTableColumnMeta{Type: "Time"}
+		assert(meta.addManagedColumns()).IsNotNil()
+	})
+}
+
+func TestDBTableMeta_ToAPIMeta(t *testing.T) {
+	t.Run("managed columns are not writable", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		meta := testDBTableMeta()
+		meta.Timestamps = true
+		assert(meta.addManagedColumns()).IsNil()
+		meta.Views["Full"].Columns = append(meta.Views["Full"].Columns, ColumnCreatedAt)
+
+		apiMeta, err := meta.ToAPIMeta()
+		assert(err).IsNil()
+		assert(attributeMap(apiMeta.Definitions["Create"])[ColumnCreatedAt]).IsNil()
+		assert(attributeMap(apiMeta.Definitions["Update"])[ColumnUpdatedAt]).IsNil()
+		assert(attributeMap(apiMeta.Definitions["Full"])[ColumnCreatedAt].Type).Equals("Time")
+	})
+
+	t.Run("links are written by id", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		apiMeta, err := testDBTableMeta().ToAPIMeta()
+		assert(err).IsNil()
+		assert(attributeMap(apiMeta.Definitions["Create"])["geo"].Type).Equals("String")
+		assert(attributeMap(apiMeta.Definitions["Full"])["geo"].Type).Equals("DB.Geo@Full")
+	})
+
+	t.Run("update is partial", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		apiMeta, err := testDBTableMeta().ToAPIMeta()
+		assert(err).IsNil()
+		update := apiMeta.Definitions["Update"]
+		assert(update.Partial).IsTrue()
+		assert(attributeMap(update)["id"].Required).IsTrue()
+		assert(attributeMap(update)["name"].Required).IsFalse()
+	})
+}
+
+func TestDBTableColumnMeta_ToDBTableColumn(t *testing.T) {
+	t.Run("onDelete", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		column, err := (&DBTableColumnMeta{Type: "List<DB.Geo>", OnDelete: "setNull"}).ToDBTableColumn(nil)
+		assert(err).IsNil()
+		assert(column.Type, column.OnDelete).Equals("LKList", "setNull")
+
+		_, err = (&DBTableColumnMeta{Type: "DB.Geo", OnDelete: "drop"}).ToDBTableColumn(nil)
+		assert(err).IsNotNil()
+
+		_, err = (&DBTableColumnMeta{Type: "String", OnDelete: "cascade"}).ToDBTableColumn(nil)
+		assert(err).IsNotNil()
+	})
+
+	t.Run("aggregate", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		column, err := (&DBTableColumnMeta{Type: "Int64", Aggregate: []string{"sum", "groupBy"}}).ToDBTableColumn(nil)
+		assert(err).IsNil()
+		assert(column.AggregateMap).Equals(map[string]bool{"sum": true, "groupBy": true})
+
+		_, err = (&DBTableColumnMeta{Type: "String", Aggregate: []string{"avg"}}).ToDBTableColumn(nil)
+		assert(err).IsNotNil()
+
+		_, err = (&DBTableColumnMeta{Type: "Int64", Aggregate: []string{"median"}}).ToDBTableColumn(nil)
+		assert(err).IsNotNil()
+	})
+
+	t.Run("search", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		column, err := (&DBTableColumnMeta{Type: "String", Search: true}).ToDBTableColumn(nil)
+		assert(err).IsNil()
+		assert(column.Search, column.QueryMap["search"]).Equals(true, true)
+
+		_, err = (&DBTableColumnMeta{Type: "Int64", Search: true}).ToDBTableColumn(nil)
+		assert(err).IsNotNil()
+	})
+
+	t.Run("geoPoint", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		column, err := (&DBTableColumnMeta{Type: "GeoPoint", Query: []string{"within-radius", "within-box"}}).
+			ToDBTableColumn(nil)
+		assert(err).IsNil()
+		assert(column.Type, column.QueryMap["within-box"]).Equals("GeoPoint", true)
+
+		_, err = (&DBTableColumnMeta{Type: "GeoPoint", Query: []string{"="}}).ToDBTableColumn(nil)
+		assert(err).IsNotNil()
+
+		_, err = (&DBTableColumnMeta{Type: "GeoPoint", Order: true}).ToDBTableColumn(nil)
+		assert(err).IsNotNil()
+	})
+
+	t.Run("foreignKey", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		column, err := (&DBTableColumnMeta{Type: "DB.Geo", ForeignKey: true}).ToDBTableColumn(nil)
+		assert(err).IsNil()
+		assert(column.ForeignKey).IsTrue()
+
+		_, err = (&DBTableColumnMeta{Type: "Map<DB.Geo>", ForeignKey: true}).ToDBTableColumn(nil)
+		assert(err).IsNotNil()
+	})
+}
+
+func TestDBTableIndexMeta_ToDBTableIndex(t *testing.T) {
+	columns := testDBTableMeta().Columns
+
+	t.Run("ok", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		index, err := (&DBTableIndexMeta{
+			Columns: []string{"geo", "name:descend"},
+			Unique:  true,
+			Where:   " name <> '' ",
+		}).ToDBTableIndex(columns)
+		assert(err).IsNil()
+		assert(index).Equals(&DBTableIndex{
+			Columns: []string{"geo", "name:descend"},
+			Unique:  true,
+			Where:   `"name" <> ''`,
+		})
+	})
+
+	t.Run("where", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		fnWhere := func(where string) (string, error) {
+			index, err := (&DBTableIndexMeta{Columns: []string{"name"}, Where: where}).ToDBTableIndex(columns)
+			if err != nil {
+				return "", err
+			}
+			return index.Where, nil
+		}
+
+		for where, expected := range map[string]string{
+			"":                                       "",
+			`name is not null and "geo" != 'x'`:      `"name" IS NOT NULL AND "geo" != 'x'`,
+			"(name = 'it''s') or name in ('a', 'b')": `( "name" = 'it''s' ) OR "name" IN ( 'a' , 'b' )`,
+			"geo <> '-- ;'":                          `"geo" <> '-- ;'`,
+			"name >= -1.5":                           `"name" >= -1.5`,
+		} {
+			assert(fnWhere(where)).Equals(expected, nil)
+		}
+
+		for _, where := range []string{
+			"name <> ''; DROP TABLE city",
+			"name <> '' -- comment",
+			"name <> '' /* comment */",
+			"unknown is null",
+			`"unknown" is null`,
+			`"name"" is null`,
+			"lower(name) = 'x'",
+			"name::text = 'x'",
+			"name = $$x$$",
+			"name = E'x'",
+			"name = 'x",
+		} {
+			_, err := fnWhere(where)
+			assert(err).IsNotNil()
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert := utils.NewAssert(t)
+		for _, indexColumns := range [][]string{
+			nil,
+			{"unknown"},
+			{"name:down"},
+			{"name", "name:descend"},
+		} {
+			_, err := (&DBTableIndexMeta{Columns: indexColumns}).ToDBTableIndex(columns)
+			assert(err).IsNotNil()
+		}
+	})
+}
@@ -8,28 +8,65 @@ import (
 
 func init() {
 	api_system_city.OnCreate(
-		func(ctx *runtime.Context, city db_city.Create) (db_city.Default, *runtime.Error) {
-			return db_city.Create(city)
+		func(ctx *runtime.Context, city db_city.Create) (db_city.Create, *runtime.Error) {
+			if id, err := db_city.Insert(ctx, city); err != nil {
+				return db_city.Create{}, err
+			} else {
+				city.Id = id
+				return city, nil
+			}
 		})
 
 	api_system_city.OnDelete(
-		func(ctx *runtime.Context, city db_city.Delete) (db_city.Default, *runtime.Error) {
-			return db_city.Delete(id)
+		func(ctx *runtime.Context, v db_city.Delete) (db_city.Delete, *runtime.Error) {
+			if err := db_city.Remove(ctx, v); err != nil && err.Code() == runtime.ErrDBRecordNotFound {
+				return v, api_system_city.ErrCityNotFound(v.Id)
+			} else {
+				return v, err
+			}
 		})
 
 	api_system_city.OnUpdate(
-		func(ctx *runtime.Context, data db_city.Update) (db_city.Default, *runtime.Error) {
-			return db_city.Update(ctx, data)
+		func(ctx *runtime.Context, v db_city.Update) (db_city.Update, *runtime.Error) {
+			if err := db_city.Modify(ctx, v); err != nil {
+				switch err.Code() {
+				case runtime.ErrDBRecordNotFound:
+					return v, api_system_city.ErrCityNotFound(v.Id)
+				case runtime.ErrDBConflict:
+					return v, api_system_city.ErrCityChanged(v.Id, v.Version)
+				default:
+					return v, err
+				}
+			}
+			v.Version++
+			return v, nil
+		})
+
+	api_system_city.OnPage(
+		func(ctx *runtime.Context, v db_city.Query) (db_city.FullPage, *runtime.Error) {
+			if page, err := db_city.QueryFullPage(ctx, v); err != nil {
+				return db_city.FullPage{}, err
+			} else {
+				return *page, nil
+			}
 		})
 
 	api_system_city.OnQuery(
-		func(ctx *runtime.Context, query db_city.Query) (api_system_city.CityList, *runtime.Error) {
-			return db_city.QueryFull(ctx, query)
+		func(ctx *runtime.Context, v db_city.Query) (api_system_city.CityList, *runtime.Error) {
+			if list, err := db_city.QueryFull(ctx, v); err != nil {
+				return api_system_city.CityList{}, err
+			} else if total, err := db_city.CountFull(ctx, v); err != nil {
+				return api_system_city.CityList{}, err
+			} else if v.Offset != nil {
+				return api_system_city.CityList{From: *v.Offset, Total: total, List: list}, nil
+			} else {
+				return api_system_city.CityList{From: 0, Total: total, List: list}, nil
+			}
 		})
 }
 
 func main() {
-	if err := runtime.NewHttpServer("0.0.0.0:8080", "", "", true).Run(); err != nil {
+	if err := runtime.Run(); err != nil {
 		panic(err)
 	}
 }
@@ -1,16 +1,33 @@
 // tag-capi-builder-start: This file is generated by capi-builder, DO NOT EDIT.
 package api_system_city
 import (
-	"github.com/ootiny/capi/server/runtime/db_city"
 	"github.com/ootiny/capi/server/runtime"
+	"github.com/ootiny/capi/server/runtime/db_city"
 )
 
 // definition: API.System.City@CityList
 type CityList struct {
 	From int64 `json:"from" required:"true"`
+	Total int64 `json:"total" required:"true"`
 	List []db_city.Full `json:"list" required:"true"`
 }
 
+// error: API.System.City@CityChanged
+const ErrCodeCityChanged = 10002
+
+// ErrCityChanged: The city has been updated by another request
+func ErrCityChanged(id any, version any) *runtime.Error {
+	return runtime.Errorf("city %v has been changed, version %v is out of date", id, version).SetCode(ErrCodeCityChanged).SetStatus(409)
+}
+
+// error: API.System.City@CityNotFound
+const ErrCodeCityNotFound = 10001
+
+// ErrCityNotFound: The city does not exist or has been deleted
+func ErrCityNotFound(id any) *runtime.Error {
+	return runtime.Errorf("city %v not found", id).SetCode(ErrCodeCityNotFound).SetStatus(404)
+}
+
 // Action: API.System.City:Create
 var fnCreate FuncCreate
 type FuncCreate = func(ctx *runtime.Context, city db_city.Create) (db_city.Create, *runtime.Error)
@@ -25,11 +42,11 @@ func OnDelete (fn FuncDelete) {
 	fnDelete = fn
 }
 
-// Action: API.System.City:Update
-var fnUpdate FuncUpdate
-type FuncUpdate = func(ctx *runtime.Context, v db_city.Update) (db_city.Update, *runtime.Error)
-func OnUpdate (fn FuncUpdate) {
-	fnUpdate = fn
+// Action: API.System.City:Page
+var fnPage FuncPage
+type FuncPage = func(ctx *runtime.Context, v db_city.Query) (db_city.FullPage, *runtime.Error)
+func OnPage (fn FuncPage) {
+	fnPage = fn
 }
 
 // Action: API.System.City:Query
@@ -39,19 +56,28 @@ func OnQuery (fn FuncQuery) {
 	fnQuery = fn
 }
 
+// Action: API.System.City:Update
+var fnUpdate FuncUpdate
+type FuncUpdate = func(ctx *runtime.Context, v db_city.Update) (db_city.Update, *runtime.Error)
+func OnUpdate (fn FuncUpdate) {
+	fnUpdate = fn
+}
+
 func init() {
+	runtime.RegisterPublicErrorCode(ErrCodeCityChanged)
+	runtime.RegisterPublicErrorCode(ErrCodeCityNotFound)
 	runtime.RegisterHandler("API.System.City:Create", func(ctx *runtime.Context, data []byte) *runtime.Return {
 		var v struct {
 			City db_city.Create `json:"city" required:"true"`
 		}
-		if err := runtime.JsonUnmarshal(data, &v); err != nil {
-			return nil
+		if err := ctx.Unmarshal(data, &v); err != nil {
+			return &runtime.Return{Code: runtime.ErrActionDecode, Message: err.Error(), Err: err}
 		}
 
 		if fnCreate == nil {
 			return &runtime.Return{Code: runtime.ErrActionNotImplemented, Message: "API.System.City:Create is not implemented"}
 		} else if result, err := fnCreate(ctx, v.City); err != nil {
-			return &runtime.Return{Code: err.Code(), Message: err.Error()}
+			return &runtime.Return{Code: err.Code(), Message: err.Error(), Err: err}
 		} else {
 			return &runtime.Return{Data: result}
 		}
@@ -60,30 +86,30 @@ func init() {
 		var v struct {
 			V db_city.Delete `json:"v" required:"true"`
 		}
-		if err := runtime.JsonUnmarshal(data, &v); err != nil {
-			return nil
+		if err := ctx.Unmarshal(data, &v); err != nil {
+			return &runtime.Return{Code: runtime.ErrActionDecode, Message: err.Error(), Err: err}
 		}
 
 		if fnDelete == nil {
 			return &runtime.Return{Code: runtime.ErrActionNotImplemented, Message: "API.System.City:Delete is not implemented"}
 		} else if result, err := fnDelete(ctx, v.V); err != nil {
-			return &runtime.Return{Code: err.Code(), Message: err.Error()}
+			return &runtime.Return{Code: err.Code(), Message: err.Error(), Err: err}
 		} else {
 			return &runtime.Return{Data: result}
 		}
 	})
-	runtime.RegisterHandler("API.System.City:Update", func(ctx *runtime.Context, data []byte) *runtime.Return {
+	runtime.RegisterHandler("API.System.City:Page", func(ctx *runtime.Context, data []byte) *runtime.Return {
 		var v struct {
-			V db_city.Update `json:"v" required:"true"`
+			V db_city.Query `json:"v" required:"true"`
 		}
-		if err := runtime.JsonUnmarshal(data, &v); err != nil {
-			return nil
+		if err := ctx.Unmarshal(data, &v); err != nil {
+			return &runtime.Return{Code: runtime.ErrActionDecode, Message: err.Error(), Err: err}
 		}
 
-		if fnUpdate == nil {
-			return &runtime.Return{Code: runtime.ErrActionNotImplemented, Message: "API.System.City:Update is not implemented"}
-		} else if result, err := fnUpdate(ctx, v.V); err != nil {
-			return &runtime.Return{Code: err.Code(), Message: err.Error()}
+		if fnPage == nil {
+			return &runtime.Return{Code: runtime.ErrActionNotImplemented, Message: "API.System.City:Page is not implemented"}
+		} else if result, err := fnPage(ctx, v.V); err != nil {
+			return &runtime.Return{Code: err.Code(), Message: err.Error(), Err: err}
 		} else {
 			return &runtime.Return{Data: result}
 		}
@@ -92,14 +118,30 @@ func init() {
 		var v struct {
 			V db_city.Query `json:"v" required:"true"`
 		}
-		if err := runtime.JsonUnmarshal(data, &v); err != nil {
-			return nil
+		if err := ctx.Unmarshal(data, &v); err != nil {
+			return &runtime.Return{Code: runtime.ErrActionDecode, Message: err.Error(), Err: err}
 		}
 
 		if fnQuery == nil {
 			return &runtime.Return{Code: runtime.ErrActionNotImplemented, Message: "API.System.City:Query is not implemented"}
 		} else if result, err := fnQuery(ctx, v.V); err != nil {
-			return &runtime.Return{Code: err.Code(), Message: err.Error()}
+			return &runtime.Return{Code: err.Code(), Message: err.Error(), Err: err}
+		} else {
+			return &runtime.Return{Data: result}
+		}
+	})
+	runtime.RegisterHandler("API.System.City:Update", func(ctx *runtime.Context, data []byte) *runtime.Return {
+		var v struct {
+			V db_city.Update `json:"v" required:"true"`
+		}
+		if err := ctx.Unmarshal(data, &v); err != nil {
+			return &runtime.Return{Code: runtime.ErrActionDecode, Message: err.Error(), Err: err}
+		}
+
+		if fnUpdate == nil {
+			return &runtime.Return{Code: runtime.ErrActionNotImplemented, Message: "API.System.City:Update is not implemented"}
+		} else if result, err := fnUpdate(ctx, v.V); err != nil {
+			return &runtime.Return{Code: err.Code(), Message: err.Error(), Err: err}
 		} else {
 			return &runtime.Return{Data: result}
 		}
@@ -3,7 +3,10 @@ package runtime
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
+	"time"
 
 	_ "github.com/lib/pq"
 )
@@ -22,311 +25,760 @@ func NewPGAgent() ISqlAgent {
 	return &PGSqlAgent{}
 }
 
-func (p *PGSqlAgent) DataSource(host string, port uint16, user string, password string, dbName string) string {
-	if dbName == "" {
-		return fmt.Sprintf(
-			"host=%s port=%d user=%s password=%s sslmode=disable",
-			host, port, user, password,
-		)
+// DataSource is the connection string of the server at host and port with the
+// user, TLS and statement timeout of connect, dbName is empty to connect to the
+// server without a database
+func (p *PGSqlAgent) DataSource(connect *DBConnectConfig, host string, port uint16, dbName string) string {
+	ret := fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s",
+		pgQuoteDataSource(host), port, pgQuoteDataSource(connect.User), pgQuoteDataSource(connect.Password),
+	)
+
+	if dbName != "" {
+		ret += " dbname=" + pgQuoteDataSource(dbName)
+	}
+
+	if tls := connect.TLS; tls == nil || tls.Mode == "" {
+		ret += " sslmode=disable"
 	} else {
-		return fmt.Sprintf(
-			"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-			host, port, user, password, dbName,
-		)
+		ret += " sslmode=" + pgQuoteDataSource(tls.Mode)
+		if tls.RootCert != "" {
+			ret += " sslrootcert=" + pgQuoteDataSource(tls.RootCert)
+		}
+		if tls.Cert != "" {
+			ret += " sslcert=" + pgQuoteDataSource(tls.Cert)
+		}
+		if tls.Key != "" {
+			ret += " sslkey=" + pgQuoteDataSource(tls.Key)
+		}
 	}
+
+	// the unknown keys are sent to the server as run-time parameters
+	if timeout, _ := time.ParseDuration(connect.StatementTimeout); timeout > 0 {
+		ret += fmt.Sprintf(" statement_timeout=%d", timeout.Milliseconds())
+	}
+
+	return ret
 }
 
-func (p *PGSqlAgent) HasDatabase(dbName string) string {
-	return fmt.Sprintf("SELECT 1 from %s WHERE datname='%s';", "pg_database", dbName)
+// pgQuoteDataSource quotes a value of the key/value connection string
+func pgQuoteDataSource(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// QuoteIdentifier quotes a table, column, index or constraint name
+func (p *PGSqlAgent) QuoteIdentifier(name string) string {
+	return "\"" + strings.ReplaceAll(name, "\"", "\"\"") + "\""
+}
+
+// quoteIdentifiers quotes the names and joins them by commas
+func (p *PGSqlAgent) quoteIdentifiers(names []string) string {
+	ret := make([]string, len(names))
+	for i, name := range names {
+		ret[i] = p.QuoteIdentifier(name)
+	}
+	return strings.Join(ret, ",")
+}
+
+// QuoteLiteral quotes a string constant, e.g. the values of an enum check,
+// for the statements that can not take args
+func (p *PGSqlAgent) QuoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// HasDatabase selects whether the database exists, the arg is the database name
+func (p *PGSqlAgent) HasDatabase() string {
+	return "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1);"
 }
 
 func (p *PGSqlAgent) CreateDatabase(dbName string) string {
-	return fmt.Sprintf("CREATE DATABASE \"%s\";", dbName)
+	return fmt.Sprintf("CREATE DATABASE %s;", p.QuoteIdentifier(dbName))
 }
 
 func (p *PGSqlAgent) DropDatabase(dbName string) string {
-	return fmt.Sprintf("DROP DATABASE \"%s\";", dbName)
+	return fmt.Sprintf("DROP DATABASE %s;", p.QuoteIdentifier(dbName))
 }
 
 func (p *PGSqlAgent) CreateMetaTable() string {
-	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS \"%s\" (id text NOT NULL PRIMARY KEY, meta text);", gSqlMetaTableName)
-
+	return fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (id text NOT NULL PRIMARY KEY, meta text);",
+		p.QuoteIdentifier(gSqlMetaTableName),
+	)
 }
+
+// QueryMetaTable selects the meta of a table, the arg is the table name
 func (p *PGSqlAgent) QueryMetaTable() string {
-	return fmt.Sprintf("SELECT meta FROM \"%s\" where id = $1;", gSqlMetaTableName)
+	return fmt.Sprintf("SELECT meta FROM %s WHERE id = $1;", p.QuoteIdentifier(gSqlMetaTableName))
 }
 
-func (p *PGSqlAgent) InsertMetaTable(serviceName string, meta string) string {
-	return fmt.Sprintf(
-		"INSERT INTO \"%s\" (id, meta) VALUES('%s', '%s');",
-		gSqlMetaTableName,
-		serviceName,
-		meta,
-	)
+// InsertMetaTable inserts the meta of a table, the args are the table name and the meta
+func (p *PGSqlAgent) InsertMetaTable() string {
+	return fmt.Sprintf("INSERT INTO %s (id, meta) VALUES($1, $2);", p.QuoteIdentifier(gSqlMetaTableName))
 }
 
-func (p *PGSqlAgent) UpdateMetaTable(serviceName string, meta string) string {
-	return fmt.Sprintf(
-		"UPDATE \"%s\" SET meta = '%s' WHERE id = '%s';",
-		gSqlMetaTableName,
-		meta,
-		serviceName,
-	)
+// UpdateMetaTable updates the meta of a table, the args are the table name and the meta
+func (p *PGSqlAgent) UpdateMetaTable() string {
+	return fmt.Sprintf("UPDATE %s SET meta = $2 WHERE id = $1;", p.QuoteIdentifier(gSqlMetaTableName))
 }
 
 func (p *PGSqlAgent) CreateServiceTable(serviceName string) string {
-	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS \"%s\" (id varchar(64) NOT NULL PRIMARY KEY);", serviceName)
+	return fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (id varchar(64) NOT NULL PRIMARY KEY);",
+		p.QuoteIdentifier(serviceName),
+	)
 }
 
 func (p *PGSqlAgent) AddColumn(serviceName string, columnName string, columnType string) string {
+	definition := ""
 	switch columnType {
 	case "LK":
-		return fmt.Sprintf(
-			"ALTER TABLE \"%s\" ADD COLUMN \"%s\" varchar(64) NOT NULL DEFAULT '';",
-			serviceName,
-			columnName,
-		)
+		definition = "varchar(64) NOT NULL DEFAULT ''"
 	case "Bool":
-		return fmt.Sprintf(
-			"ALTER TABLE \"%s\" ADD COLUMN \"%s\" boolean NOT NULL DEFAULT false;",
-			serviceName,
-			columnName,
-		)
+		definition = "boolean NOT NULL DEFAULT false"
 	case "Int64":
-		return fmt.Sprintf(
-			"ALTER TABLE \"%s\" ADD COLUMN \"%s\" bigint NOT NULL DEFAULT 0;",
-			serviceName,
-			columnName,
-		)
+		definition = "bigint NOT NULL DEFAULT 0"
 	case "Float64":
-		return fmt.Sprintf(
-			"ALTER TABLE \"%s\" ADD COLUMN \"%s\" double precision NOT NULL DEFAULT 0;",
-			serviceName,
-			columnName,
-		)
+		definition = "double precision NOT NULL DEFAULT 0"
 	case "Bytes":
-		return fmt.Sprintf(
-			"ALTER TABLE \"%s\" ADD COLUMN \"%s\" bytea NOT NULL DEFAULT '';",
-			serviceName,
-			columnName,
-		)
+		definition = "bytea NOT NULL DEFAULT ''"
+	case "Time":
+		definition = "timestamp with time zone NOT NULL DEFAULT 'epoch'"
+	case "Date":
+		definition = "date NOT NULL DEFAULT '1970-01-01'"
+	case "Decimal":
+		definition = "numeric NOT NULL DEFAULT 0"
+	case "UUID":
+		definition = "uuid NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000'"
 	case "String16":
-		return fmt.Sprintf(
-			"ALTER TABLE \"%s\" ADD COLUMN \"%s\" varchar(16) NOT NULL DEFAULT '';",
-			serviceName,
-			columnName,
-		)
+		definition = "varchar(16) NOT NULL DEFAULT ''"
 	case "String32":
-		return fmt.Sprintf(
-			"ALTER TABLE \"%s\" ADD COLUMN \"%s\" varchar(32) NOT NULL DEFAULT '';",
-			serviceName,
-			columnName,
-		)
+		definition = "varchar(32) NOT NULL DEFAULT ''"
 	case "String64":
-		return fmt.Sprintf(
-			"ALTER TABLE \"%s\" ADD COLUMN \"%s\" varchar(64) NOT NULL DEFAULT '';",
-			serviceName,
-			columnName,
-		)
+		definition = "varchar(64) NOT NULL DEFAULT ''"
 	case "String256":
+		definition = "varchar(256) NOT NULL DEFAULT ''"
+	case "String", "Enum":
+		definition = "text NOT NULL DEFAULT ''"
+	case "GeoPoint":
+		// the point is (longitude, latitude), the GiST index serves the box
+		// operator and the bounding box of the radius operator
 		return fmt.Sprintf(
-			"ALTER TABLE \"%s\" ADD COLUMN \"%s\" varchar(256) NOT NULL DEFAULT '';",
-			serviceName,
-			columnName,
-		)
-	case "String":
-		return fmt.Sprintf(
-			"ALTER TABLE \"%s\" ADD COLUMN \"%s\" text NOT NULL DEFAULT '';",
-			serviceName,
-			columnName,
-		)
-	case "List<String>":
-		return fmt.Sprintf(
-			"ALTER TABLE \"%s\" ADD COLUMN \"%s\" text NOT NULL DEFAULT '[]';",
-			serviceName,
-			columnName,
-		)
-	case "Map<String>":
-		return fmt.Sprintf(
-			"ALTER TABLE \"%s\" ADD COLUMN \"%s\" text NOT NULL DEFAULT '{}';",
-			serviceName,
-			columnName,
-		)
-	case "LKList":
-		return fmt.Sprintf(
-			"ALTER TABLE \"%s\" ADD COLUMN \"%s\" text NOT NULL DEFAULT '[]';",
-			serviceName,
-			columnName,
-		)
-	case "LKMap":
-		return fmt.Sprintf(
-			"ALTER TABLE \"%s\" ADD COLUMN \"%s\" text NOT NULL DEFAULT '{}';",
-			serviceName,
-			columnName,
+			"ALTER TABLE %s ADD COLUMN %s point NOT NULL DEFAULT '(0,0)'; CREATE INDEX %s ON %s USING GIST (%s);",
+			p.QuoteIdentifier(serviceName), p.QuoteIdentifier(columnName),
+			p.QuoteIdentifier(serviceName+"__spatial__"+columnName),
+			p.QuoteIdentifier(serviceName), p.QuoteIdentifier(columnName),
 		)
+	case "List<String>", "LKList":
+		definition = "text NOT NULL DEFAULT '[]'"
+	case "Map<String>", "LKMap":
+		definition = "text NOT NULL DEFAULT '{}'"
+	default:
+		return ""
 	}
 
-	return ""
+	return fmt.Sprintf(
+		"ALTER TABLE %s ADD COLUMN %s %s;",
+		p.QuoteIdentifier(serviceName), p.QuoteIdentifier(columnName), definition,
+	)
 }
 
 func (p *PGSqlAgent) DropColumn(serviceName string, columnName string) string {
-	return fmt.Sprintf("ALTER TABLE \"%s\" DROP COLUMN \"%s\";", serviceName, columnName)
+	return fmt.Sprintf(
+		"ALTER TABLE %s DROP COLUMN %s;",
+		p.QuoteIdentifier(serviceName), p.QuoteIdentifier(columnName),
+	)
 }
 
 func (p *PGSqlAgent) CreateIndex(serviceName string, columnName string) string {
 	return fmt.Sprintf(
-		"CREATE INDEX %s__index__%s ON \"%s\" (\"%s\");",
-		serviceName, columnName, serviceName, columnName,
+		"CREATE INDEX %s ON %s (%s);",
+		p.QuoteIdentifier(serviceName+"__index__"+columnName),
+		p.QuoteIdentifier(serviceName), p.QuoteIdentifier(columnName),
 	)
 }
 
 func (p *PGSqlAgent) DropIndex(serviceName string, columnName string) string {
-	return fmt.Sprintf(
-		"DROP INDEX %s__index__%s;",
-		serviceName, columnName,
-	)
+	return fmt.Sprintf("DROP INDEX %s;", p.QuoteIdentifier(serviceName+"__index__"+columnName))
 }
 
 func (p *PGSqlAgent) CreateUnique(serviceName string, columnName string) string {
 	return fmt.Sprintf(
-		"ALTER TABLE \"%s\" ADD CONSTRAINT %s__unique__%s UNIQUE (\"%s\");",
-		serviceName, serviceName, columnName, columnName,
+		"ALTER TABLE %s ADD CONSTRAINT %s UNIQUE (%s);",
+		p.QuoteIdentifier(serviceName),
+		p.QuoteIdentifier(serviceName+"__unique__"+columnName),
+		p.QuoteIdentifier(columnName),
 	)
 }
 
 func (p *PGSqlAgent) DropUnique(serviceName string, columnName string) string {
 	return fmt.Sprintf(
-		"ALTER TABLE \"%s\" DROP CONSTRAINT %s__unique__%s;",
-		serviceName, serviceName, columnName,
+		"ALTER TABLE %s DROP CONSTRAINT %s;",
+		p.QuoteIdentifier(serviceName),
+		p.QuoteIdentifier(serviceName+"__unique__"+columnName),
 	)
 }
 
+// CreateCompositeIndex creates the index, the where of a partial index is
+// the sql of the table meta, it is checked and its columns are quoted by the
+// builder
+func (p *PGSqlAgent) CreateCompositeIndex(serviceName string, indexName string, index *DBTableIndex) string {
+	columns := make([]string, len(index.Columns))
+	for i, column := range index.Columns {
+		columnName, direction, _ := strings.Cut(column, ":")
+		if direction == "descend" {
+			columns[i] = p.QuoteIdentifier(columnName) + " DESC"
+		} else {
+			columns[i] = p.QuoteIdentifier(columnName) + " ASC"
+		}
+	}
+
+	unique := ""
+	if index.Unique {
+		unique = "UNIQUE "
+	}
+
+	where := ""
+	if index.Where != "" {
+		where = " WHERE " + index.Where
+	}
+
+	return fmt.Sprintf(
+		"CREATE %sINDEX %s ON %s (%s)%s;",
+		unique,
+		p.QuoteIdentifier(serviceName+"__cindex__"+indexName),
+		p.QuoteIdentifier(serviceName),
+		strings.Join(columns, ", "),
+		where,
+	)
+}
+
+func (p *PGSqlAgent) DropCompositeIndex(serviceName string, indexName string) string {
+	return fmt.Sprintf("DROP INDEX IF EXISTS %s;", p.QuoteIdentifier(serviceName+"__cindex__"+indexName))
+}
+
+func (p *PGSqlAgent) CreateEnumCheck(serviceName string, columnName string, values []string) string {
+	literals := make([]string, len(values))
+	for i, value := range values {
+		literals[i] = p.QuoteLiteral(value)
+	}
+
+	// the empty string is the column default, it means the value is not set
+	column := p.QuoteIdentifier(columnName)
+	return fmt.Sprintf(
+		"ALTER TABLE %s ADD CONSTRAINT %s CHECK (%s = '' OR %s IN (%s));",
+		p.QuoteIdentifier(serviceName),
+		p.QuoteIdentifier(serviceName+"__check__"+columnName),
+		column, column, strings.Join(literals, ","),
+	)
+}
+
+func (p *PGSqlAgent) DropEnumCheck(serviceName string, columnName string) string {
+	return fmt.Sprintf(
+		"ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s;",
+		p.QuoteIdentifier(serviceName),
+		p.QuoteIdentifier(serviceName+"__check__"+columnName),
+	)
+}
+
+// CreateForeignKey makes the link column nullable, because an empty link
+// can not reference a record, and adds the foreign key constraint
+func (p *PGSqlAgent) CreateForeignKey(serviceName string, columnName string, linkTable string, onDelete string) string {
+	action := "NO ACTION"
+	switch onDelete {
+	case "restrict":
+		action = "RESTRICT"
+	case "cascade":
+		action = "CASCADE"
+	case "setNull":
+		action = "SET NULL"
+	}
+
+	table, column := p.QuoteIdentifier(serviceName), p.QuoteIdentifier(columnName)
+	return fmt.Sprintf(
+		"ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL, ALTER COLUMN %s SET DEFAULT NULL; "+
+			"UPDATE %s SET %s = NULL WHERE %s = ''; "+
+			"ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (id) ON DELETE %s;",
+		table, column, column,
+		table, column, column,
+		table, p.QuoteIdentifier(serviceName+"__fk__"+columnName), column, p.QuoteIdentifier(linkTable), action,
+	)
+}
+
+// DropForeignKey drops the foreign key constraint and restores the link column
+func (p *PGSqlAgent) DropForeignKey(serviceName string, columnName string) string {
+	table, column := p.QuoteIdentifier(serviceName), p.QuoteIdentifier(columnName)
+	return fmt.Sprintf(
+		"ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s; "+
+			"UPDATE %s SET %s = '' WHERE %s IS NULL; "+
+			"ALTER TABLE %s ALTER COLUMN %s SET DEFAULT '', ALTER COLUMN %s SET NOT NULL;",
+		table, p.QuoteIdentifier(serviceName+"__fk__"+columnName),
+		table, column, column,
+		table, column, column,
+	)
+}
+
+// CreateSearch adds the tsvector column of the search column, it is
+// generated by postgres, and the GIN index of it
+func (p *PGSqlAgent) CreateSearch(serviceName string, columnName string) string {
+	table, search := p.QuoteIdentifier(serviceName), p.QuoteIdentifier(columnName+"__search")
+	return fmt.Sprintf(
+		"ALTER TABLE %s ADD COLUMN %s tsvector GENERATED ALWAYS AS (to_tsvector(%s, %s)) STORED; "+
+			"CREATE INDEX %s ON %s USING GIN (%s);",
+		table, search, p.QuoteLiteral(gSqlSearchConfig), p.QuoteIdentifier(columnName),
+		p.QuoteIdentifier(serviceName+"__search__"+columnName), table, search,
+	)
+}
+
+func (p *PGSqlAgent) DropSearch(serviceName string, columnName string) string {
+	return fmt.Sprintf(
+		"DROP INDEX IF EXISTS %s; ALTER TABLE %s DROP COLUMN IF EXISTS %s;",
+		p.QuoteIdentifier(serviceName+"__search__"+columnName),
+		p.QuoteIdentifier(serviceName), p.QuoteIdentifier(columnName+"__search"),
+	)
+}
+
+// MaxArgs is the max number of args of a statement, postgres accepts at most
+// 65535 parameters
+func (p *PGSqlAgent) MaxArgs() int {
+	return len(gSqlPostgresCompileArgs) - 1
+}
+
 func (p *PGSqlAgent) Insert(serviceName string, keys []string) string {
 	return fmt.Sprintf(
-		"INSERT INTO \"%s\" (%s) VALUES(%s);",
-		serviceName,
-		"\""+strings.Join(keys, "\",\"")+"\"",
+		"INSERT INTO %s (%s) VALUES(%s);",
+		p.QuoteIdentifier(serviceName),
+		p.quoteIdentifiers(keys),
 		strings.Join(gSqlPostgresCompileArgs[:len(keys)], ","),
 	)
 }
 
-func (p *PGSqlAgent) Update(serviceName string, keys []string) string {
+// values builds the VALUES rows of keys, the args are the values of keys row by row
+func (p *PGSqlAgent) values(keys []string, rows int) string {
+	values := make([]string, rows)
+	for i := 0; i < rows; i++ {
+		values[i] = "(" + strings.Join(gSqlPostgresCompileArgs[i*len(keys):(i+1)*len(keys)], ",") + ")"
+	}
+	return strings.Join(values, ",")
+}
+
+// InsertMany inserts rows records in a statement, the args are the values
+// of keys row by row
+func (p *PGSqlAgent) InsertMany(serviceName string, keys []string, rows int) string {
+	return fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES %s;",
+		p.QuoteIdentifier(serviceName),
+		p.quoteIdentifiers(keys),
+		p.values(keys, rows),
+	)
+}
+
+// Upsert inserts rows records, a record that conflicts with an existing
+// record on columnName updates it instead, except the id, the conflict column
+// and the creation columns. The update is skipped if the existing record does
+// not match conditions, the args are the values of keys row by row, followed
+// by the values of conditions. It returns the ids and the texts of columnName
+// of the inserted or updated records, the order is not the order of the rows.
+func (p *PGSqlAgent) Upsert(
+	serviceName string,
+	keys []string,
+	rows int,
+	columnName string,
+	conditions []string,
+	versioned bool,
+) string {
+	sets := make([]string, 0, len(keys))
+	for _, key := range keys {
+		switch key {
+		case "id", columnName, SqlColumnCreatedAt, SqlColumnCreatedBy:
+			continue
+		default:
+			sets = append(sets, p.QuoteIdentifier(key)+" = EXCLUDED."+p.QuoteIdentifier(key))
+		}
+	}
+
+	if versioned {
+		sets = append(sets, p.QuoteIdentifier(SqlColumnVersion)+" = "+
+			p.QuoteIdentifier(serviceName)+"."+p.QuoteIdentifier(SqlColumnVersion)+" + 1")
+	}
+
+	// the conflict column is set to itself, so that the record is returned
+	if len(sets) == 0 {
+		sets = append(sets, p.QuoteIdentifier(columnName)+" = EXCLUDED."+p.QuoteIdentifier(columnName))
+	}
+
+	where := ""
+	for i, condition := range conditions {
+		if i == 0 {
+			where += " WHERE "
+		} else {
+			where += " AND "
+		}
+		where += p.QuoteIdentifier(serviceName) + "." + p.QuoteIdentifier(condition) +
+			" = " + gSqlPostgresCompileArgs[rows*len(keys)+i]
+	}
+
+	return fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES %s ON CONFLICT (%s) DO UPDATE SET %s%s RETURNING id, %s::text;",
+		p.QuoteIdentifier(serviceName),
+		p.quoteIdentifiers(keys),
+		p.values(keys, rows),
+		p.QuoteIdentifier(columnName),
+		strings.Join(sets, ","),
+		where,
+		p.QuoteIdentifier(columnName),
+	)
+}
+
+// Update updates keys of the record with id, the args are the values of keys,
+// followed by the id and the values of conditions. If version is one of the
+// conditions, it is increased by the update.
+func (p *PGSqlAgent) Update(serviceName string, keys []string, conditions []string) string {
+	sets := make([]string, len(keys))
+	for i, key := range keys {
+		sets[i] = p.QuoteIdentifier(key) + " = " + gSqlPostgresCompileArgs[i]
+	}
+
+	where := "id = " + gSqlPostgresCompileArgs[len(keys)]
+	for i, condition := range conditions {
+		where += " AND " + p.QuoteIdentifier(condition) + " = " + gSqlPostgresCompileArgs[len(keys)+1+i]
+		if condition == SqlColumnVersion {
+			sets = append(sets, p.QuoteIdentifier(SqlColumnVersion)+" = "+p.QuoteIdentifier(SqlColumnVersion)+" + 1")
+		}
+	}
+
+	return fmt.Sprintf(
+		"UPDATE %s SET %s WHERE %s;",
+		p.QuoteIdentifier(serviceName),
+		strings.Join(sets, ","),
+		where,
+	)
+}
+
+// UpdateMany updates keys of the records matched by where, the args are the
+// values of keys followed by the args of where, see QueryWhere. The version of
+// a versioned table is increased by the update.
+func (p *PGSqlAgent) UpdateMany(serviceName string, keys []string, where string, versioned bool) string {
 	sets := make([]string, len(keys))
 	for i, key := range keys {
-		sets[i] = "\"" + key + "\" = " + gSqlPostgresCompileArgs[i]
+		sets[i] = p.QuoteIdentifier(key) + " = " + gSqlPostgresCompileArgs[i]
+	}
+
+	if versioned {
+		sets = append(sets, p.QuoteIdentifier(SqlColumnVersion)+" = "+p.QuoteIdentifier(SqlColumnVersion)+" + 1")
+	}
+
+	if where != "" {
+		where = " WHERE " + where
 	}
 
 	return fmt.Sprintf(
-		"UPDATE \"%s\" SET %s WHERE id = %s;",
-		serviceName,
+		"UPDATE %s SET %s%s;",
+		p.QuoteIdentifier(serviceName),
 		strings.Join(sets, ","),
-		gSqlPostgresCompileArgs[len(keys)],
+		where,
 	)
 }
 
 func (p *PGSqlAgent) Delete(serviceName string) string {
-	return fmt.Sprintf("DELETE FROM \"%s\" WHERE id = $1;", serviceName)
+	return fmt.Sprintf("DELETE FROM %s WHERE id = $1;", p.QuoteIdentifier(serviceName))
 }
 
-func (p *PGSqlAgent) QueryOrderBy(serviceName string, query *SqlQuery) string {
+// Exists selects the record with id, the args are the id and the values of conditions
+func (p *PGSqlAgent) Exists(serviceName string, conditions []string) string {
+	where := "id = $1"
+	for i, condition := range conditions {
+		where += " AND " + p.QuoteIdentifier(condition) + " = " + gSqlPostgresCompileArgs[i+1]
+	}
+
+	return fmt.Sprintf("SELECT 1 FROM %s WHERE %s;", p.QuoteIdentifier(serviceName), where)
+}
+
+// References selects the id and the link column of the records that link
+// to a record, the args are the linked id and the values of conditions
+func (p *PGSqlAgent) References(serviceName string, columnName string, columnType string, conditions []string) string {
+	column := p.QuoteIdentifier(columnName)
+	where := ""
+	switch columnType {
+	case "LKList":
+		where = column + "::jsonb @> jsonb_build_array($1::text)"
+	case "LKMap":
+		where = "EXISTS (SELECT 1 FROM jsonb_each_text(" + column + "::jsonb) WHERE value = $1)"
+	default:
+		where = column + " = $1"
+	}
+
+	for i, condition := range conditions {
+		where += " AND " + p.QuoteIdentifier(condition) + " = " + gSqlPostgresCompileArgs[i+1]
+	}
+
+	return fmt.Sprintf("SELECT id, %s FROM %s WHERE %s;", column, p.QuoteIdentifier(serviceName), where)
+}
+
+// QueryOrderBy builds the orders of query, the args are the texts of the search orders
+func (p *PGSqlAgent) QueryOrderBy(serviceName string, argStartPos int, query *SqlQuery) (string, []any) {
 	queryOrders := query.GetOrders()
+	args := make([]any, 0)
 
 	if len(queryOrders) == 0 {
-		return ""
+		return "", args
 	}
 
 	orders := make([]string, len(queryOrders))
 
 	for i, order := range queryOrders {
+		if order.search != "" {
+			orders[i] = fmt.Sprintf(
+				"ts_rank(%s, websearch_to_tsquery(%s, %s)) DESC",
+				p.QuoteIdentifier(order.name+"__search"), p.QuoteLiteral(gSqlSearchConfig),
+				gSqlPostgresCompileArgs[argStartPos+len(args)],
+			)
+			args = append(args, order.search)
+		} else if order.asc {
+			orders[i] = p.QuoteIdentifier(order.name) + " ASC"
+		} else {
+			orders[i] = p.QuoteIdentifier(order.name) + " DESC"
+		}
+	}
+
+	return strings.Join(orders, ", "), args
+}
+
+// QueryAfter builds the condition of the rows after the cursor in the order
+// of orders, the args are the values of the order columns of the cursor.
+func (p *PGSqlAgent) QueryAfter(serviceName string, argStartPos int, orders []*SqlOrderBy) string {
+	conditions := make([]string, len(orders))
+
+	for i, order := range orders {
+		terms := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			terms = append(terms, p.QuoteIdentifier(orders[j].name)+" = "+gSqlPostgresCompileArgs[argStartPos+j])
+		}
+
 		if order.asc {
-			orders[i] = "\"" + order.name + "\" ASC"
+			terms = append(terms, p.QuoteIdentifier(order.name)+" > "+gSqlPostgresCompileArgs[argStartPos+i])
 		} else {
-			orders[i] = "\"" + order.name + "\" DESC"
+			terms = append(terms, p.QuoteIdentifier(order.name)+" < "+gSqlPostgresCompileArgs[argStartPos+i])
 		}
+
+		conditions[i] = "(" + strings.Join(terms, " AND ") + ")"
 	}
 
-	return strings.Join(orders, ", ")
+	return "(" + strings.Join(conditions, " OR ") + ")"
+}
+
+func (p *PGSqlAgent) QueryAggregate(serviceName string, query *SqlQuery) string {
+	columns := []string{}
+
+	for _, column := range query.GetGroupBy() {
+		columns = append(columns, p.QuoteIdentifier(column))
+	}
+
+	for _, aggregate := range query.GetAggregates() {
+		target := "*"
+		if aggregate.GetColumnName() != "" {
+			target = p.QuoteIdentifier(aggregate.GetColumnName())
+		}
+		columns = append(columns, fmt.Sprintf(
+			"%s(%s) AS %s",
+			aggregate.GetFunc(), target, p.QuoteIdentifier(aggregate.GetName()),
+		))
+	}
+
+	return strings.Join(columns, ",")
+}
+
+func (p *PGSqlAgent) QueryGroupBy(serviceName string, query *SqlQuery) string {
+	groupBy := query.GetGroupBy()
+
+	if len(groupBy) == 0 {
+		return ""
+	}
+
+	return p.quoteIdentifiers(groupBy)
 }
 
 func (p *PGSqlAgent) QuerySelect(serviceName string, columns []string) string {
-	return "\"" + strings.Join(columns, "\",\"") + "\""
+	return p.quoteIdentifiers(columns)
 }
 
+// QueryWhere compiles the wheres of query, the placeholders of the args start
+// from argStartPos. The wheres are joined by their concats in order, so "and"
+// binds tighter than "or", a child query is compiled as a parenthesised group
+// and an empty child query is skipped.
 func (p *PGSqlAgent) QueryWhere(serviceName string, argStartPos int, query *SqlQuery) (string, []any, error) {
-	queryWheres := query.GetWheres()
-	whereSqls := make([]string, len(queryWheres))
-	args := make([]any, 0)
+	compiler := &pgWhereCompiler{agent: p, argStartPos: argStartPos, args: []any{}}
 
-	if len(queryWheres) == 0 {
-		return "", args, nil
+	if sql, e := compiler.compile(query); e != nil {
+		return "", nil, e
+	} else if argStartPos+len(compiler.args) > p.MaxArgs() {
+		return "", nil, fmt.Errorf("too many query args %d", len(compiler.args))
+	} else {
+		return sql, compiler.args, nil
 	}
+}
+
+// pgWhereCompiler compiles the wheres of a query, args are the args of the
+// compiled wheres in the order of their placeholders
+type pgWhereCompiler struct {
+	agent       *PGSqlAgent
+	argStartPos int
+	args        []any
+}
 
-	pos := argStartPos
+// arg appends v to the args and returns its placeholder
+func (p *pgWhereCompiler) arg(v any) string {
+	pos := p.argStartPos + len(p.args)
+	p.args = append(p.args, v)
 
-	for i, where := range queryWheres {
-		sql := ""
+	if pos < len(gSqlPostgresCompileArgs) {
+		return gSqlPostgresCompileArgs[pos]
+	}
+	return "$" + strconv.Itoa(pos+1)
+}
+
+func (p *pgWhereCompiler) compile(query *SqlQuery) (string, error) {
+	if query == nil {
+		return "", fmt.Errorf("query is nil")
+	}
 
-		if i != 0 {
-			sql = where.GetConcat() + " "
+	sqls := make([]string, 0, len(query.GetWheres()))
+	for _, where := range query.GetWheres() {
+		if sql, e := p.compileWhere(where); e != nil {
+			return "", e
+		} else if sql == "" {
+			continue
+		} else if len(sqls) == 0 {
+			sqls = append(sqls, sql)
+		} else {
+			sqls = append(sqls, where.GetConcat()+" "+sql)
 		}
+	}
 
-		op := where.GetOp()
-		columnName := where.GetColumnName()
-
-		switch op {
-		case SqlEqual, SqlNotEqual, SqlGreaterThan, SqlLessThan, SqlGreaterEqual, SqlLessEqual:
-			sql += "\"" + columnName + "\" " + string(op) + " " + gSqlPostgresCompileArgs[pos]
-			args = append(args, where.GetValue())
-			pos++
-		case SqlLike:
-			sql += "\"" + columnName + "\" " + string(op) + " '%' || " + gSqlPostgresCompileArgs[pos] + " || '%'"
-			args = append(args, where.GetValue())
-			pos++
-		case SqlIn, SqlNotIn:
-			containArgs := []any(nil)
-
-			if v, ok := where.GetValue().([]any); ok {
-				containArgs = v
-			} else if v, ok := where.GetValue().([]string); ok {
-				containArgs = make([]any, len(v))
-				for i, it := range v {
-					containArgs[i] = it
-				}
-			} else if v, ok := where.GetValue().([]int64); ok {
-				containArgs = make([]any, len(v))
-				for i, it := range v {
-					containArgs[i] = it
-				}
-			} else if v, ok := where.GetValue().([]int); ok {
-				containArgs = make([]any, len(v))
-				for i, it := range v {
-					containArgs[i] = it
-				}
-			} else {
-				return "", nil, fmt.Errorf("invalid in args %T", where.GetValue())
-			}
-
-			argSql := make([]string, 0)
-			for _, it := range containArgs {
-				argSql = append(argSql, gSqlPostgresCompileArgs[pos])
-				args = append(args, it)
-				pos++
-			}
-			sql += "\"" + columnName + "\" " + string(op) + " (" + strings.Join(argSql, ",") + ")"
-		case SqlChild:
-			if subSql, subArgs, e := p.QueryWhere(columnName, argStartPos+pos, where.GetValue().(*SqlQuery)); e != nil {
-				return "", nil, e
-			} else {
-				sql += subSql
-				args = append(args, subArgs...)
-				pos += len(subArgs)
-			}
+	if len(sqls) == 0 {
+		return "", nil
+	}
+
+	return "(" + strings.Join(sqls, " ") + ")", nil
+}
+
+func (p *pgWhereCompiler) compileWhere(where *SqlWhere) (string, error) {
+	op := where.GetOp()
+	columnName := where.GetColumnName()
+	column := p.agent.QuoteIdentifier(columnName)
+
+	switch op {
+	case SqlEqual, SqlNotEqual, SqlGreaterThan, SqlLessThan, SqlGreaterEqual, SqlLessEqual:
+		return column + " " + string(op) + " " + p.arg(where.GetValue()), nil
+	case SqlIsNull, SqlIsNotNull:
+		return column + " " + string(op), nil
+	case SqlLike:
+		return column + " " + string(op) + " '%' || " + p.arg(where.GetValue()) + " || '%'", nil
+	case SqlBetween:
+		if values, ok := sqlToSlice(where.GetValue()); !ok || len(values) != 2 {
+			return "", fmt.Errorf("invalid between args %v", where.GetValue())
+		} else {
+			return column + " between " + p.arg(values[0]) + " and " + p.arg(values[1]), nil
+		}
+	case SqlIn, SqlNotIn:
+		values, ok := sqlToSlice(where.GetValue())
+		if !ok {
+			return "", fmt.Errorf("invalid in args %T", where.GetValue())
+		}
+
+		// nothing is in the empty set
+		if len(values) == 0 && op == SqlIn {
+			return "false", nil
+		} else if len(values) == 0 {
+			return "true", nil
+		}
+
+		placeholders := make([]string, len(values))
+		for i, value := range values {
+			placeholders[i] = p.arg(value)
+		}
+		return column + " " + string(op) + " (" + strings.Join(placeholders, ",") + ")", nil
+	case SqlSearch:
+		return fmt.Sprintf(
+			"%s @@ websearch_to_tsquery(%s, %s)",
+			p.agent.QuoteIdentifier(columnName+"__search"), p.agent.QuoteLiteral(gSqlSearchConfig),
+			p.arg(where.GetValue()),
+		), nil
+	case SqlWithinBox:
+		if box, e := sqlToGeoBox(where.GetValue()); e != nil {
+			return "", e
+		} else {
+			return p.withinBox(columnName, box), nil
+		}
+	case SqlWithinRadius:
+		if radius, e := sqlToGeoRadius(where.GetValue()); e != nil {
+			return "", e
+		} else {
+			return p.withinRadius(columnName, radius), nil
 		}
+	case SqlChild, SqlNot:
+		child, ok := where.GetValue().(*SqlQuery)
+		if !ok {
+			return "", fmt.Errorf("invalid %s query %T", op, where.GetValue())
+		}
+
+		if sql, e := p.compile(child); e != nil {
+			return "", e
+		} else if sql == "" || op == SqlChild {
+			return sql, nil
+		} else {
+			return "not " + sql, nil
+		}
+	default:
+		return "", fmt.Errorf("unsupported operator %q", op)
+	}
+}
+
+func (p *pgWhereCompiler) withinBox(columnName string, box GeoBox) string {
+	return fmt.Sprintf(
+		"%s <@ box(point(%s::float8, %s::float8), point(%s::float8, %s::float8))",
+		p.agent.QuoteIdentifier(columnName),
+		p.arg(box.Min.Longitude), p.arg(box.Min.Latitude),
+		p.arg(box.Max.Longitude), p.arg(box.Max.Latitude),
+	)
+}
+
+// withinRadius compares the haversine distance with the radius, a bounding box
+// of the circle filters the rows by the spatial index first, it is skipped if
+// the circle crosses a pole or the antimeridian
+func (p *pgWhereCompiler) withinRadius(columnName string, radius GeoRadius) string {
+	center := radius.Center
+	column := p.agent.QuoteIdentifier(columnName)
+	latitude, longitude := p.arg(center.Latitude), p.arg(center.Longitude)
+	distance := fmt.Sprintf(
+		"%s * 2 * asin(least(1, sqrt("+
+			"power(sin(radians((%s)[1] - %s::float8) / 2), 2) + "+
+			"cos(radians(%s::float8)) * cos(radians((%s)[1])) * "+
+			"power(sin(radians((%s)[0] - %s::float8) / 2), 2)"+
+			"))) <= %s::float8",
+		strconv.FormatFloat(gSqlEarthRadius, 'f', -1, 64),
+		column, latitude,
+		latitude, column,
+		column, longitude,
+		p.arg(radius.Radius),
+	)
+
+	deltaLatitude := radius.Radius / gSqlEarthRadius * 180 / math.Pi
+	minLatitude, maxLatitude := center.Latitude-deltaLatitude, center.Latitude+deltaLatitude
+	if minLatitude <= -90 || maxLatitude >= 90 {
+		return "(" + distance + ")"
+	}
 
-		whereSqls[i] = sql
+	deltaLongitude := deltaLatitude / math.Cos(center.Latitude*math.Pi/180)
+	minLongitude, maxLongitude := center.Longitude-deltaLongitude, center.Longitude+deltaLongitude
+	if minLongitude <= -180 || maxLongitude >= 180 {
+		return "(" + distance + ")"
 	}
 
-	return "(" + strings.Join(whereSqls, " ") + ")", args, nil
+	box := p.withinBox(columnName, GeoBox{
+		Min: GeoPoint{Latitude: minLatitude, Longitude: minLongitude},
+		Max: GeoPoint{Latitude: maxLatitude, Longitude: maxLongitude},
+	})
+	return "(" + box + " AND " + distance + ")"
 }
 
 // tag-capi-builder-end
\ No newline at end of file
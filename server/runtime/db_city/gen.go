@@ -1,26 +1,51 @@
 // tag-capi-builder-start: This file is generated by capi-builder, DO NOT EDIT.
 package db_city
 import (
-	"github.com/ootiny/capi/server/runtime/db_geo"
 	"github.com/ootiny/capi/server/runtime"
+	"github.com/ootiny/capi/server/runtime/db_geo"
+	"time"
+)
+
+// enum: DB.City@Status
+type Status string
+
+const (
+	StatusActive Status = "active"
+	StatusInactive Status = "inactive"
+	StatusArchived Status = "archived"
 )
 
+func (v Status) Valid() bool {
+	switch v {
+	case StatusActive, StatusInactive, StatusArchived:
+		return true
+	default:
+		return false
+	}
+}
+
 // definition: DB.City@Create
 type Create struct {
 	Active bool `json:"active" required:"false"`
 	Age int64 `json:"age" required:"true"`
 	Area float64 `json:"area" required:"true"`
-	Geo db_geo.Create `json:"geo" required:"false"`
-	Geo_list db_geo.Create `json:"geo_list" required:"true"`
-	Geo_map db_geo.Create `json:"geo_map" required:"true"`
+	Budget string `json:"budget" required:"false"`
+	Code string `json:"code" required:"false"`
+	Emblem []byte `json:"emblem" required:"false"`
+	Founded runtime.Date `json:"founded" required:"false"`
+	Geo string `json:"geo" required:"false"`
+	Geo_list []string `json:"geo_list" required:"true"`
+	Geo_map map[string]string `json:"geo_map" required:"true"`
 	Id string `json:"id" required:"true"`
 	Name string `json:"name" required:"true"`
 	Name_16 string `json:"name_16" required:"true"`
 	Name_256 string `json:"name_256" required:"true"`
 	Name_32 string `json:"name_32" required:"true"`
 	Name_64 string `json:"name_64" required:"true"`
+	Status Status `json:"status" required:"false"`
 	Str_list []string `json:"str_list" required:"true"`
 	Str_map map[string]string `json:"str_map" required:"true"`
+	Surveyed_at time.Time `json:"surveyed_at" required:"false"`
 }
 
 type CreateBytes = []byte
@@ -64,10 +89,19 @@ type Full struct {
 	Area float64 `json:"area" required:"true"`
 	Str_list []string `json:"str_list" required:"true"`
 	Str_map map[string]string `json:"str_map" required:"true"`
-	Geo_list db_geo.Full `json:"geo_list" required:"true"`
-	Geo_map db_geo.Full `json:"geo_map" required:"true"`
+	Geo_list []db_geo.Full `json:"geo_list" required:"true"`
+	Geo_map map[string]db_geo.Full `json:"geo_map" required:"true"`
 	Geo db_geo.Full `json:"geo" required:"false"`
 	Active bool `json:"active" required:"false"`
+	Status Status `json:"status" required:"false"`
+	Founded runtime.Date `json:"founded" required:"false"`
+	Surveyed_at time.Time `json:"surveyed_at" required:"false"`
+	Budget string `json:"budget" required:"false"`
+	Code string `json:"code" required:"false"`
+	Created_at time.Time `json:"created_at" required:"true"`
+	Updated_at time.Time `json:"updated_at" required:"true"`
+	Updated_by string `json:"updated_by" required:"true"`
+	Version int64 `json:"version" required:"true"`
 }
 
 type FullBytes = []byte
@@ -82,10 +116,52 @@ func FullBytesToFull(data []byte) (*Full, *runtime.Error) {
 	return &v, nil
 }
 
+// definition: DB.City@FullPage
+type FullPage struct {
+	Items []Full `json:"items" required:"true"`
+	NextCursor string `json:"nextCursor" required:"false"`
+}
+
+type FullPageBytes = []byte
+func UnmarshalFullPage(data []byte, v *FullPage) *runtime.Error {
+	 return runtime.JsonUnmarshal(data, v)
+}
+func FullPageBytesToFullPage(data []byte) (*FullPage, *runtime.Error) {
+	var v FullPage
+	if err := runtime.JsonUnmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// definition: DB.City@Query
+type Query struct {
+	Where map[string]any `json:"where,omitempty" required:"false"`
+	Orders []string `json:"orders,omitempty" required:"false"`
+	Limit *int64 `json:"limit,omitempty" required:"false"`
+	Offset *int64 `json:"offset,omitempty" required:"false"`
+	Cursor *string `json:"cursor,omitempty" required:"false"`
+	Search *string `json:"search,omitempty" required:"false"`
+	IncludeDeleted *bool `json:"includeDeleted,omitempty" required:"false"`
+}
+
+type QueryBytes = []byte
+func UnmarshalQuery(data []byte, v *Query) *runtime.Error {
+	 return runtime.JsonUnmarshal(data, v)
+}
+func QueryBytesToQuery(data []byte) (*Query, *runtime.Error) {
+	var v Query
+	if err := runtime.JsonUnmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
 // definition: DB.City@Simple
 type Simple struct {
 	Id string `json:"id" required:"true"`
 	Name string `json:"name" required:"true"`
+	Version int64 `json:"version" required:"true"`
 }
 
 type SimpleBytes = []byte
@@ -100,5 +176,283 @@ func SimpleBytesToSimple(data []byte) (*Simple, *runtime.Error) {
 	return &v, nil
 }
 
+// definition: DB.City@SimplePage
+type SimplePage struct {
+	Items []Simple `json:"items" required:"true"`
+	NextCursor string `json:"nextCursor" required:"false"`
+}
+
+type SimplePageBytes = []byte
+func UnmarshalSimplePage(data []byte, v *SimplePage) *runtime.Error {
+	 return runtime.JsonUnmarshal(data, v)
+}
+func SimplePageBytesToSimplePage(data []byte) (*SimplePage, *runtime.Error) {
+	var v SimplePage
+	if err := runtime.JsonUnmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// definition: DB.City@Update
+type Update struct {
+	Active *bool `json:"active,omitempty" required:"false"`
+	Age *int64 `json:"age,omitempty" required:"false"`
+	Area *float64 `json:"area,omitempty" required:"false"`
+	Budget *string `json:"budget,omitempty" required:"false"`
+	Code *string `json:"code,omitempty" required:"false"`
+	Emblem []byte `json:"emblem,omitempty" required:"false"`
+	Founded *runtime.Date `json:"founded,omitempty" required:"false"`
+	Geo *string `json:"geo,omitempty" required:"false"`
+	Geo_list []string `json:"geo_list,omitempty" required:"false"`
+	Geo_map map[string]string `json:"geo_map,omitempty" required:"false"`
+	Id string `json:"id" required:"true"`
+	Name *string `json:"name,omitempty" required:"false"`
+	Name_16 *string `json:"name_16,omitempty" required:"false"`
+	Name_256 *string `json:"name_256,omitempty" required:"false"`
+	Name_32 *string `json:"name_32,omitempty" required:"false"`
+	Name_64 *string `json:"name_64,omitempty" required:"false"`
+	Status *Status `json:"status,omitempty" required:"false"`
+	Str_list []string `json:"str_list,omitempty" required:"false"`
+	Str_map map[string]string `json:"str_map,omitempty" required:"false"`
+	Surveyed_at *time.Time `json:"surveyed_at,omitempty" required:"false"`
+	Version int64 `json:"version" required:"true"`
+}
+
+type UpdateBytes = []byte
+func UnmarshalUpdate(data []byte, v *Update) *runtime.Error {
+	 return runtime.JsonUnmarshal(data, v)
+}
+func UpdateBytesToUpdate(data []byte) (*Update, *runtime.Error) {
+	var v Update
+	if err := runtime.JsonUnmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// table: DB.City
+const Table = "city"
+
+func createRecord(v Create) runtime.Record {
+	return runtime.Record{
+		"active": v.Active,
+		"age": v.Age,
+		"area": v.Area,
+		"budget": v.Budget,
+		"code": v.Code,
+		"emblem": v.Emblem,
+		"founded": v.Founded,
+		"geo": v.Geo,
+		"geo_list": v.Geo_list,
+		"geo_map": v.Geo_map,
+		"id": v.Id,
+		"name": v.Name,
+		"name_16": v.Name_16,
+		"name_256": v.Name_256,
+		"name_32": v.Name_32,
+		"name_64": v.Name_64,
+		"status": v.Status,
+		"str_list": v.Str_list,
+		"str_map": v.Str_map,
+		"surveyed_at": v.Surveyed_at,
+	}
+}
+
+// Insert inserts a record and returns its id
+func Insert(ctx *runtime.Context, v Create) (string, *runtime.Error) {
+	return runtime.DBInsert(ctx, Table, createRecord(v))
+}
+
+// InsertMany inserts the records and returns their ids
+func InsertMany(ctx *runtime.Context, vs []Create) ([]string, *runtime.Error) {
+	records := make([]runtime.Record, len(vs))
+	for i, v := range vs {
+		records[i] = createRecord(v)
+	}
+	return runtime.DBInsertMany(ctx, Table, records)
+}
+
+// Upsert inserts the records, a record that has the same value of the id or
+// unique column as an existing record updates it, it returns their ids
+func Upsert(ctx *runtime.Context, column string, vs []Create) ([]string, *runtime.Error) {
+	records := make([]runtime.Record, len(vs))
+	for i, v := range vs {
+		records[i] = createRecord(v)
+	}
+	return runtime.DBUpsert(ctx, Table, column, records)
+}
+
+// Modify updates the attributes of the record that are not omitted
+func Modify(ctx *runtime.Context, v Update) *runtime.Error {
+	record := runtime.Record{}
+	if v.Active != nil {
+		record["active"] = *v.Active
+	}
+	if v.Age != nil {
+		record["age"] = *v.Age
+	}
+	if v.Area != nil {
+		record["area"] = *v.Area
+	}
+	if v.Budget != nil {
+		record["budget"] = *v.Budget
+	}
+	if v.Code != nil {
+		record["code"] = *v.Code
+	}
+	if v.Emblem != nil {
+		record["emblem"] = v.Emblem
+	}
+	if v.Founded != nil {
+		record["founded"] = *v.Founded
+	}
+	if v.Geo != nil {
+		record["geo"] = *v.Geo
+	}
+	if v.Geo_list != nil {
+		record["geo_list"] = v.Geo_list
+	}
+	if v.Geo_map != nil {
+		record["geo_map"] = v.Geo_map
+	}
+	if v.Name != nil {
+		record["name"] = *v.Name
+	}
+	if v.Name_16 != nil {
+		record["name_16"] = *v.Name_16
+	}
+	if v.Name_256 != nil {
+		record["name_256"] = *v.Name_256
+	}
+	if v.Name_32 != nil {
+		record["name_32"] = *v.Name_32
+	}
+	if v.Name_64 != nil {
+		record["name_64"] = *v.Name_64
+	}
+	if v.Status != nil {
+		record["status"] = *v.Status
+	}
+	if v.Str_list != nil {
+		record["str_list"] = v.Str_list
+	}
+	if v.Str_map != nil {
+		record["str_map"] = v.Str_map
+	}
+	if v.Surveyed_at != nil {
+		record["surveyed_at"] = *v.Surveyed_at
+	}
+	record["version"] = v.Version
+	return runtime.DBUpdate(ctx, Table, v.Id, record)
+}
+
+// Remove deletes the record
+func Remove(ctx *runtime.Context, v Delete) *runtime.Error {
+	return runtime.DBDelete(ctx, Table, v.Id)
+}
+
+// Restore restores the deleted record
+func Restore(ctx *runtime.Context, v Delete) *runtime.Error {
+	return runtime.DBRestore(ctx, Table, v.Id)
+}
+
+func newQuery(q Query) *runtime.SqlQuery {
+	query := runtime.NewWebQuery(Table, q.Where, q.Orders)
+	if q.Limit != nil {
+		query.Limit(int(*q.Limit))
+	}
+	if q.Offset != nil {
+		query.Offset(int(*q.Offset))
+	}
+	if q.Cursor != nil {
+		query.Cursor(*q.Cursor)
+	}
+	if q.IncludeDeleted != nil && *q.IncludeDeleted {
+		query.IncludeDeleted()
+	}
+	if q.Search != nil && *q.Search != "" {
+		query.AndChild(runtime.NewQuery(Table).Or("name", runtime.SqlSearch, *q.Search))
+		if len(q.Orders) == 0 {
+			query.OrderBySearch("name", *q.Search)
+		}
+	}
+	return query
+}
+
+// UpdateMany updates the columns in record of the records matched by the
+// where of q and returns the count of the updated records
+func UpdateMany(ctx *runtime.Context, q Query, record runtime.Record) (int64, *runtime.Error) {
+	return runtime.DBUpdateMany(ctx, newQuery(q), record)
+}
+
+// QueryFull returns the Full view of the matched records
+func QueryFull(ctx *runtime.Context, q Query) ([]Full, *runtime.Error) {
+	ret := []Full{}
+	if err := runtime.DBQuery(ctx, newQuery(q).View("Full"), &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// QueryFullPage returns a page of the Full view of the matched records
+func QueryFullPage(ctx *runtime.Context, q Query) (*FullPage, *runtime.Error) {
+	ret := &FullPage{Items: []Full{}}
+	if cursor, err := runtime.DBQueryPage(ctx, newQuery(q).View("Full"), &ret.Items); err != nil {
+		return nil, err
+	} else {
+		ret.NextCursor = cursor
+		return ret, nil
+	}
+}
+
+// CountFull returns the count of the records matched by QueryFull without limit and offset
+func CountFull(ctx *runtime.Context, q Query) (int64, *runtime.Error) {
+	return runtime.DBCount(ctx, newQuery(q).View("Full"))
+}
+
+// GetFull returns the Full view of the record
+func GetFull(ctx *runtime.Context, id string) (*Full, *runtime.Error) {
+	var ret Full
+	if err := runtime.DBGet(ctx, Table, "Full", id, &ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// QuerySimple returns the Simple view of the matched records
+func QuerySimple(ctx *runtime.Context, q Query) ([]Simple, *runtime.Error) {
+	ret := []Simple{}
+	if err := runtime.DBQuery(ctx, newQuery(q).View("Simple"), &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// QuerySimplePage returns a page of the Simple view of the matched records
+func QuerySimplePage(ctx *runtime.Context, q Query) (*SimplePage, *runtime.Error) {
+	ret := &SimplePage{Items: []Simple{}}
+	if cursor, err := runtime.DBQueryPage(ctx, newQuery(q).View("Simple"), &ret.Items); err != nil {
+		return nil, err
+	} else {
+		ret.NextCursor = cursor
+		return ret, nil
+	}
+}
+
+// CountSimple returns the count of the records matched by QuerySimple without limit and offset
+func CountSimple(ctx *runtime.Context, q Query) (int64, *runtime.Error) {
+	return runtime.DBCount(ctx, newQuery(q).View("Simple"))
+}
+
+// GetSimple returns the Simple view of the record
+func GetSimple(ctx *runtime.Context, id string) (*Simple, *runtime.Error) {
+	var ret Simple
+	if err := runtime.DBGet(ctx, Table, "Simple", id, &ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
 
 // tag-capi-builder-end
\ No newline at end of file
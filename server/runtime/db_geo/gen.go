@@ -8,6 +8,7 @@ import (
 type Create struct {
 	Id string `json:"id" required:"false"`
 	Latitude float64 `json:"latitude" required:"false"`
+	Location runtime.GeoPoint `json:"location" required:"false"`
 	Longitude float64 `json:"longitude" required:"false"`
 }
 
@@ -45,6 +46,7 @@ type Full struct {
 	Id string `json:"id" required:"false"`
 	Latitude float64 `json:"latitude" required:"false"`
 	Longitude float64 `json:"longitude" required:"false"`
+	Location runtime.GeoPoint `json:"location" required:"false"`
 }
 
 type FullBytes = []byte
@@ -59,5 +61,174 @@ func FullBytesToFull(data []byte) (*Full, *runtime.Error) {
 	return &v, nil
 }
 
+// definition: DB.Geo@FullPage
+type FullPage struct {
+	Items []Full `json:"items" required:"true"`
+	NextCursor string `json:"nextCursor" required:"false"`
+}
+
+type FullPageBytes = []byte
+func UnmarshalFullPage(data []byte, v *FullPage) *runtime.Error {
+	 return runtime.JsonUnmarshal(data, v)
+}
+func FullPageBytesToFullPage(data []byte) (*FullPage, *runtime.Error) {
+	var v FullPage
+	if err := runtime.JsonUnmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// definition: DB.Geo@Query
+type Query struct {
+	Where map[string]any `json:"where,omitempty" required:"false"`
+	Orders []string `json:"orders,omitempty" required:"false"`
+	Limit *int64 `json:"limit,omitempty" required:"false"`
+	Offset *int64 `json:"offset,omitempty" required:"false"`
+	Cursor *string `json:"cursor,omitempty" required:"false"`
+}
+
+type QueryBytes = []byte
+func UnmarshalQuery(data []byte, v *Query) *runtime.Error {
+	 return runtime.JsonUnmarshal(data, v)
+}
+func QueryBytesToQuery(data []byte) (*Query, *runtime.Error) {
+	var v Query
+	if err := runtime.JsonUnmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// definition: DB.Geo@Update
+type Update struct {
+	Id string `json:"id" required:"true"`
+	Latitude *float64 `json:"latitude,omitempty" required:"false"`
+	Location *runtime.GeoPoint `json:"location,omitempty" required:"false"`
+	Longitude *float64 `json:"longitude,omitempty" required:"false"`
+}
+
+type UpdateBytes = []byte
+func UnmarshalUpdate(data []byte, v *Update) *runtime.Error {
+	 return runtime.JsonUnmarshal(data, v)
+}
+func UpdateBytesToUpdate(data []byte) (*Update, *runtime.Error) {
+	var v Update
+	if err := runtime.JsonUnmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// table: DB.Geo
+const Table = "geo"
+
+func createRecord(v Create) runtime.Record {
+	return runtime.Record{
+		"id": v.Id,
+		"latitude": v.Latitude,
+		"location": v.Location,
+		"longitude": v.Longitude,
+	}
+}
+
+// Insert inserts a record and returns its id
+func Insert(ctx *runtime.Context, v Create) (string, *runtime.Error) {
+	return runtime.DBInsert(ctx, Table, createRecord(v))
+}
+
+// InsertMany inserts the records and returns their ids
+func InsertMany(ctx *runtime.Context, vs []Create) ([]string, *runtime.Error) {
+	records := make([]runtime.Record, len(vs))
+	for i, v := range vs {
+		records[i] = createRecord(v)
+	}
+	return runtime.DBInsertMany(ctx, Table, records)
+}
+
+// Upsert inserts the records, a record that has the same value of the id or
+// unique column as an existing record updates it, it returns their ids
+func Upsert(ctx *runtime.Context, column string, vs []Create) ([]string, *runtime.Error) {
+	records := make([]runtime.Record, len(vs))
+	for i, v := range vs {
+		records[i] = createRecord(v)
+	}
+	return runtime.DBUpsert(ctx, Table, column, records)
+}
+
+// Modify updates the attributes of the record that are not omitted
+func Modify(ctx *runtime.Context, v Update) *runtime.Error {
+	record := runtime.Record{}
+	if v.Latitude != nil {
+		record["latitude"] = *v.Latitude
+	}
+	if v.Location != nil {
+		record["location"] = *v.Location
+	}
+	if v.Longitude != nil {
+		record["longitude"] = *v.Longitude
+	}
+	return runtime.DBUpdate(ctx, Table, v.Id, record)
+}
+
+// Remove deletes the record
+func Remove(ctx *runtime.Context, v Delete) *runtime.Error {
+	return runtime.DBDelete(ctx, Table, v.Id)
+}
+
+func newQuery(q Query) *runtime.SqlQuery {
+	query := runtime.NewWebQuery(Table, q.Where, q.Orders)
+	if q.Limit != nil {
+		query.Limit(int(*q.Limit))
+	}
+	if q.Offset != nil {
+		query.Offset(int(*q.Offset))
+	}
+	if q.Cursor != nil {
+		query.Cursor(*q.Cursor)
+	}
+	return query
+}
+
+// UpdateMany updates the columns in record of the records matched by the
+// where of q and returns the count of the updated records
+func UpdateMany(ctx *runtime.Context, q Query, record runtime.Record) (int64, *runtime.Error) {
+	return runtime.DBUpdateMany(ctx, newQuery(q), record)
+}
+
+// QueryFull returns the Full view of the matched records
+func QueryFull(ctx *runtime.Context, q Query) ([]Full, *runtime.Error) {
+	ret := []Full{}
+	if err := runtime.DBQuery(ctx, newQuery(q).View("Full"), &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// QueryFullPage returns a page of the Full view of the matched records
+func QueryFullPage(ctx *runtime.Context, q Query) (*FullPage, *runtime.Error) {
+	ret := &FullPage{Items: []Full{}}
+	if cursor, err := runtime.DBQueryPage(ctx, newQuery(q).View("Full"), &ret.Items); err != nil {
+		return nil, err
+	} else {
+		ret.NextCursor = cursor
+		return ret, nil
+	}
+}
+
+// CountFull returns the count of the records matched by QueryFull without limit and offset
+func CountFull(ctx *runtime.Context, q Query) (int64, *runtime.Error) {
+	return runtime.DBCount(ctx, newQuery(q).View("Full"))
+}
+
+// GetFull returns the Full view of the record
+func GetFull(ctx *runtime.Context, id string) (*Full, *runtime.Error) {
+	var ret Full
+	if err := runtime.DBGet(ctx, Table, "Full", id, &ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
 
 // tag-capi-builder-end
\ No newline at end of file
@@ -1,7 +1,18 @@
 // tag-capi-builder-start: This file is generated by capi-builder, DO NOT EDIT.
 package runtime
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type DBConnectConfig struct {
 	Driver   string `json:"driver" required:"true"`
@@ -10,6 +21,34 @@ type DBConnectConfig struct {
 	User     string `json:"user" required:"true"`
 	Password string `json:"password" required:"true"`
 	DBName   string `json:"dbName" required:"true"`
+	// StatementTimeout aborts the statements that run longer, e.g. "30s"
+	StatementTimeout string       `json:"statementTimeout,omitempty"`
+	TLS              *DBTLSConfig `json:"tls,omitempty"`
+}
+
+// DBTLSConfig is the TLS of the connections, mode is one of disable,
+// require, verify-ca and verify-full, the default is disable
+type DBTLSConfig struct {
+	Mode     string `json:"mode"`
+	RootCert string `json:"rootCert,omitempty"`
+	Cert     string `json:"cert,omitempty"`
+	Key      string `json:"key,omitempty"`
+}
+
+// DBPoolConfig is the connection pool of each database, the durations are
+// like "30m", zero values keep the defaults of database/sql
+type DBPoolConfig struct {
+	MaxOpenConns    int    `json:"maxOpenConns,omitempty"`
+	MaxIdleConns    int    `json:"maxIdleConns,omitempty"`
+	ConnMaxLifetime string `json:"connMaxLifetime,omitempty"`
+	ConnMaxIdleTime string `json:"connMaxIdleTime,omitempty"`
+}
+
+// DBReplicaConfig is a read replica, it shares the user, password, database
+// and TLS of the connect config
+type DBReplicaConfig struct {
+	Host string `json:"host" required:"true"`
+	Port uint16 `json:"port" required:"true"`
 }
 
 type DBCacheConfig struct {
@@ -19,26 +58,367 @@ type DBCacheConfig struct {
 }
 
 type DBConfig struct {
-	Connect *DBConnectConfig `json:"connect" required:"true"`
-	Cache   *DBCacheConfig   `json:"cache" required:"true"`
+	Connect  *DBConnectConfig   `json:"connect" required:"true"`
+	Cache    *DBCacheConfig     `json:"cache" required:"true"`
+	Pool     *DBPoolConfig      `json:"pool,omitempty"`
+	Replicas []*DBReplicaConfig `json:"replicas,omitempty"`
 }
 
+// gConfigRefRegex matches the references in the string fields of the configs,
+// ${env:NAME} is an environment variable and ${file:path} is the content of
+// a file, they are resolved when the config is loaded by the server
+var gConfigRefRegex = regexp.MustCompile(`\$\{(env|file):([^}]*)\}`)
+
+// gDBConfigEnvPrefix is the prefix of the environment variables that override
+// the fields of DBConfig, the name is the path of the json names in upper case,
+// e.g. CAPI_DB_CONNECT_PASSWORD and CAPI_DB_POOL_MAXOPENCONNS. The values of
+// the fields that are not strings are json, e.g. CAPI_DB_REPLICAS.
+const gDBConfigEnvPrefix = "CAPI_DB"
+
 func LoadDBConfig(jsonStr string) (*DBConfig, error) {
 	var config DBConfig
 	if err := json.Unmarshal([]byte(jsonStr), &config); err != nil {
 		return nil, err
+	} else if _, err := overrideConfigByEnv(reflect.ValueOf(&config).Elem(), gDBConfigEnvPrefix); err != nil {
+		return nil, err
+	} else if err := resolveConfigRefs(reflect.ValueOf(&config).Elem()); err != nil {
+		return nil, err
+	} else if err := config.check(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// overrideConfigByEnv sets the fields of the struct v by the environment
+// variables, a nil struct field is created if one of its fields is set.
+// It returns whether a field is set.
+func overrideConfigByEnv(v reflect.Value, prefix string) (bool, error) {
+	ret := false
+
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		envName := prefix + "_" + strings.ToUpper(name)
+		field := v.Field(i)
+
+		if envValue, ok := os.LookupEnv(envName); ok {
+			if field.Kind() == reflect.String {
+				field.SetString(envValue)
+			} else if err := json.Unmarshal([]byte(envValue), field.Addr().Interface()); err != nil {
+				return false, fmt.Errorf("config: invalid %s: %s", envName, err.Error())
+			}
+			ret = true
+		} else if field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.Struct {
+			fieldValue := field
+			if field.IsNil() {
+				fieldValue = reflect.New(field.Type().Elem())
+			}
+
+			if set, err := overrideConfigByEnv(fieldValue.Elem(), envName); err != nil {
+				return false, err
+			} else if set {
+				field.Set(fieldValue)
+				ret = true
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// resolveConfigRefs replaces the references in the string fields of v
+func resolveConfigRefs(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		err := error(nil)
+		v.SetString(gConfigRefRegex.ReplaceAllStringFunc(v.String(), func(ref string) string {
+			match := gConfigRefRegex.FindStringSubmatch(ref)
+			if err != nil {
+				return ""
+			} else if match[1] == "env" {
+				if value, ok := os.LookupEnv(match[2]); ok {
+					return value
+				}
+				err = fmt.Errorf("config: env %s is not set", match[2])
+			} else if content, e := os.ReadFile(match[2]); e == nil {
+				return strings.TrimRight(string(content), "\r\n")
+			} else {
+				err = fmt.Errorf("config: %s", e.Error())
+			}
+			return ""
+		}))
+		return err
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return resolveConfigRefs(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := resolveConfigRefs(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := resolveConfigRefs(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		// the map values are not addressable, they are resolved by copies
+		iter := v.MapRange()
+		for iter.Next() {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(iter.Value())
+			if err := resolveConfigRefs(value); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), value)
+		}
+	}
+
+	return nil
+}
+
+var gDBTLSModes = []string{"", "disable", "require", "verify-ca", "verify-full"}
+
+func (p *DBConfig) check() error {
+	if p.Connect == nil {
+		return fmt.Errorf("db config: connect is required")
+	}
+
+	// the references are checked after they are resolved
+	if p.Connect.TLS != nil && !slices.Contains(gDBTLSModes, p.Connect.TLS.Mode) &&
+		!gConfigRefRegex.MatchString(p.Connect.TLS.Mode) {
+		return fmt.Errorf("db config: invalid tls mode %q", p.Connect.TLS.Mode)
+	}
+
+	durations := map[string]string{"connect.statementTimeout": p.Connect.StatementTimeout}
+	if p.Pool != nil {
+		if p.Pool.MaxOpenConns < 0 || p.Pool.MaxIdleConns < 0 {
+			return fmt.Errorf("db config: pool sizes must not be negative")
+		}
+		durations["pool.connMaxLifetime"] = p.Pool.ConnMaxLifetime
+		durations["pool.connMaxIdleTime"] = p.Pool.ConnMaxIdleTime
+	}
+
+	for name, value := range durations {
+		if value == "" || gConfigRefRegex.MatchString(value) {
+			continue
+		} else if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			return fmt.Errorf("db config: invalid %s %q", name, value)
+		}
+	}
+
+	for i, replica := range p.Replicas {
+		if replica == nil || replica.Host == "" || replica.Port == 0 {
+			return fmt.Errorf("db config: replicas[%d]: host and port are required", i)
+		}
+	}
+
+	return nil
+}
+
+// ServerConfig is the config of the http server, the durations are like "30s"
+// and the sizes are like "4m", zero values keep the defaults of net/http
+type ServerConfig struct {
+	Listen            string            `json:"listen" required:"true"`
+	TLS               *ServerTLSConfig  `json:"tls,omitempty"`
+	CORS              *ServerCORSConfig `json:"cors,omitempty"`
+	MaxBodySize       string            `json:"maxBodySize,omitempty"`
+	ReadTimeout       string            `json:"readTimeout,omitempty"`
+	ReadHeaderTimeout string            `json:"readHeaderTimeout,omitempty"`
+	WriteTimeout      string            `json:"writeTimeout,omitempty"`
+	IdleTimeout       string            `json:"idleTimeout,omitempty"`
+	ShutdownTimeout   string            `json:"shutdownTimeout,omitempty"`
+	// AdminAddress is the listen address of the admin server, the requests
+	// must have the header Authorization: Bearer <AdminToken>
+	AdminAddress string `json:"adminAddress,omitempty"`
+	AdminToken   string `json:"adminToken,omitempty"`
+	// Tracing exports the spans of the actions and statements to a collector
+	Tracing *ServerTracingConfig `json:"tracing,omitempty"`
+	Log     *ServerLogConfig     `json:"log,omitempty"`
+	// ExposeErrors returns the messages of the internal errors to the clients
+	// instead of a generic message, it should only be set in development
+	ExposeErrors bool `json:"exposeErrors,omitempty"`
+}
+
+// ServerLogConfig is the logger of the runtime that writes to stderr, level
+// is one of debug, info, warn and error, format is text or json
+type ServerLogConfig struct {
+	Level  string `json:"level,omitempty"`
+	Format string `json:"format,omitempty"`
+}
+
+type ServerTLSConfig struct {
+	Cert string `json:"cert" required:"true"`
+	Key  string `json:"key" required:"true"`
+}
+
+// ServerTracingConfig is the OTLP/HTTP collector of the spans, endpoint is
+// like "http://localhost:4318" and headers are sent with the spans
+type ServerTracingConfig struct {
+	Endpoint    string            `json:"endpoint" required:"true"`
+	ServiceName string            `json:"serviceName,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+// ServerCORSConfig is the cross origin policy of the api. An origin is like
+// "https://example.com" or a pattern like "https://*.example.com", "*" allows
+// any origin. The builder derives the methods from the actions and adds
+// Content-Type to the headers. MaxAge is the cache duration of the preflight.
+type ServerCORSConfig struct {
+	Origins          []string `json:"origins"`
+	AllowCredentials bool     `json:"allowCredentials,omitempty"`
+	Methods          []string `json:"methods,omitempty"`
+	Headers          []string `json:"headers,omitempty"`
+	ExposeHeaders    []string `json:"exposeHeaders,omitempty"`
+	MaxAge           string   `json:"maxAge,omitempty"`
+}
+
+// gServerConfigEnvPrefix is the prefix of the environment variables that
+// override the fields of ServerConfig, e.g. CAPI_SERVER_LISTEN
+const gServerConfigEnvPrefix = "CAPI_SERVER"
+
+// gServerConfigFileEnv is the environment variable of a json file that
+// overrides the fields of the embedded ServerConfig
+const gServerConfigFileEnv = "CAPI_SERVER_CONFIG"
+
+// LoadServerConfig loads the server config of jsonStr, the file of
+// CAPI_SERVER_CONFIG and the environment variables override it in order
+func LoadServerConfig(jsonStr string) (*ServerConfig, error) {
+	var config ServerConfig
+	if err := json.Unmarshal([]byte(jsonStr), &config); err != nil {
+		return nil, err
+	}
+
+	if configPath, ok := os.LookupEnv(gServerConfigFileEnv); ok {
+		if content, err := os.ReadFile(configPath); err != nil {
+			return nil, fmt.Errorf("server config: %s", err.Error())
+		} else if err := json.Unmarshal(content, &config); err != nil {
+			return nil, fmt.Errorf("server config: %s: %s", configPath, err.Error())
+		}
+	}
+
+	if _, err := overrideConfigByEnv(reflect.ValueOf(&config).Elem(), gServerConfigEnvPrefix); err != nil {
+		return nil, err
+	} else if err := resolveConfigRefs(reflect.ValueOf(&config).Elem()); err != nil {
+		return nil, err
+	} else if err := config.check(); err != nil {
+		return nil, err
 	}
 	return &config, nil
 }
 
+func (p *ServerConfig) check() error {
+	if p.Listen == "" {
+		return fmt.Errorf("server config: listen is required")
+	}
+
+	if p.Log != nil && !slices.Contains([]string{"", "debug", "info", "warn", "error"}, p.Log.Level) {
+		return fmt.Errorf("server config: invalid log level %q", p.Log.Level)
+	} else if p.Log != nil && !slices.Contains([]string{"", "text", "json"}, p.Log.Format) {
+		return fmt.Errorf("server config: invalid log format %q", p.Log.Format)
+	}
+
+	if p.Tracing != nil && p.Tracing.Endpoint == "" {
+		return fmt.Errorf("server config: tracing endpoint is required")
+	}
+
+	if p.AdminAddress != "" && p.AdminToken == "" {
+		return fmt.Errorf("server config: adminToken is required by adminAddress")
+	}
+
+	if p.TLS != nil && (p.TLS.Cert == "" || p.TLS.Key == "") {
+		return fmt.Errorf("server config: tls cert and key are required")
+	}
+
+	if p.CORS != nil {
+		for _, origin := range p.CORS.Origins {
+			if _, err := path.Match(origin, ""); err != nil {
+				return fmt.Errorf("server config: invalid cors origin %q", origin)
+			} else if origin == "*" && p.CORS.AllowCredentials {
+				return fmt.Errorf("server config: cors origin * can not allow credentials")
+			}
+		}
+	}
+
+	// the references are checked after they are resolved
+	durations := map[string]string{
+		"readTimeout":       p.ReadTimeout,
+		"readHeaderTimeout": p.ReadHeaderTimeout,
+		"writeTimeout":      p.WriteTimeout,
+		"idleTimeout":       p.IdleTimeout,
+		"shutdownTimeout":   p.ShutdownTimeout,
+	}
+	if p.CORS != nil {
+		durations["cors.maxAge"] = p.CORS.MaxAge
+	}
+
+	for name, value := range durations {
+		if value == "" || gConfigRefRegex.MatchString(value) {
+			continue
+		} else if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			return fmt.Errorf("server config: invalid %s %q", name, value)
+		}
+	}
+
+	if p.MaxBodySize != "" && !gConfigRefRegex.MatchString(p.MaxBodySize) {
+		if _, err := ParseConfigSize(p.MaxBodySize); err != nil {
+			return fmt.Errorf("server config: invalid maxBodySize %q", p.MaxBodySize)
+		}
+	}
+
+	return nil
+}
+
+// configDuration is the duration of a checked config value, zero if it is empty
+func configDuration(value string) time.Duration {
+	d, _ := time.ParseDuration(value)
+	return d
+}
+
+// ParseConfigSize parses a size like "512", "64k", "4m" or "1g" to bytes
+func ParseConfigSize(value string) (int64, error) {
+	units := map[byte]int64{'k': 1 << 10, 'm': 1 << 20, 'g': 1 << 30}
+	number, unit := strings.ToLower(strings.TrimSpace(value)), int64(1)
+
+	if number != "" {
+		if v, ok := units[number[len(number)-1]]; ok {
+			number, unit = number[:len(number)-1], v
+		}
+	}
+
+	if v, err := strconv.ParseInt(number, 10, 64); err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	} else {
+		return v * unit, nil
+	}
+}
+
 type DBTableColumn struct {
-	Type      string          `json:"type"`
-	QueryMap  map[string]bool `json:"queryMap"`
-	Unique    bool            `json:"unique"`
-	Index     bool            `json:"index"`
-	Order     bool            `json:"order"`
-	Required  bool            `json:"required"`
-	LinkTable string          `json:"linkTable"`
+	Type         string          `json:"type"`
+	QueryMap     map[string]bool `json:"queryMap"`
+	Unique       bool            `json:"unique"`
+	Index        bool            `json:"index"`
+	Order        bool            `json:"order"`
+	Required     bool            `json:"required"`
+	LinkTable    string          `json:"linkTable"`
+	Enum         []string        `json:"enum,omitempty"`
+	OnDelete     string          `json:"onDelete,omitempty"`
+	ForeignKey   bool            `json:"foreignKey,omitempty"`
+	AggregateMap map[string]bool `json:"aggregateMap,omitempty"`
+	Search       bool            `json:"search,omitempty"`
+}
+
+type DBTableIndex struct {
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+	Where   string   `json:"where,omitempty"`
 }
 
 type DBTableViewColumn struct {
@@ -55,12 +435,17 @@ type DBTableView struct {
 }
 
 type DBTable struct {
-	Version   string                    `json:"version"`
-	Namespace string                    `json:"namespace"`
-	Table     string                    `json:"table"`
-	Columns   map[string]*DBTableColumn `json:"columns"`
-	Views     map[string]*DBTableView   `json:"views"`
-	File      string                    `json:"file"`
+	Version    string                    `json:"version"`
+	Namespace  string                    `json:"namespace"`
+	Table      string                    `json:"table"`
+	Timestamps bool                      `json:"timestamps"`
+	Audit      bool                      `json:"audit"`
+	SoftDelete bool                      `json:"softDelete"`
+	Versioned  bool                      `json:"versioned"`
+	Columns    map[string]*DBTableColumn `json:"columns"`
+	Indexes    map[string]*DBTableIndex  `json:"indexes,omitempty"`
+	Views      map[string]*DBTableView   `json:"views"`
+	File       string                    `json:"file"`
 }
 
 func LoadDBTable(jsonStr string) (*DBTable, error) {
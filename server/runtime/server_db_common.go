@@ -2,12 +2,16 @@
 package runtime
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -43,6 +47,42 @@ const (
 	SqlIn           SqlQueryOperator = "in"
 	SqlNotIn        SqlQueryOperator = "not in"
 	SqlChild        SqlQueryOperator = "child"
+	SqlNot          SqlQueryOperator = "not"
+	SqlIsNull       SqlQueryOperator = "is null"
+	SqlIsNotNull    SqlQueryOperator = "is not null"
+	SqlBetween      SqlQueryOperator = "between"
+	SqlSearch       SqlQueryOperator = "search"
+	SqlWithinRadius SqlQueryOperator = "within-radius"
+	SqlWithinBox    SqlQueryOperator = "within-box"
+)
+
+// GeoRadius is the value of the within-radius operator, the radius is in meters
+type GeoRadius struct {
+	Center GeoPoint `json:"center"`
+	Radius float64  `json:"radius"`
+}
+
+// GeoBox is the value of the within-box operator, Min is the south-west
+// corner and Max is the north-east corner
+type GeoBox struct {
+	Min GeoPoint `json:"min"`
+	Max GeoPoint `json:"max"`
+}
+
+// gSqlEarthRadius is the mean earth radius in meters
+const gSqlEarthRadius = 6371008.8
+
+// gSqlSearchConfig is the text search configuration of the search columns
+const gSqlSearchConfig = "simple"
+
+type SqlAggregateFunc string
+
+const (
+	SqlCount SqlAggregateFunc = "count"
+	SqlSum   SqlAggregateFunc = "sum"
+	SqlAvg   SqlAggregateFunc = "avg"
+	SqlMin   SqlAggregateFunc = "min"
+	SqlMax   SqlAggregateFunc = "max"
 )
 
 const (
@@ -66,6 +106,22 @@ func stringToIsolationLevel(s string) sql.IsolationLevel {
 
 const gSqlMetaTableName = "_meta_"
 
+// gSqlMaxLinkDepth limits the nesting of linked views
+const gSqlMaxLinkDepth = 8
+
+// columns maintained by the runtime, see the table options timestamps, audit, softDelete and versioned
+const (
+	SqlColumnCreatedAt = "created_at"
+	SqlColumnUpdatedAt = "updated_at"
+	SqlColumnCreatedBy = "created_by"
+	SqlColumnUpdatedBy = "updated_by"
+	SqlColumnDeletedAt = "deleted_at"
+	SqlColumnVersion   = "version"
+)
+
+// gSqlNotDeleted is the deleted_at value of the records that are not deleted
+var gSqlNotDeleted = time.Unix(0, 0).UTC()
+
 var gSqlIsolationLevels = []string{"", SqlLevelReadCommitted, SqlLevelRepeatableRead, SqlLevelSerializable}
 var gSqlServiceNameRegex, _ = regexp.Compile("^[_a-z][_a-z0-9]*$")
 var gSqlColumnNameRegex, _ = regexp.Compile("^[_a-z][_a-z0-9]*$")
@@ -93,12 +149,14 @@ var gSqlColumnKindAllowedQueryOperatorsMap = map[string]map[string]bool{
 		string(SqlLessEqual):    true,
 		string(SqlIn):           true,
 		string(SqlNotIn):        true,
+		string(SqlBetween):      true,
 	},
 	"Float64": {
 		string(SqlGreaterThan):  true,
 		string(SqlLessThan):     true,
 		string(SqlGreaterEqual): true,
 		string(SqlLessEqual):    true,
+		string(SqlBetween):      true,
 	},
 	"String16": {
 		string(SqlEqual):    true,
@@ -106,6 +164,7 @@ var gSqlColumnKindAllowedQueryOperatorsMap = map[string]map[string]bool{
 		string(SqlLike):     true,
 		string(SqlIn):       true,
 		string(SqlNotIn):    true,
+		string(SqlSearch):   true,
 	},
 	"String32": {
 		string(SqlEqual):    true,
@@ -113,6 +172,7 @@ var gSqlColumnKindAllowedQueryOperatorsMap = map[string]map[string]bool{
 		string(SqlLike):     true,
 		string(SqlIn):       true,
 		string(SqlNotIn):    true,
+		string(SqlSearch):   true,
 	},
 	"String64": {
 		string(SqlEqual):    true,
@@ -120,6 +180,7 @@ var gSqlColumnKindAllowedQueryOperatorsMap = map[string]map[string]bool{
 		string(SqlLike):     true,
 		string(SqlIn):       true,
 		string(SqlNotIn):    true,
+		string(SqlSearch):   true,
 	},
 	"String256": {
 		string(SqlEqual):    true,
@@ -127,6 +188,7 @@ var gSqlColumnKindAllowedQueryOperatorsMap = map[string]map[string]bool{
 		string(SqlLike):     true,
 		string(SqlIn):       true,
 		string(SqlNotIn):    true,
+		string(SqlSearch):   true,
 	},
 	"String": {
 		string(SqlEqual):    true,
@@ -134,28 +196,82 @@ var gSqlColumnKindAllowedQueryOperatorsMap = map[string]map[string]bool{
 		string(SqlLike):     true,
 		string(SqlIn):       true,
 		string(SqlNotIn):    true,
+		string(SqlSearch):   true,
+	},
+	"Time": {
+		string(SqlEqual):        true,
+		string(SqlNotEqual):     true,
+		string(SqlGreaterThan):  true,
+		string(SqlLessThan):     true,
+		string(SqlGreaterEqual): true,
+		string(SqlLessEqual):    true,
+		string(SqlBetween):      true,
+	},
+	"Date": {
+		string(SqlEqual):        true,
+		string(SqlNotEqual):     true,
+		string(SqlGreaterThan):  true,
+		string(SqlLessThan):     true,
+		string(SqlGreaterEqual): true,
+		string(SqlLessEqual):    true,
+		string(SqlIn):           true,
+		string(SqlNotIn):        true,
+		string(SqlBetween):      true,
+	},
+	"Decimal": {
+		string(SqlEqual):        true,
+		string(SqlNotEqual):     true,
+		string(SqlGreaterThan):  true,
+		string(SqlLessThan):     true,
+		string(SqlGreaterEqual): true,
+		string(SqlLessEqual):    true,
+		string(SqlBetween):      true,
+	},
+	"UUID": {
+		string(SqlEqual):    true,
+		string(SqlNotEqual): true,
+		string(SqlIn):       true,
+		string(SqlNotIn):    true,
+	},
+	"Bytes": {},
+	"GeoPoint": {
+		string(SqlWithinRadius): true,
+		string(SqlWithinBox):    true,
+	},
+	"Enum": {
+		string(SqlEqual):    true,
+		string(SqlNotEqual): true,
+		string(SqlIn):       true,
+		string(SqlNotIn):    true,
 	},
 	"List<String>": {},
 	"Map<String>":  {},
 	"LK": {
-		string(SqlEqual):    true,
-		string(SqlNotEqual): true,
+		string(SqlEqual):     true,
+		string(SqlNotEqual):  true,
+		string(SqlIsNull):    true,
+		string(SqlIsNotNull): true,
 	},
 	"LKList": {},
 	"LKMap":  {},
 }
 
+// ISqlAgent builds the statements of a database. The values are passed as
+// args of the statements, the names that a statement can not take as args
+// are quoted by QuoteIdentifier and QuoteLiteral.
 type ISqlAgent interface {
-	DataSource(host string, port uint16, user string, password string, dbName string) string
+	DataSource(connect *DBConnectConfig, host string, port uint16, dbName string) string
+	QuoteIdentifier(name string) string
+	QuoteLiteral(value string) string
 
-	HasDatabase(dbName string) string
+	HasDatabase() string
 	CreateDatabase(dbName string) string
 	DropDatabase(dbName string) string
 
 	CreateMetaTable() string
 	QueryMetaTable() string
-	InsertMetaTable(serviceName string, meta string) string
-	UpdateMetaTable(serviceName string, meta string) string
+	InsertMetaTable() string
+	UpdateMetaTable() string
 
 	CreateServiceTable(serviceName string) string
 	AddColumn(serviceName string, columnName string, columnType string) string
@@ -164,12 +280,29 @@ type ISqlAgent interface {
 	DropIndex(serviceName string, columnName string) string
 	CreateUnique(serviceName string, columnName string) string
 	DropUnique(serviceName string, columnName string) string
-
+	CreateCompositeIndex(serviceName string, indexName string, index *DBTableIndex) string
+	DropCompositeIndex(serviceName string, indexName string) string
+	CreateEnumCheck(serviceName string, columnName string, values []string) string
+	DropEnumCheck(serviceName string, columnName string) string
+	CreateSearch(serviceName string, columnName string) string
+	DropSearch(serviceName string, columnName string) string
+
+	MaxArgs() int
 	Insert(serviceName string, keys []string) string
-	Update(serviceName string, keys []string) string
+	InsertMany(serviceName string, keys []string, rows int) string
+	Upsert(serviceName string, keys []string, rows int, columnName string, conditions []string, versioned bool) string
+	Update(serviceName string, keys []string, conditions []string) string
+	UpdateMany(serviceName string, keys []string, where string, versioned bool) string
 	Delete(serviceName string) string
-
-	QueryOrderBy(serviceName string, query *SqlQuery) string
+	Exists(serviceName string, conditions []string) string
+	References(serviceName string, columnName string, columnType string, conditions []string) string
+	CreateForeignKey(serviceName string, columnName string, linkTable string, onDelete string) string
+	DropForeignKey(serviceName string, columnName string) string
+
+	QueryOrderBy(serviceName string, argStartPos int, query *SqlQuery) (string, []any)
+	QueryAfter(serviceName string, argStartPos int, orders []*SqlOrderBy) string
+	QueryAggregate(serviceName string, query *SqlQuery) string
+	QueryGroupBy(serviceName string, query *SqlQuery) string
 	QueryWhere(serviceName string, argStartPos int, query *SqlQuery) (string, []any, error)
 	QuerySelect(serviceName string, columns []string) string
 }
@@ -197,18 +330,47 @@ func (p *SqlWhere) GetConcat() string {
 	return p.concat
 }
 
+// SqlOrderBy is an order by column, or by the relevance of the search
+// of the column if search is not empty
 type SqlOrderBy struct {
-	name string
-	asc  bool
+	name   string
+	asc    bool
+	search string
+}
+
+// SqlAggregate is an aggregate of a column, the column of count is empty
+type SqlAggregate struct {
+	fn     SqlAggregateFunc
+	column string
+}
+
+func (p *SqlAggregate) GetFunc() SqlAggregateFunc {
+	return p.fn
+}
+
+func (p *SqlAggregate) GetColumnName() string {
+	return p.column
+}
+
+// GetName returns the key of the aggregate in the records, e.g. "sum(level)"
+func (p *SqlAggregate) GetName() string {
+	if p.column == "" {
+		return string(p.fn)
+	}
+	return string(p.fn) + "(" + p.column + ")"
 }
 
 type SqlQuery struct {
-	service string
-	view    string
-	wheres  []*SqlWhere
-	orders  []*SqlOrderBy
-	limit   int
-	offset  int
+	service        string
+	view           string
+	wheres         []*SqlWhere
+	orders         []*SqlOrderBy
+	limit          int
+	offset         int
+	cursor         string
+	includeDeleted bool
+	aggregates     []*SqlAggregate
+	groupBy        []string
 }
 
 func NewQuery(service string) *SqlQuery {
@@ -244,6 +406,22 @@ func (p *SqlQuery) GetOffset() int {
 	return p.offset
 }
 
+func (p *SqlQuery) GetCursor() string {
+	return p.cursor
+}
+
+func (p *SqlQuery) GetAggregates() []*SqlAggregate {
+	return p.aggregates
+}
+
+func (p *SqlQuery) GetGroupBy() []string {
+	return p.groupBy
+}
+
+func (p *SqlQuery) GetIncludeDeleted() bool {
+	return p.includeDeleted
+}
+
 func (p *SqlQuery) View(view string) *SqlQuery {
 	p.view = view
 	return p
@@ -269,13 +447,32 @@ func (p *SqlQuery) OrChild(child *SqlQuery) *SqlQuery {
 	return p
 }
 
+// AndNot adds the negation of the wheres of child
+func (p *SqlQuery) AndNot(child *SqlQuery) *SqlQuery {
+	p.wheres = append(p.wheres, &SqlWhere{"", SqlNot, child, "and"})
+	return p
+}
+
+// OrNot adds the negation of the wheres of child
+func (p *SqlQuery) OrNot(child *SqlQuery) *SqlQuery {
+	p.wheres = append(p.wheres, &SqlWhere{"", SqlNot, child, "or"})
+	return p
+}
+
 func (p *SqlQuery) OrderByAsc(name string) *SqlQuery {
-	p.orders = append(p.orders, &SqlOrderBy{name, true})
+	p.orders = append(p.orders, &SqlOrderBy{name, true, ""})
 	return p
 }
 
 func (p *SqlQuery) OrderByDesc(name string) *SqlQuery {
-	p.orders = append(p.orders, &SqlOrderBy{name, false})
+	p.orders = append(p.orders, &SqlOrderBy{name, false, ""})
+	return p
+}
+
+// OrderBySearch orders by the relevance of the search of text in the
+// column, the most relevant first
+func (p *SqlQuery) OrderBySearch(name string, text string) *SqlQuery {
+	p.orders = append(p.orders, &SqlOrderBy{name, false, text})
 	return p
 }
 
@@ -289,17 +486,70 @@ func (p *SqlQuery) Offset(offset int) *SqlQuery {
 	return p
 }
 
+// Count adds the count of the records to an aggregate query
+func (p *SqlQuery) Count() *SqlQuery {
+	p.aggregates = append(p.aggregates, &SqlAggregate{SqlCount, ""})
+	return p
+}
+
+// Sum adds the sum of the column to an aggregate query
+func (p *SqlQuery) Sum(column string) *SqlQuery {
+	p.aggregates = append(p.aggregates, &SqlAggregate{SqlSum, column})
+	return p
+}
+
+// Avg adds the average of the column to an aggregate query
+func (p *SqlQuery) Avg(column string) *SqlQuery {
+	p.aggregates = append(p.aggregates, &SqlAggregate{SqlAvg, column})
+	return p
+}
+
+// Min adds the minimum of the column to an aggregate query
+func (p *SqlQuery) Min(column string) *SqlQuery {
+	p.aggregates = append(p.aggregates, &SqlAggregate{SqlMin, column})
+	return p
+}
+
+// Max adds the maximum of the column to an aggregate query
+func (p *SqlQuery) Max(column string) *SqlQuery {
+	p.aggregates = append(p.aggregates, &SqlAggregate{SqlMax, column})
+	return p
+}
+
+// GroupBy groups the aggregates by the columns
+func (p *SqlQuery) GroupBy(columns ...string) *SqlQuery {
+	p.groupBy = append(p.groupBy, columns...)
+	return p
+}
+
+// Cursor sets the cursor of a page query, it is the nextCursor of the last page
+func (p *SqlQuery) Cursor(cursor string) *SqlQuery {
+	p.cursor = cursor
+	return p
+}
+
+// IncludeDeleted makes the query match the soft deleted records too,
+// the linked views are still resolved without the deleted records.
+func (p *SqlQuery) IncludeDeleted() *SqlQuery {
+	p.includeDeleted = true
+	return p
+}
+
 func (p *SqlQuery) Check(table *DBTable, root bool) error {
 	// check orderBy
 	for _, v := range p.orders {
-		if column, ok := table.Columns[v.name]; !ok || !column.Order {
+		if column, ok := table.Columns[v.name]; !ok {
+			return fmt.Errorf("db query: %s.%s order is not allowed", table.Table, v.name)
+		} else if v.search != "" && !column.Search {
+			return fmt.Errorf("db query: %s.%s search order is not allowed", table.Table, v.name)
+		} else if v.search == "" && !column.Order {
 			return fmt.Errorf("db query: %s.%s order is not allowed", table.Table, v.name)
 		}
 	}
 
 	// check where
 	for _, v := range p.wheres {
-		if v.op == SqlChild {
+		if v.op == SqlChild || v.op == SqlNot {
 			if err := v.value.(*SqlQuery).Check(table, false); err != nil {
 				return err
 			}
@@ -310,6 +560,21 @@ func (p *SqlQuery) Check(table *DBTable, root bool) error {
 		}
 	}
 
+	// check aggregates
+	for _, v := range p.aggregates {
+		if v.fn == SqlCount && v.column == "" {
+			continue
+		} else if column, ok := table.Columns[v.column]; !ok || !column.AggregateMap[string(v.fn)] {
+			return fmt.Errorf("db query: %s.%s \"%s\" is not allowed", table.Table, v.column, v.fn)
+		}
+	}
+
+	for _, v := range p.groupBy {
+		if column, ok := table.Columns[v]; !ok || !column.AggregateMap["groupBy"] {
+			return fmt.Errorf("db query: %s.%s \"groupBy\" is not allowed", table.Table, v)
+		}
+	}
+
 	// check limit and offset
 	if p.limit < 0 || p.offset < 0 {
 		return fmt.Errorf("db query: limit and offset must be positive")
@@ -348,15 +613,233 @@ func NewWebQuery(name string, queries map[string]any, orders []string) *SqlQuery
 	return ret
 }
 
+// sqlToGeoValue converts the value of a GeoPoint column or a geo operator,
+// it is either the typed value or the decoded json of it
+func sqlToGeoValue[T any](v any) (T, error) {
+	var ret T
+	if typedV, ok := v.(T); ok {
+		return typedV, nil
+	} else if typedV, ok := v.(*T); ok && typedV != nil {
+		return *typedV, nil
+	} else if _, ok := v.(map[string]any); !ok {
+		return ret, fmt.Errorf("invalid %T type %T", ret, v)
+	} else if data, e := json.Marshal(v); e != nil {
+		return ret, e
+	} else if e := json.Unmarshal(data, &ret); e != nil {
+		return ret, fmt.Errorf("invalid %T: %s", ret, e.Error())
+	} else {
+		return ret, nil
+	}
+}
+
+func sqlToGeoPoint(v any) (GeoPoint, error) {
+	if point, e := sqlToGeoValue[GeoPoint](v); e != nil {
+		return point, e
+	} else if !point.Valid() {
+		return point, fmt.Errorf("invalid geo point %s", point.String())
+	} else {
+		return point, nil
+	}
+}
+
+func sqlToGeoRadius(v any) (GeoRadius, error) {
+	if radius, e := sqlToGeoValue[GeoRadius](v); e != nil {
+		return radius, e
+	} else if !radius.Center.Valid() {
+		return radius, fmt.Errorf("invalid geo point %s", radius.Center.String())
+	} else if radius.Radius < 0 {
+		return radius, fmt.Errorf("invalid geo radius %v", radius.Radius)
+	} else {
+		return radius, nil
+	}
+}
+
+func sqlToGeoBox(v any) (GeoBox, error) {
+	if box, e := sqlToGeoValue[GeoBox](v); e != nil {
+		return box, e
+	} else if !box.Min.Valid() || !box.Max.Valid() {
+		return box, fmt.Errorf("invalid geo box %s %s", box.Min.String(), box.Max.String())
+	} else {
+		return box, nil
+	}
+}
+
+// sqlParseGeoPoint parses the point "(x,y)" of the database, x is the longitude
+func sqlParseGeoPoint(s string) (GeoPoint, error) {
+	x, y, ok := strings.Cut(strings.Trim(strings.TrimSpace(s), "()"), ",")
+	if !ok {
+		return GeoPoint{}, fmt.Errorf("invalid point %q", s)
+	}
+
+	longitude, e := strconv.ParseFloat(strings.TrimSpace(x), 64)
+	if e != nil {
+		return GeoPoint{}, fmt.Errorf("invalid point %q", s)
+	}
+
+	latitude, e := strconv.ParseFloat(strings.TrimSpace(y), 64)
+	if e != nil {
+		return GeoPoint{}, fmt.Errorf("invalid point %q", s)
+	}
+
+	return GeoPoint{Latitude: latitude, Longitude: longitude}, nil
+}
+
+var gSqlDecimalRegex, _ = regexp.Compile(`^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?$`)
+
+func sqlToString(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	default:
+		return "", false
+	}
+}
+
+// sqlToSlice converts the slice value of an operator, e.g. in and between
+func sqlToSlice(v any) ([]any, bool) {
+	switch v := v.(type) {
+	case []any:
+		return v, true
+	case []string:
+		return sqlSliceToAny(v), true
+	case []int64:
+		return sqlSliceToAny(v), true
+	case []int:
+		return sqlSliceToAny(v), true
+	case []float64:
+		return sqlSliceToAny(v), true
+	case []time.Time:
+		return sqlSliceToAny(v), true
+	default:
+		return nil, false
+	}
+}
+
+func sqlSliceToAny[T any](v []T) []any {
+	ret := make([]any, len(v))
+	for i, it := range v {
+		ret[i] = it
+	}
+	return ret
+}
+
+func sqlToInt64(v any) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case float64:
+		return int64(v), v == float64(int64(v))
+	default:
+		return 0, false
+	}
+}
+
 func SqlEncodeToDB(kind string, v any) (any, error) {
 	switch kind {
-	case "PK", "LK", "Bool", "Int64", "Float64", "String", "String256", "Bytes":
+	case "PK", "LK", "Bool", "Int64", "Float64",
+		"String", "String16", "String32", "String64", "String256", "Enum":
 		return v, nil
-	case "StringArray", "LKArray":
+	case "Time":
+		switch v := v.(type) {
+		case time.Time:
+			return v.UTC(), nil
+		case Date:
+			return v.Time, nil
+		case string:
+			if v == "" {
+				return time.Unix(0, 0).UTC(), nil
+			} else if t, e := time.Parse(time.RFC3339Nano, v); e != nil {
+				return nil, fmt.Errorf("invalid time %q", v)
+			} else {
+				return t.UTC(), nil
+			}
+		case nil:
+			return time.Unix(0, 0).UTC(), nil
+		default:
+			return nil, fmt.Errorf("invalid time type %T", v)
+		}
+	case "Date":
+		switch v := v.(type) {
+		case Date:
+			return v.String(), nil
+		case time.Time:
+			return NewDate(v).String(), nil
+		case string:
+			if v == "" {
+				return "1970-01-01", nil
+			} else if d, e := ParseDate(v); e != nil {
+				return nil, fmt.Errorf("invalid date %q", v)
+			} else {
+				return d.String(), nil
+			}
+		case nil:
+			return "1970-01-01", nil
+		default:
+			return nil, fmt.Errorf("invalid date type %T", v)
+		}
+	case "Decimal":
+		switch v := v.(type) {
+		case int64, int, float64:
+			return fmt.Sprint(v), nil
+		case json.Number:
+			return v.String(), nil
+		case string:
+			if v == "" {
+				return "0", nil
+			} else if !gSqlDecimalRegex.MatchString(v) {
+				return nil, fmt.Errorf("invalid decimal %q", v)
+			}
+			return v, nil
+		case nil:
+			return "0", nil
+		default:
+			return nil, fmt.Errorf("invalid decimal type %T", v)
+		}
+	case "UUID":
+		if v == nil {
+			return uuid.Nil.String(), nil
+		} else if strV, ok := sqlToString(v); !ok {
+			return nil, fmt.Errorf("invalid uuid type %T", v)
+		} else if strV == "" {
+			return uuid.Nil.String(), nil
+		} else if id, e := uuid.Parse(strV); e != nil {
+			return nil, fmt.Errorf("invalid uuid %q", strV)
+		} else {
+			return id.String(), nil
+		}
+	case "Bytes":
+		switch v := v.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			// bytes are transferred as base64 in json
+			if ret, e := base64.StdEncoding.DecodeString(v); e != nil {
+				return nil, fmt.Errorf("invalid base64 bytes")
+			} else {
+				return ret, nil
+			}
+		case nil:
+			return []byte{}, nil
+		default:
+			return nil, fmt.Errorf("invalid bytes type %T", v)
+		}
+	case "GeoPoint":
+		if v == nil {
+			return GeoPoint{}.String(), nil
+		} else if point, e := sqlToGeoPoint(v); e != nil {
+			return nil, e
+		} else {
+			return point.String(), nil
+		}
+	case "List<String>", "LKList":
 		if v == nil {
 			return "[]", nil
 		}
-	case "StringMap", "LKMap":
+	case "Map<String>", "LKMap":
 		if v == nil {
 			return "{}", nil
 		}
@@ -373,13 +856,54 @@ func SqlEncodeToDB(kind string, v any) (any, error) {
 
 func SqlDecodeFromDB(kind string, v any) (any, error) {
 	switch kind {
-	case "PK", "LK", "Bool", "Int64", "Float64", "String", "String256", "Bytes":
+	case "PK", "LK", "Bool", "Int64", "Float64",
+		"String", "String16", "String32", "String64", "String256", "Enum":
+		if v == nil && kind == "LK" {
+			// the link column of a foreign key is null if it is not set
+			return "", nil
+		} else if bytesV, ok := v.([]byte); ok {
+			return string(bytesV), nil
+		}
 		return v, nil
+	case "Time":
+		if t, ok := v.(time.Time); !ok {
+			return nil, fmt.Errorf("db internal error")
+		} else {
+			return t.UTC(), nil
+		}
+	case "Date":
+		if t, ok := v.(time.Time); ok {
+			return NewDate(t), nil
+		} else if strV, ok := sqlToString(v); !ok {
+			return nil, fmt.Errorf("db internal error")
+		} else {
+			return ParseDate(strV)
+		}
+	case "Decimal", "UUID":
+		if strV, ok := sqlToString(v); !ok {
+			return nil, fmt.Errorf("db internal error")
+		} else {
+			return strV, nil
+		}
+	case "Bytes":
+		if v == nil {
+			return []byte{}, nil
+		} else if bytesV, ok := v.([]byte); !ok {
+			return nil, fmt.Errorf("db internal error")
+		} else {
+			return bytesV, nil
+		}
+	case "GeoPoint":
+		if strV, ok := sqlToString(v); !ok {
+			return nil, fmt.Errorf("db internal error")
+		} else {
+			return sqlParseGeoPoint(strV)
+		}
 	case "List<String>", "LKList":
 		ret := make([]string, 0)
 		if v == nil {
 			return make([]string, 0), nil
-		} else if strV, ok := v.(string); !ok {
+		} else if strV, ok := sqlToString(v); !ok {
 			return ret, fmt.Errorf("db internal error")
 		} else if e := json.Unmarshal([]byte(strV), &ret); e != nil {
 			return ret, e
@@ -390,7 +914,7 @@ func SqlDecodeFromDB(kind string, v any) (any, error) {
 		ret := make(map[string]any)
 		if v == nil {
 			return make(map[string]any), nil
-		} else if strV, ok := v.(string); !ok {
+		} else if strV, ok := sqlToString(v); !ok {
 			return ret, fmt.Errorf("db internal error")
 		} else if e := json.Unmarshal([]byte(strV), &ret); e != nil {
 			return ret, e
@@ -402,6 +926,106 @@ func SqlDecodeFromDB(kind string, v any) (any, error) {
 	}
 }
 
+// sqlDecodeAggregate decodes the value of an aggregate of the column type,
+// sum and avg of integers and decimals are decimals
+func sqlDecodeAggregate(fn SqlAggregateFunc, kind string, v any) (any, error) {
+	switch fn {
+	case SqlCount:
+		if count, ok := v.(int64); !ok {
+			return nil, fmt.Errorf("db internal error")
+		} else {
+			return count, nil
+		}
+	case SqlSum, SqlAvg:
+		if v == nil {
+			return nil, nil
+		} else if kind == "Float64" {
+			return SqlDecodeFromDB(kind, v)
+		} else {
+			return SqlDecodeFromDB("Decimal", v)
+		}
+	default:
+		if v == nil {
+			return nil, nil
+		}
+		return SqlDecodeFromDB(kind, v)
+	}
+}
+
+// sqlCursor is the position of a record in the order of a page query
+type sqlCursor struct {
+	Orders []string `json:"o"`
+	Values []any    `json:"v"`
+}
+
+func sqlCursorOrders(orders []*SqlOrderBy) []string {
+	ret := make([]string, len(orders))
+	for i, order := range orders {
+		if order.asc {
+			ret[i] = order.name + ":ascend"
+		} else {
+			ret[i] = order.name + ":descend"
+		}
+	}
+	return ret
+}
+
+func sqlEncodeCursor(orders []*SqlOrderBy, record Record) (string, error) {
+	cursor := sqlCursor{Orders: sqlCursorOrders(orders), Values: make([]any, len(orders))}
+	for i, order := range orders {
+		cursor.Values[i] = record[order.name]
+	}
+
+	if data, e := json.Marshal(cursor); e != nil {
+		return "", e
+	} else {
+		return base64.RawURLEncoding.EncodeToString(data), nil
+	}
+}
+
+// sqlDecodeCursor returns the values of the order columns in the cursor,
+// the orders must be the same as the orders of the query that made it.
+func sqlDecodeCursor(table *DBTable, orders []*SqlOrderBy, cursorString string) ([]any, error) {
+	data, e := base64.RawURLEncoding.DecodeString(cursorString)
+	if e != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	// numbers are kept as json.Number, so that Int64 values are exact
+	cursor := sqlCursor{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if e := decoder.Decode(&cursor); e != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	} else if !slices.Equal(cursor.Orders, sqlCursorOrders(orders)) || len(cursor.Values) != len(orders) {
+		return nil, fmt.Errorf("the cursor does not match the orders")
+	}
+
+	ret := make([]any, len(orders))
+	for i, order := range orders {
+		column := table.Columns[order.name]
+		value := cursor.Values[i]
+
+		if number, ok := value.(json.Number); ok {
+			if v, e := number.Int64(); e == nil {
+				value = v
+			} else if v, e := number.Float64(); e == nil {
+				value = v
+			}
+		}
+
+		if column == nil {
+			return nil, fmt.Errorf("invalid cursor")
+		} else if v, e := SqlEncodeToDB(column.Type, value); e != nil {
+			return nil, fmt.Errorf("invalid cursor: %s", e.Error())
+		} else {
+			ret[i] = v
+		}
+	}
+
+	return ret, nil
+}
+
 func SqlUUID() string {
 	raw := []byte(strings.Replace(uuid.NewString(), "-", "", -1))
 	buffer := make([]byte, 16)
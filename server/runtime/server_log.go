@@ -0,0 +1,58 @@
+// tag-capi-builder-start: This file is generated by capi-builder, DO NOT EDIT.
+package runtime
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+// gRequestIDMaxLength limits the length of the request ids from the clients
+const gRequestIDMaxLength = 128
+
+var gLogger atomic.Pointer[slog.Logger]
+
+// SetLogger sets the logger of the runtime, nil restores slog.Default()
+func SetLogger(logger *slog.Logger) {
+	gLogger.Store(logger)
+}
+
+// Logger returns the logger of the runtime
+func Logger() *slog.Logger {
+	if logger := gLogger.Load(); logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
+
+// newLogger is the logger of a checked log config that writes to w
+func newLogger(config *ServerLogConfig, w io.Writer) *slog.Logger {
+	level := slog.LevelInfo
+	if config.Level != "" {
+		_ = level.UnmarshalText([]byte(config.Level))
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	if config.Format == "json" {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+// newRequestID returns the request id of the client if it is valid,
+// otherwise a random one
+func newRequestID(clientID string) string {
+	if clientID != "" && len(clientID) <= gRequestIDMaxLength &&
+		strings.IndexFunc(clientID, func(r rune) bool { return r < 0x21 || r > 0x7e }) < 0 {
+		return clientID
+	}
+
+	ret := make([]byte, 16)
+	_, _ = rand.Read(ret)
+	return hex.EncodeToString(ret)
+}
+
+// tag-capi-builder-end
\ No newline at end of file
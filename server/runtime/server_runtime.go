@@ -5,20 +5,28 @@ import "embed"
 
 //go:embed all:db
 var gDBAssets embed.FS
-var gDBManager *SQLManager
 
-func GetDBManager() *SQLManager {
-	return gDBManager
-}
+//go:embed server.json
+var gServerConfigContent string
 
+// the tables are migrated by Server.Run after it listens,
+// call GetDBManager().Open() to use the db without the server
 func init() {
 	if dbManager, err := NewSQLManager(&gDBAssets); err != nil {
 		panic(DebugError(err))
-	} else if err := dbManager.Open(); err != nil {
-		panic(DebugError(err))
 	} else {
 		gDBManager = dbManager
 	}
 }
 
+// Run runs the server of the config embedded at build time, the file of
+// CAPI_SERVER_CONFIG and the CAPI_SERVER_* environment variables override it
+func Run() error {
+	if config, err := LoadServerConfig(gServerConfigContent); err != nil {
+		return err
+	} else {
+		return NewServer(config).Run()
+	}
+}
+
 // tag-capi-builder-end
\ No newline at end of file
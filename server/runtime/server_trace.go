@@ -0,0 +1,454 @@
+// tag-capi-builder-start: This file is generated by capi-builder, DO NOT EDIT.
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// the span kinds and status codes are the values of OTLP
+const (
+	SpanKindInternal = 1
+	SpanKindServer   = 2
+	SpanKindClient   = 3
+
+	SpanStatusUnset = 0
+	SpanStatusOk    = 1
+	SpanStatusError = 2
+)
+
+const (
+	gTraceBatchSize     = 512
+	gTraceQueueSize     = 4096
+	gTraceBatchInterval = time.Second
+	gTraceExportTimeout = 10 * time.Second
+)
+
+type TraceID [16]byte
+
+func (p TraceID) String() string {
+	return hex.EncodeToString(p[:])
+}
+
+type SpanID [8]byte
+
+func (p SpanID) String() string {
+	return hex.EncodeToString(p[:])
+}
+
+// Span is a timed operation of a trace, the methods of a nil span do nothing
+// so that the spans cost nothing when the tracing is disabled
+type Span struct {
+	TraceID       TraceID
+	SpanID        SpanID
+	ParentID      SpanID
+	Name          string
+	Kind          int
+	Start         time.Time
+	End           time.Time
+	Attributes    map[string]any
+	StatusCode    int
+	StatusMessage string
+	sampled       bool
+	tracer        *tracer
+	mutex         sync.Mutex
+}
+
+func newSpanID() SpanID {
+	ret := SpanID{}
+	_, _ = rand.Read(ret[:])
+	return ret
+}
+
+// parseTraceParent parses a W3C traceparent header like
+// 00-<32 hex trace id>-<16 hex parent id>-<2 hex flags>
+func parseTraceParent(traceParent string) (TraceID, SpanID, bool, bool) {
+	traceID, parentID := TraceID{}, SpanID{}
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		(parts[0] == "00" && len(parts) != 4) ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return traceID, parentID, false, false
+	} else if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil || traceID == (TraceID{}) {
+		return traceID, parentID, false, false
+	} else if _, err := hex.Decode(parentID[:], []byte(parts[2])); err != nil || parentID == (SpanID{}) {
+		return traceID, parentID, false, false
+	} else if flags, err := strconv.ParseUint(parts[3], 16, 8); err != nil {
+		return traceID, parentID, false, false
+	} else {
+		return traceID, parentID, flags&1 == 1, true
+	}
+}
+
+// StartSpan starts a root span, it continues the trace of traceParent if it
+// is a valid W3C traceparent. It returns nil if the tracing is disabled.
+func StartSpan(name string, kind int, traceParent string) *Span {
+	t := gTracer.Load()
+	if t == nil {
+		return nil
+	}
+
+	ret := &Span{
+		SpanID:     newSpanID(),
+		Name:       name,
+		Kind:       kind,
+		Start:      time.Now(),
+		Attributes: map[string]any{},
+		sampled:    true,
+		tracer:     t,
+	}
+
+	if traceID, parentID, sampled, ok := parseTraceParent(traceParent); ok {
+		ret.TraceID, ret.ParentID, ret.sampled = traceID, parentID, sampled
+	} else {
+		_, _ = rand.Read(ret.TraceID[:])
+	}
+
+	return ret
+}
+
+// StartChild starts a span of the trace whose parent is p
+func (p *Span) StartChild(name string, kind int) *Span {
+	if p == nil {
+		return nil
+	}
+
+	return &Span{
+		TraceID:    p.TraceID,
+		SpanID:     newSpanID(),
+		ParentID:   p.SpanID,
+		Name:       name,
+		Kind:       kind,
+		Start:      time.Now(),
+		Attributes: map[string]any{},
+		sampled:    p.sampled,
+		tracer:     p.tracer,
+	}
+}
+
+// TraceParent is the W3C traceparent of the span to propagate the trace
+func (p *Span) TraceParent() string {
+	if p == nil {
+		return ""
+	} else if p.sampled {
+		return fmt.Sprintf("00-%s-%s-01", p.TraceID, p.SpanID)
+	} else {
+		return fmt.Sprintf("00-%s-%s-00", p.TraceID, p.SpanID)
+	}
+}
+
+// SetAttribute sets an attribute, the value is a string, bool, int, int64
+// or float64
+func (p *Span) SetAttribute(key string, value any) {
+	if p != nil {
+		p.mutex.Lock()
+		p.Attributes[key] = value
+		p.mutex.Unlock()
+	}
+}
+
+// SetError sets the status to error if err is not nil
+func (p *Span) SetError(err error) {
+	if p != nil && err != nil {
+		p.mutex.Lock()
+		p.StatusCode, p.StatusMessage = SpanStatusError, err.Error()
+		p.mutex.Unlock()
+	}
+}
+
+// Finish ends the span and exports it if the trace is sampled
+func (p *Span) Finish() {
+	if p == nil {
+		return
+	}
+
+	p.mutex.Lock()
+	p.End = time.Now()
+	p.mutex.Unlock()
+
+	if p.sampled {
+		p.tracer.add(p)
+	}
+}
+
+// SpanExporter exports the finished spans in batches, see NewOTLPExporter
+type SpanExporter interface {
+	ExportSpans(ctx context.Context, spans []*Span) error
+	Shutdown(ctx context.Context) error
+}
+
+var gTracer atomic.Pointer[tracer]
+
+// tracer batches the finished spans to the exporter, the spans are dropped
+// if the queue is full
+type tracer struct {
+	exporter SpanExporter
+	queue    chan *Span
+	flush    chan chan struct{}
+	stop     chan struct{}
+	done     chan error
+}
+
+// SetSpanExporter starts the tracing with the exporter, nil disables it. The
+// previous exporter is flushed and shut down, it returns the error of it.
+func SetSpanExporter(exporter SpanExporter) error {
+	next := (*tracer)(nil)
+	if exporter != nil {
+		next = &tracer{
+			exporter: exporter,
+			queue:    make(chan *Span, gTraceQueueSize),
+			flush:    make(chan chan struct{}),
+			stop:     make(chan struct{}),
+			done:     make(chan error, 1),
+		}
+		go next.run()
+	}
+
+	if prev := gTracer.Swap(next); prev != nil {
+		close(prev.stop)
+		return <-prev.done
+	}
+
+	return nil
+}
+
+// FlushSpans exports the finished spans that are waiting for the batch
+func FlushSpans() {
+	if t := gTracer.Load(); t != nil {
+		done := make(chan struct{})
+		select {
+		case t.flush <- done:
+			<-done
+		case <-t.stop:
+		}
+	}
+}
+
+func (p *tracer) add(span *Span) {
+	select {
+	case p.queue <- span:
+	default:
+	}
+}
+
+func (p *tracer) run() {
+	batch := make([]*Span, 0, gTraceBatchSize)
+	ticker := time.NewTicker(gTraceBatchInterval)
+	defer ticker.Stop()
+
+	fnExport := func(drain bool) {
+		for drain {
+			select {
+			case span := <-p.queue:
+				batch = append(batch, span)
+			default:
+				drain = false
+			}
+		}
+
+		for len(batch) > 0 {
+			n := min(len(batch), gTraceBatchSize)
+			ctx, cancel := context.WithTimeout(context.Background(), gTraceExportTimeout)
+			_ = p.exporter.ExportSpans(ctx, batch[:n])
+			cancel()
+			batch = batch[n:]
+		}
+		batch = make([]*Span, 0, gTraceBatchSize)
+	}
+
+	for {
+		select {
+		case span := <-p.queue:
+			if batch = append(batch, span); len(batch) >= gTraceBatchSize {
+				fnExport(false)
+			}
+		case <-ticker.C:
+			fnExport(false)
+		case done := <-p.flush:
+			fnExport(true)
+			close(done)
+		case <-p.stop:
+			fnExport(true)
+			ctx, cancel := context.WithTimeout(context.Background(), gTraceExportTimeout)
+			p.done <- p.exporter.Shutdown(ctx)
+			cancel()
+			return
+		}
+	}
+}
+
+// OTLPExporter exports the spans to an OpenTelemetry collector by OTLP/HTTP
+// with the json encoding
+type OTLPExporter struct {
+	url         string
+	serviceName string
+	headers     map[string]string
+	client      *http.Client
+}
+
+// NewOTLPExporter is the exporter of the collector at endpoint like
+// "http://localhost:4318", the spans are posted to endpoint/v1/traces
+func NewOTLPExporter(endpoint string, serviceName string, headers map[string]string) *OTLPExporter {
+	return &OTLPExporter{
+		url:         strings.TrimRight(endpoint, "/") + "/v1/traces",
+		serviceName: serviceName,
+		headers:     headers,
+		client:      &http.Client{},
+	}
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []*otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []*otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTraces struct {
+	ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+}
+
+func toOTLPAttribute(key string, value any) otlpAttribute {
+	ret := otlpAttribute{Key: key}
+
+	switch v := value.(type) {
+	case bool:
+		ret.Value.BoolValue = &v
+	case int:
+		s := strconv.Itoa(v)
+		ret.Value.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		ret.Value.IntValue = &s
+	case float64:
+		ret.Value.DoubleValue = &v
+	case string:
+		ret.Value.StringValue = &v
+	default:
+		s := fmt.Sprint(v)
+		ret.Value.StringValue = &s
+	}
+
+	return ret
+}
+
+func toOTLPSpan(span *Span) *otlpSpan {
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+
+	ret := &otlpSpan{
+		TraceID:           span.TraceID.String(),
+		SpanID:            span.SpanID.String(),
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+	}
+
+	if span.ParentID != (SpanID{}) {
+		ret.ParentSpanID = span.ParentID.String()
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(span.Attributes)) {
+		ret.Attributes = append(ret.Attributes, toOTLPAttribute(key, span.Attributes[key]))
+	}
+
+	ret.Status.Code, ret.Status.Message = span.StatusCode, span.StatusMessage
+	return ret
+}
+
+func (p *OTLPExporter) ExportSpans(ctx context.Context, spans []*Span) error {
+	scopeSpans := &otlpScopeSpans{}
+	scopeSpans.Scope.Name = "capi"
+	for _, span := range spans {
+		scopeSpans.Spans = append(scopeSpans.Spans, toOTLPSpan(span))
+	}
+
+	resourceSpans := &otlpResourceSpans{ScopeSpans: []*otlpScopeSpans{scopeSpans}}
+	resourceSpans.Resource.Attributes = []otlpAttribute{toOTLPAttribute("service.name", p.serviceName)}
+
+	content, err := json.Marshal(&otlpTraces{ResourceSpans: []*otlpResourceSpans{resourceSpans}})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range p.headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, response.Body)
+		_ = response.Body.Close()
+	}()
+
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp: export spans: %s", response.Status)
+	}
+
+	return nil
+}
+
+func (p *OTLPExporter) Shutdown(ctx context.Context) error {
+	p.client.CloseIdleConnections()
+	return nil
+}
+
+// tag-capi-builder-end
\ No newline at end of file
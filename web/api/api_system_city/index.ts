@@ -1,11 +1,32 @@
 // tag-capi-builder-start: This file is generated by capi-builder, DO NOT EDIT.
 import * as db_city from "../db_city"
-import { fetchJson } from "../client_utils";
+import { ApiError, fetchJson, paginate, registerApiError } from "../client_utils";
 // definition: API.System.City@CityList
 export interface CityList {
   from: number;
+  total: number;
   list: db_city.Full[];
 }
+
+// error: API.System.City@CityChanged
+export class CityChangedError extends ApiError {
+	declare readonly code: 10002;
+	constructor(message: string, requestId?: string) {
+		super(10002, message, requestId);
+		this.name = "CityChangedError";
+	}
+}
+registerApiError(10002, CityChangedError);
+
+// error: API.System.City@CityNotFound
+export class CityNotFoundError extends ApiError {
+	declare readonly code: 10001;
+	constructor(message: string, requestId?: string) {
+		super(10001, message, requestId);
+		this.name = "CityNotFoundError";
+	}
+}
+registerApiError(10001, CityNotFoundError);
 export class __Main__ {
 	private url: string;
 	constructor(url: string) {
@@ -22,15 +43,25 @@ export class __Main__ {
 		return fetchJson(this.url, "API.System.City:Delete", "POST", { v })
 	}
 
-	// action: API.System.City:Update
-	async Update(v: db_city.Update): Promise<db_city.Update> {
-		return fetchJson(this.url, "API.System.City:Update", "POST", { v })
+	// action: API.System.City:Page
+	async Page(v: db_city.Query): Promise<db_city.FullPage> {
+		return fetchJson(this.url, "API.System.City:Page", "GET", { v })
+	}
+
+	// iterator: API.System.City:Page
+	async *PageIterator(v: db_city.Query): AsyncGenerator<db_city.Full> {
+		yield* paginate((cursor) => this.Page({ ...v, cursor }));
 	}
 
 	// action: API.System.City:Query
 	async Query(v: db_city.Query): Promise<CityList> {
 		return fetchJson(this.url, "API.System.City:Query", "GET", { v })
 	}
+
+	// action: API.System.City:Update
+	async Update(v: db_city.Update): Promise<db_city.Update> {
+		return fetchJson(this.url, "API.System.City:Update", "POST", { v })
+	}
 }
 
 // tag-capi-builder-end
\ No newline at end of file
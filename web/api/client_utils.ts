@@ -1,5 +1,29 @@
 // tag-capi-builder-start: This file is generated by capi-builder, DO NOT EDIT.
 /* eslint-disable @typescript-eslint/no-explicit-any */
+
+// ApiError is the error of a failed action, the declared errors of the metas
+// are its subclasses with typed codes, e.g. `err instanceof CityNotFoundError`
+export class ApiError extends Error {
+  readonly code: number;
+  readonly requestId?: string;
+
+  constructor(code: number, message: string, requestId?: string) {
+    super(message);
+    this.name = "ApiError";
+    this.code = code;
+    this.requestId = requestId;
+  }
+}
+
+type ApiErrorClass = new (message: string, requestId?: string) => ApiError;
+
+const apiErrorClasses = new Map<number, ApiErrorClass>();
+
+// registerApiError makes fetchJson throw errorClass for the code
+export function registerApiError(code: number, errorClass: ApiErrorClass) {
+  apiErrorClasses.set(code, errorClass);
+}
+
 export async function fetchJson(
   url: string,
   action: string,
@@ -23,7 +47,12 @@ export async function fetchJson(
 
   const resp = await fetch(u.toString(), init);
 
-  type Return = { code: number; message: string; data: any };
+  type Return = {
+    code: number;
+    message: string;
+    data: any;
+    requestId?: string;
+  };
   let parsed: Return;
   try {
     parsed = (await resp.json()) as Return;
@@ -40,7 +69,31 @@ export async function fetchJson(
     return parsed.data;
   }
 
-  throw new Error(message || `Request failed with code ${code}`);
+  const errorClass = apiErrorClasses.get(code);
+  if (errorClass) {
+    throw new errorClass(message, parsed?.requestId);
+  }
+
+  // the internal errors are found by the request id in the server log
+  const requestId = parsed?.requestId ? ` (request id: ${parsed.requestId})` : "";
+  throw new ApiError(
+    code,
+    (message || `Request failed with code ${code}`) + requestId,
+    parsed?.requestId
+  );
+}
+
+// paginate yields the items of all the pages, fetchPage is called with the
+// nextCursor of the last page until it is empty
+export async function* paginate<T>(
+  fetchPage: (cursor?: string) => Promise<{ items: T[]; nextCursor?: string }>
+): AsyncGenerator<T> {
+  let cursor: string | undefined = undefined;
+  do {
+    const page: { items: T[]; nextCursor?: string } = await fetchPage(cursor);
+    yield* page.items;
+    cursor = page.nextCursor;
+  } while (cursor);
 }
 
 // tag-capi-builder-end
\ No newline at end of file
@@ -1,5 +1,37 @@
 // tag-capi-builder-start: This file is generated by capi-builder, DO NOT EDIT.
 import * as db_geo from "../db_geo"
+// enum: DB.City@Status
+export type Status = "active" | "inactive" | "archived";
+
+// definition: DB.City@Create
+export interface Create {
+  active: boolean;
+  age: number;
+  area: number;
+  budget: string;
+  code: string;
+  emblem: string;
+  founded: string;
+  geo: string;
+  geo_list: string[];
+  geo_map: { [key: string]: string };
+  id: string;
+  name: string;
+  name_16: string;
+  name_256: string;
+  name_32: string;
+  name_64: string;
+  status: Status;
+  str_list: string[];
+  str_map: { [key: string]: string };
+  surveyed_at: string;
+}
+
+// definition: DB.City@Delete
+export interface Delete {
+  id: string;
+}
+
 // definition: DB.City@Full
 export interface Full {
   id: string;
@@ -12,39 +44,74 @@ export interface Full {
   area: number;
   str_list: string[];
   str_map: { [key: string]: string };
-  geo_list: db_geo.Full;
-  geo_map: db_geo.Full;
+  geo_list: db_geo.Full[];
+  geo_map: { [key: string]: db_geo.Full };
   geo: db_geo.Full;
   active: boolean;
+  status: Status;
+  founded: string;
+  surveyed_at: string;
+  budget: string;
+  code: string;
+  created_at: string;
+  updated_at: string;
+  updated_by: string;
+  version: number;
+}
+
+// definition: DB.City@FullPage
+export interface FullPage {
+  items: Full[];
+  nextCursor: string;
+}
+
+// definition: DB.City@Query
+export interface Query {
+  where?: { [key: string]: unknown };
+  orders?: string[];
+  limit?: number;
+  offset?: number;
+  cursor?: string;
+  search?: string;
+  includeDeleted?: boolean;
 }
 
 // definition: DB.City@Simple
 export interface Simple {
   id: string;
   name: string;
+  version: number;
 }
 
-// definition: DB.City@Create
-export interface Create {
-  active: boolean;
-  age: number;
-  area: number;
-  geo: db_geo.Create;
-  geo_list: db_geo.Create;
-  geo_map: db_geo.Create;
-  id: string;
-  name: string;
-  name_16: string;
-  name_256: string;
-  name_32: string;
-  name_64: string;
-  str_list: string[];
-  str_map: { [key: string]: string };
+// definition: DB.City@SimplePage
+export interface SimplePage {
+  items: Simple[];
+  nextCursor: string;
 }
 
-// definition: DB.City@Delete
-export interface Delete {
+// definition: DB.City@Update
+export interface Update {
+  active?: boolean;
+  age?: number;
+  area?: number;
+  budget?: string;
+  code?: string;
+  emblem?: string;
+  founded?: string;
+  geo?: string;
+  geo_list?: string[];
+  geo_map?: { [key: string]: string };
   id: string;
+  name?: string;
+  name_16?: string;
+  name_256?: string;
+  name_32?: string;
+  name_64?: string;
+  status?: Status;
+  str_list?: string[];
+  str_map?: { [key: string]: string };
+  surveyed_at?: string;
+  version: number;
 }
 
 // tag-capi-builder-end
\ No newline at end of file
@@ -3,6 +3,7 @@
 export interface Create {
   id: string;
   latitude: number;
+  location: { latitude: number; longitude: number };
   longitude: number;
 }
 
@@ -16,6 +17,30 @@ export interface Full {
   id: string;
   latitude: number;
   longitude: number;
+  location: { latitude: number; longitude: number };
+}
+
+// definition: DB.Geo@FullPage
+export interface FullPage {
+  items: Full[];
+  nextCursor: string;
+}
+
+// definition: DB.Geo@Query
+export interface Query {
+  where?: { [key: string]: unknown };
+  orders?: string[];
+  limit?: number;
+  offset?: number;
+  cursor?: string;
+}
+
+// definition: DB.Geo@Update
+export interface Update {
+  id: string;
+  latitude?: number;
+  location?: { latitude: number; longitude: number };
+  longitude?: number;
 }
 
 // tag-capi-builder-end
\ No newline at end of file